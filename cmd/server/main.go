@@ -5,11 +5,17 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	apphttp "github.com/bastianvv/vio/internal/http"
+	"github.com/bastianvv/vio/internal/language"
+	"github.com/bastianvv/vio/internal/media"
 	"github.com/bastianvv/vio/internal/metadata"
 	"github.com/bastianvv/vio/internal/metadata/tmdb"
 	"github.com/bastianvv/vio/internal/store"
+	"github.com/bastianvv/vio/internal/subprovider"
 	"github.com/joho/godotenv"
 )
 
@@ -17,7 +23,8 @@ func main() {
 
 	_ = godotenv.Load()
 
-	dbPath := envOr("VIO_DB", "vio.db")
+	// A SQLite file path, or a postgres:// DSN
+	dbDSN := envOr("VIO_DB", "vio.db")
 	addr := envOr("VIO_ADDR", ":8080")
 	tmdbKey := os.Getenv("TMDB_API_KEY")
 
@@ -25,17 +32,13 @@ func main() {
 		log.Fatal("TMDB_API_KEY is required")
 	}
 
-	// Open database
-	s, err := store.NewSQLiteStore(dbPath)
+	// Open and migrate database
+	s, err := store.Open(dbDSN)
 	if err != nil {
 		log.Fatalf("failed to open db: %v", err)
 	}
 	defer func() { _ = s.Close() }()
 
-	if err := s.EnsureSchema(); err != nil {
-		log.Fatalf("failed to ensure schema: %v", err)
-	}
-
 	// Metadata
 	imageBasePath := os.Getenv("IMAGE_CACHE_DIR")
 	absImagePath, err := filepath.Abs(imageBasePath)
@@ -50,8 +53,37 @@ func main() {
 		log.Fatalf("failed to create image cache dir: %v", err)
 	}
 
+	// Extracted embedded subtitles
+	subtitleCachePath, err := filepath.Abs(envOr("SUBTITLE_CACHE_DIR", "subtitles"))
+	if err != nil {
+		panic(err)
+	}
+
+	if err := os.MkdirAll(subtitleCachePath, 0755); err != nil {
+		log.Fatalf("failed to create subtitle cache dir: %v", err)
+	}
+
+	// Subtitle providers (optional)
+	var providers []subprovider.Provider
+	if key := os.Getenv("OPENSUBTITLES_API_KEY"); key != "" {
+		providers = append(providers, subprovider.NewOpenSubtitles(key, os.Getenv("OPENSUBTITLES_BASE_URL")))
+	}
+	subtitleProviders := subprovider.NewManager(s, providers, subtitleLanguages(), subtitleCachePath)
+
+	// Scanner: commits every VIO_SCAN_BATCH_SIZE files or
+	// VIO_SCAN_BATCH_INTERVAL ("2s"), whichever comes first
+	batchSize, err := strconv.Atoi(envOr("VIO_SCAN_BATCH_SIZE", strconv.Itoa(media.DefaultBatchSize)))
+	if err != nil {
+		log.Fatalf("invalid VIO_SCAN_BATCH_SIZE: %v", err)
+	}
+	batchInterval, err := time.ParseDuration(envOr("VIO_SCAN_BATCH_INTERVAL", media.DefaultBatchInterval.String()))
+	if err != nil {
+		log.Fatalf("invalid VIO_SCAN_BATCH_INTERVAL: %v", err)
+	}
+	scanner := media.NewScanner(s, batchSize, batchInterval)
+
 	// Router
-	r := apphttp.NewRouter(s, enricher, absImagePath)
+	r := apphttp.NewRouter(s, enricher, subtitleProviders, scanner, absImagePath, subtitleCachePath)
 
 	log.Printf("VIO listening on %s", addr)
 	if err := http.ListenAndServe(addr, r); err != nil {
@@ -65,3 +97,15 @@ func envOr(key, def string) string {
 	}
 	return def
 }
+
+// subtitleLanguages reads SUBTITLE_LANGUAGES ("en,es,pt-BR"), in order of
+// preference.
+func subtitleLanguages() []string {
+	var out []string
+	for _, l := range strings.Split(envOr("SUBTITLE_LANGUAGES", "en"), ",") {
+		if tag := language.Normalize(l); tag != "" {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
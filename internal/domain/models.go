@@ -11,11 +11,14 @@ const (
 	LibraryTypeOther  LibraryType = "other"
 )
 
+// Library is a catalog of media under one or more root folders, e.g. one
+// per disk. Path is the first root.
 type Library struct {
 	ID        int64       `json:"id"`
 	Name      string      `json:"name"`
 	Type      LibraryType `json:"type"`
 	Path      string      `json:"path"`
+	Roots     []string    `json:"roots"`
 	CreatedAt time.Time   `json:"created_at"`
 	UpdatedAt time.Time   `json:"updated_at"`
 }
@@ -40,7 +43,9 @@ type Series struct {
 	LibraryID     int64     `json:"library_id"`
 	Title         string    `json:"title"`
 	OriginalTitle string    `json:"original_title"`
+	Year          int       `json:"year"` // first-air year, 0 when unknown
 	TMDBID        *string   `json:"tmdb_id,omitempty"`
+	TVDBID        *string   `json:"tvdb_id,omitempty"`
 	Overview      string    `json:"overview"`
 	Status        string    `json:"status"` // ongoing, ended, etc.
 	PosterPath    *string   `json:"poster_path,omitempty"`
@@ -63,18 +68,30 @@ type Season struct {
 	HasPoster  bool       `json:"has_poster"`
 }
 
+// TitleSource records where an episode title came from, so that
+// enrichment knows which titles it may overwrite.
+type TitleSource string
+
+const (
+	TitleSourceNone     TitleSource = ""
+	TitleSourceFilename TitleSource = "filename"
+	TitleSourceTMDB     TitleSource = "tmdb"
+	TitleSourceManual   TitleSource = "manual"
+)
+
 type Episode struct {
-	ID         int64      `json:"id"`
-	SeasonID   int64      `json:"season_id"`
-	Number     int        `json:"number"`
-	TMDBID     *string    `json:"tmdb_id,omitempty"`
-	Title      string     `json:"title"`
-	Overview   string     `json:"overview"`
-	AirDate    *time.Time `json:"air_date,omitempty"`
-	RuntimeMin int        `json:"runtime_min"`
-	StillPath  *string    `json:"still_path,omitempty"`
-	CreatedAt  time.Time  `json:"created_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
+	ID          int64       `json:"id"`
+	SeasonID    int64       `json:"season_id"`
+	Number      int         `json:"number"`
+	TMDBID      *string     `json:"tmdb_id,omitempty"`
+	Title       string      `json:"title"`
+	TitleSource TitleSource `json:"title_source"`
+	Overview    string      `json:"overview"`
+	AirDate     *time.Time  `json:"air_date,omitempty"`
+	RuntimeMin  int         `json:"runtime_min"`
+	StillPath   *string     `json:"still_path,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
 }
 
 type MediaFile struct {
@@ -95,10 +112,37 @@ type MediaFile struct {
 	VideoHeight   int        `json:"video_height"`
 	AudioChannels int        `json:"audio_channels"`
 	DurationSec   int        `json:"duration_sec"`
-	CreatedAt     time.Time  `json:"created_at"`
-	UpdatedAt     time.Time  `json:"updated_at"`
+	BitRate       int64      `json:"bit_rate"` // overall, bits/s
+
+	// Release-name hints parsed from the filename (see internal/release)
+	ReleaseResolution string `json:"release_resolution"`
+	ReleaseSource     string `json:"release_source"`
+	ReleaseVideoCodec string `json:"release_video_codec"`
+	ReleaseAudioCodec string `json:"release_audio_codec"`
+	ReleaseHDR        string `json:"release_hdr"` // comma separated
+	ReleaseGroup      string `json:"release_group"`
+	IsRemux           bool   `json:"is_remux"`
+	IsProper          bool   `json:"is_proper"`
+	IsRepack          bool   `json:"is_repack"`
+
+	// Probe outcome. Files ffprobe cannot read are kept with the error so
+	// they can be listed and retried.
+	ProbeStatus   ProbeStatus `json:"probe_status"`
+	ProbeError    string      `json:"probe_error"`
+	ProbeAttempts int         `json:"probe_attempts"` // consecutive failures
+	NextProbeAt   *time.Time  `json:"next_probe_at"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
+type ProbeStatus string
+
+const (
+	ProbeStatusOK    ProbeStatus = "OK"
+	ProbeStatusError ProbeStatus = "ERROR"
+)
+
 type MediaFileEpisode struct {
 	ID          int64 `json:"id"`
 	MediaFileID int64 `json:"media_file_id"`
@@ -108,20 +152,34 @@ type MediaFileEpisode struct {
 type SubtitleSource string
 
 const (
-	SubtitleSourceEmbedded SubtitleSource = "EMBEDDED"
-	SubtitleSourceExternal SubtitleSource = "EXTERNAL"
+	SubtitleSourceEmbedded   SubtitleSource = "EMBEDDED"
+	SubtitleSourceExternal   SubtitleSource = "EXTERNAL"
+	SubtitleSourceSynced     SubtitleSource = "SYNCED"     // auto-synced copy of another track
+	SubtitleSourceDownloaded SubtitleSource = "DOWNLOADED" // fetched from a subtitle provider
 )
 
 type SubtitleTrack struct {
-	ID           int64          `json:"id"`
-	MediaFileID  int64          `json:"media_file_id"`
-	Source       SubtitleSource `json:"source"`
-	ExternalPath *string        `json:"external_path,omitempty"`
-	StreamIndex  *int           `json:"stream_index,omitempty"`
-	Language     string         `json:"language"`
-	IsForced     bool           `json:"is_forced"`
-	IsDefault    bool           `json:"is_default"`
-	Format       string         `json:"format"`
+	ID               int64          `json:"id"`
+	MediaFileID      int64          `json:"media_file_id"`
+	Source           SubtitleSource `json:"source"`
+	ExternalPath     *string        `json:"external_path,omitempty"`
+	StreamIndex      *int           `json:"stream_index,omitempty"`
+	Language         string         `json:"language"`
+	Title            string         `json:"title"`
+	IsForced         bool           `json:"is_forced"`
+	IsSDH            bool           `json:"is_sdh"` // hearing impaired
+	IsDefault        bool           `json:"is_default"`
+	IsCommentary     bool           `json:"is_commentary"`
+	IsVisualImpaired bool           `json:"is_visual_impaired"`
+	IsDub            bool           `json:"is_dub"`
+	IsOriginal       bool           `json:"is_original"`
+	Format           string         `json:"format"`
+	IsBitmap         bool           `json:"is_bitmap"`
+	OffsetMs         int64          `json:"offset_ms"` // saved timing correction, set by users
+
+	// DerivedFromID links generated tracks (SYNCED) to the track they were
+	// made from.
+	DerivedFromID *int64 `json:"derived_from_id,omitempty"`
 }
 
 type AudioTrack struct {
@@ -129,7 +187,73 @@ type AudioTrack struct {
 	MediaFileID int64  `json:"media_file_id"`
 	StreamIndex int    `json:"stream_index"`
 	Language    string `json:"language"`
+	Title       string `json:"title"`
 	Codec       string `json:"codec"`
+	Profile     string `json:"profile"`
 	Channels    int    `json:"channels"`
+	BitRate     int64  `json:"bit_rate"`
 	IsDefault   bool   `json:"is_default"`
+
+	// Dispositions
+	IsCommentary      bool `json:"is_commentary"`
+	IsHearingImpaired bool `json:"is_hearing_impaired"`
+	IsVisualImpaired  bool `json:"is_visual_impaired"` // audio description
+	IsDub             bool `json:"is_dub"`
+	IsOriginal        bool `json:"is_original"`
+}
+
+// VideoStream is the technical description of one video stream in a file.
+type VideoStream struct {
+	ID             int64   `json:"id"`
+	MediaFileID    int64   `json:"media_file_id"`
+	StreamIndex    int     `json:"stream_index"`
+	Codec          string  `json:"codec"`
+	Profile        string  `json:"profile"`
+	Level          int     `json:"level"`
+	Width          int     `json:"width"`
+	Height         int     `json:"height"`
+	BitRate        int64   `json:"bit_rate"`
+	FrameRate      float64 `json:"frame_rate"`
+	BitDepth       int     `json:"bit_depth"`
+	PixelFormat    string  `json:"pixel_format"`
+	ColorRange     string  `json:"color_range"`
+	ColorSpace     string  `json:"color_space"`
+	ColorTransfer  string  `json:"color_transfer"`
+	ColorPrimaries string  `json:"color_primaries"`
+	HDRFormats     string  `json:"hdr_formats"` // comma separated: DV, HDR10+, HDR10, HLG
+	ScanType       string  `json:"scan_type"`   // progressive | interlaced | ""
+	IsDefault      bool    `json:"is_default"`
+}
+
+// Chapter is a chapter marker probed from a media file (MKV/MP4).
+type Chapter struct {
+	ID          int64  `json:"id"`
+	MediaFileID int64  `json:"media_file_id"`
+	Index       int    `json:"index"`
+	StartMs     int64  `json:"start_ms"`
+	EndMs       int64  `json:"end_ms"`
+	Title       string `json:"title"`
+}
+
+type SearchResultType string
+
+const (
+	SearchResultMovie   SearchResultType = "movie"
+	SearchResultSeries  SearchResultType = "series"
+	SearchResultEpisode SearchResultType = "episode"
+)
+
+// SearchResult is one catalog search hit. Episode hits carry their series
+// and numbers so clients can label them.
+type SearchResult struct {
+	Type      SearchResultType `json:"type"`
+	ID        int64            `json:"id"`
+	LibraryID int64            `json:"library_id"`
+	Title     string           `json:"title"`
+	Year      int              `json:"year,omitempty"`
+
+	SeriesID      int64  `json:"series_id,omitempty"`
+	SeriesTitle   string `json:"series_title,omitempty"`
+	SeasonNumber  int    `json:"season_number,omitempty"`
+	EpisodeNumber int    `json:"episode_number,omitempty"`
 }
@@ -1,15 +1,28 @@
 package dto
 
-import "github.com/bastianvv/vio/internal/domain"
+import (
+	"github.com/bastianvv/vio/internal/domain"
+	"github.com/bastianvv/vio/internal/language"
+)
 
 type AudioTrack struct {
-	ID          int64  `json:"id"`
-	MediaFileID int64  `json:"media_file_id"`
-	StreamIndex int    `json:"stream_index"`
-	Language    string `json:"language,omitempty"`
-	Codec       string `json:"codec,omitempty"`
-	Channels    int    `json:"channels,omitempty"`
-	IsDefault   bool   `json:"is_default"`
+	ID           int64  `json:"id"`
+	MediaFileID  int64  `json:"media_file_id"`
+	StreamIndex  int    `json:"stream_index"`
+	Language     string `json:"language,omitempty"`
+	LanguageName string `json:"language_name,omitempty"`
+	Title        string `json:"title,omitempty"`
+	Codec        string `json:"codec,omitempty"`
+	Profile      string `json:"profile,omitempty"`
+	Channels     int    `json:"channels,omitempty"`
+	BitRate      int64  `json:"bit_rate,omitempty"`
+	IsDefault    bool   `json:"is_default"`
+
+	IsCommentary      bool `json:"is_commentary"`
+	IsHearingImpaired bool `json:"is_hearing_impaired"`
+	IsVisualImpaired  bool `json:"is_visual_impaired"` // audio description
+	IsDub             bool `json:"is_dub"`
+	IsOriginal        bool `json:"is_original"`
 }
 
 func NewAudioTrack(at *domain.AudioTrack) *AudioTrack {
@@ -18,12 +31,22 @@ func NewAudioTrack(at *domain.AudioTrack) *AudioTrack {
 	}
 
 	return &AudioTrack{
-		ID:          at.ID,
-		MediaFileID: at.MediaFileID,
-		StreamIndex: at.StreamIndex,
-		Language:    at.Language,
-		Codec:       at.Codec,
-		Channels:    at.Channels,
-		IsDefault:   at.IsDefault,
+		ID:           at.ID,
+		MediaFileID:  at.MediaFileID,
+		StreamIndex:  at.StreamIndex,
+		Language:     at.Language,
+		LanguageName: language.Name(at.Language),
+		Title:        at.Title,
+		Codec:        at.Codec,
+		Profile:      at.Profile,
+		Channels:     at.Channels,
+		BitRate:      at.BitRate,
+		IsDefault:    at.IsDefault,
+
+		IsCommentary:      at.IsCommentary,
+		IsHearingImpaired: at.IsHearingImpaired,
+		IsVisualImpaired:  at.IsVisualImpaired,
+		IsDub:             at.IsDub,
+		IsOriginal:        at.IsOriginal,
 	}
 }
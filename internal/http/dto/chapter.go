@@ -0,0 +1,23 @@
+package dto
+
+import "github.com/bastianvv/vio/internal/domain"
+
+type Chapter struct {
+	Index   int    `json:"index"`
+	StartMs int64  `json:"start_ms"`
+	EndMs   int64  `json:"end_ms"`
+	Title   string `json:"title,omitempty"`
+}
+
+func NewChapter(ch *domain.Chapter) *Chapter {
+	if ch == nil {
+		return nil
+	}
+
+	return &Chapter{
+		Index:   ch.Index,
+		StartMs: ch.StartMs,
+		EndMs:   ch.EndMs,
+		Title:   ch.Title,
+	}
+}
@@ -7,15 +7,16 @@ import (
 )
 
 type Episode struct {
-	ID       int64      `json:"id"`
-	SeasonID int64      `json:"season_id"`
-	Number   int        `json:"number"`
-	TMDBID   *string    `json:"tmdb_id,omitempty"`
-	Title    string     `json:"title,omitempty"`
-	Overview string     `json:"overview,omitempty"`
-	AirDate  *time.Time `json:"air_date,omitempty"`
-	Runtime  int        `json:"runtime_min,omitempty"`
-	HasStill bool       `json:"has_still"`
+	ID          int64      `json:"id"`
+	SeasonID    int64      `json:"season_id"`
+	Number      int        `json:"number"`
+	TMDBID      *string    `json:"tmdb_id,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	TitleSource string     `json:"title_source,omitempty"` // filename | tmdb | manual
+	Overview    string     `json:"overview,omitempty"`
+	AirDate     *time.Time `json:"air_date,omitempty"`
+	Runtime     int        `json:"runtime_min,omitempty"`
+	HasStill    bool       `json:"has_still"`
 }
 
 func NewEpisode(e *domain.Episode, hasStill bool) *Episode {
@@ -24,14 +25,15 @@ func NewEpisode(e *domain.Episode, hasStill bool) *Episode {
 	}
 
 	return &Episode{
-		ID:       e.ID,
-		SeasonID: e.SeasonID,
-		Number:   e.Number,
-		TMDBID:   e.TMDBID,
-		Title:    e.Title,
-		Overview: e.Overview,
-		AirDate:  e.AirDate,
-		Runtime:  e.RuntimeMin,
-		HasStill: hasStill, // ← SAME FIX AS SEASONS
+		ID:          e.ID,
+		SeasonID:    e.SeasonID,
+		Number:      e.Number,
+		TMDBID:      e.TMDBID,
+		Title:       e.Title,
+		TitleSource: string(e.TitleSource),
+		Overview:    e.Overview,
+		AirDate:     e.AirDate,
+		Runtime:     e.RuntimeMin,
+		HasStill:    hasStill, // ← SAME FIX AS SEASONS
 	}
 }
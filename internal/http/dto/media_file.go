@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"strings"
 	"time"
 
 	"github.com/bastianvv/vio/internal/domain"
@@ -19,10 +20,59 @@ type MediaFile struct {
 	Height        int    `json:"height"`
 	AudioChannels int    `json:"audio_channels"`
 	DurationSec   int    `json:"duration_sec"`
+	BitRate       int64  `json:"bit_rate,omitempty"`
 
 	IsMissing    bool       `json:"is_missing"`
 	MissingSince *time.Time `json:"missing_since,omitempty"`
 	LastSeenAt   *time.Time `json:"last_seen_at,omitempty"`
+
+	Release *Release `json:"release,omitempty"`
+
+	// Set when ffprobe could not read the file
+	ProbeStatus   string     `json:"probe_status,omitempty"` // "OK" | "ERROR"
+	ProbeError    string     `json:"probe_error,omitempty"`
+	ProbeAttempts int        `json:"probe_attempts,omitempty"`
+	NextProbeAt   *time.Time `json:"next_probe_at,omitempty"`
+
+	// Only on GET /api/files/{id}
+	VideoStreams []*VideoStream `json:"video_streams,omitempty"`
+}
+
+// Release holds quality attributes parsed from the file name.
+type Release struct {
+	Resolution string   `json:"resolution,omitempty"`
+	Source     string   `json:"source,omitempty"`
+	VideoCodec string   `json:"video_codec,omitempty"`
+	AudioCodec string   `json:"audio_codec,omitempty"`
+	HDR        []string `json:"hdr,omitempty"`
+	Group      string   `json:"group,omitempty"`
+	Remux      bool     `json:"remux"`
+	Proper     bool     `json:"proper"`
+	Repack     bool     `json:"repack"`
+}
+
+func newRelease(m *domain.MediaFile) *Release {
+	if m.ReleaseResolution == "" && m.ReleaseSource == "" &&
+		m.ReleaseVideoCodec == "" && m.ReleaseAudioCodec == "" &&
+		m.ReleaseHDR == "" && m.ReleaseGroup == "" &&
+		!m.IsRemux && !m.IsProper && !m.IsRepack {
+		return nil
+	}
+
+	r := &Release{
+		Resolution: m.ReleaseResolution,
+		Source:     m.ReleaseSource,
+		VideoCodec: m.ReleaseVideoCodec,
+		AudioCodec: m.ReleaseAudioCodec,
+		Group:      m.ReleaseGroup,
+		Remux:      m.IsRemux,
+		Proper:     m.IsProper,
+		Repack:     m.IsRepack,
+	}
+	if m.ReleaseHDR != "" {
+		r.HDR = strings.Split(m.ReleaseHDR, ",")
+	}
+	return r
 }
 
 func NewMediaFile(m *domain.MediaFile) *MediaFile {
@@ -43,8 +93,14 @@ func NewMediaFile(m *domain.MediaFile) *MediaFile {
 		Height:        m.VideoHeight,
 		AudioChannels: m.AudioChannels,
 		DurationSec:   m.DurationSec,
+		BitRate:       m.BitRate,
 		IsMissing:     m.IsMissing,
 		MissingSince:  m.MissingSince,
 		LastSeenAt:    m.LastSeenAt,
+		Release:       newRelease(m),
+		ProbeStatus:   string(m.ProbeStatus),
+		ProbeError:    m.ProbeError,
+		ProbeAttempts: m.ProbeAttempts,
+		NextProbeAt:   m.NextProbeAt,
 	}
 }
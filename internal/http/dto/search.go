@@ -0,0 +1,29 @@
+package dto
+
+import "github.com/bastianvv/vio/internal/domain"
+
+type SearchResult struct {
+	Type          string `json:"type"` // movie | series | episode
+	ID            int64  `json:"id"`
+	LibraryID     int64  `json:"library_id"`
+	Title         string `json:"title"`
+	Year          int    `json:"year,omitempty"`
+	SeriesID      int64  `json:"series_id,omitempty"`
+	SeriesTitle   string `json:"series_title,omitempty"`
+	SeasonNumber  int    `json:"season_number,omitempty"`
+	EpisodeNumber int    `json:"episode_number,omitempty"`
+}
+
+func NewSearchResult(r *domain.SearchResult) *SearchResult {
+	return &SearchResult{
+		Type:          string(r.Type),
+		ID:            r.ID,
+		LibraryID:     r.LibraryID,
+		Title:         r.Title,
+		Year:          r.Year,
+		SeriesID:      r.SeriesID,
+		SeriesTitle:   r.SeriesTitle,
+		SeasonNumber:  r.SeasonNumber,
+		EpisodeNumber: r.EpisodeNumber,
+	}
+}
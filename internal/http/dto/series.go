@@ -7,7 +7,9 @@ type Series struct {
 	LibraryID     int64   `json:"library_id"`
 	Title         string  `json:"title"`
 	OriginalTitle string  `json:"original_title,omitempty"`
+	Year          int     `json:"year,omitempty"`
 	TMDBID        *string `json:"tmdb_id,omitempty"`
+	TVDBID        *string `json:"tvdb_id,omitempty"`
 	Overview      string  `json:"overview,omitempty"`
 	Status        string  `json:"status,omitempty"`
 	HasPoster     bool    `json:"has_poster"`
@@ -24,7 +26,9 @@ func NewSeries(s *domain.Series, hasPoster, hasBackdrop bool) *Series {
 		LibraryID:     s.LibraryID,
 		Title:         s.Title,
 		OriginalTitle: s.OriginalTitle,
+		Year:          s.Year,
 		TMDBID:        s.TMDBID,
+		TVDBID:        s.TVDBID,
 		Overview:      s.Overview,
 		Status:        s.Status,
 		HasPoster:     hasPoster,
@@ -1,21 +1,36 @@
 package dto
 
-import "github.com/bastianvv/vio/internal/domain"
+import (
+	"github.com/bastianvv/vio/internal/domain"
+	"github.com/bastianvv/vio/internal/language"
+)
 
 type SubtitleTrack struct {
-	ID        int64  `json:"id"`
-	Source    string `json:"source"` // "EMBEDDED" | "EXTERNAL"
-	Language  string `json:"language,omitempty"`
-	Format    string `json:"format,omitempty"`
-	IsForced  bool   `json:"is_forced"`
-	IsDefault bool   `json:"is_default"`
-	StreamURL string `json:"stream_url,omitempty"`
+	ID               int64  `json:"id"`
+	Source           string `json:"source"` // "EMBEDDED" | "EXTERNAL" | "SYNCED" | "DOWNLOADED"
+	Language         string `json:"language,omitempty"`
+	LanguageName     string `json:"language_name,omitempty"`
+	Title            string `json:"title,omitempty"`
+	Format           string `json:"format,omitempty"`
+	IsForced         bool   `json:"is_forced"`
+	IsSDH            bool   `json:"is_sdh"` // hearing impaired
+	IsDefault        bool   `json:"is_default"`
+	IsCommentary     bool   `json:"is_commentary"`
+	IsVisualImpaired bool   `json:"is_visual_impaired"`
+	IsDub            bool   `json:"is_dub"`
+	IsOriginal       bool   `json:"is_original"`
+	IsBitmap         bool   `json:"is_bitmap"` // image based (PGS, VobSub); must be burned in
+	OffsetMs         int64  `json:"offset_ms"`
+	StreamURL        string `json:"stream_url,omitempty"`
 
 	// Only for embedded subtitles
 	StreamIndex *int `json:"stream_index,omitempty"`
 
 	// Only for external subtitles
 	External bool `json:"external"`
+
+	// Only for generated tracks, e.g. auto-synced copies
+	DerivedFromID *int64 `json:"derived_from_id,omitempty"`
 }
 
 func NewSubtitleTrack(st *domain.SubtitleTrack, streamURL string) *SubtitleTrack {
@@ -24,14 +39,24 @@ func NewSubtitleTrack(st *domain.SubtitleTrack, streamURL string) *SubtitleTrack
 	}
 
 	return &SubtitleTrack{
-		ID:          st.ID,
-		Source:      string(st.Source),
-		Language:    st.Language,
-		Format:      st.Format,
-		IsForced:    st.IsForced,
-		IsDefault:   st.IsDefault,
-		StreamURL:   streamURL,
-		StreamIndex: st.StreamIndex,
-		External:    st.Source == domain.SubtitleSourceExternal,
+		ID:               st.ID,
+		Source:           string(st.Source),
+		Language:         st.Language,
+		LanguageName:     language.Name(st.Language),
+		Title:            st.Title,
+		Format:           st.Format,
+		IsForced:         st.IsForced,
+		IsSDH:            st.IsSDH,
+		IsDefault:        st.IsDefault,
+		IsCommentary:     st.IsCommentary,
+		IsVisualImpaired: st.IsVisualImpaired,
+		IsDub:            st.IsDub,
+		IsOriginal:       st.IsOriginal,
+		IsBitmap:         st.IsBitmap,
+		OffsetMs:         st.OffsetMs,
+		StreamURL:        streamURL,
+		StreamIndex:      st.StreamIndex,
+		External:         st.Source == domain.SubtitleSourceExternal,
+		DerivedFromID:    st.DerivedFromID,
 	}
 }
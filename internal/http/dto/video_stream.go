@@ -0,0 +1,56 @@
+package dto
+
+import (
+	"strings"
+
+	"github.com/bastianvv/vio/internal/domain"
+)
+
+type VideoStream struct {
+	StreamIndex    int      `json:"stream_index"`
+	Codec          string   `json:"codec,omitempty"`
+	Profile        string   `json:"profile,omitempty"`
+	Level          int      `json:"level,omitempty"`
+	Width          int      `json:"width"`
+	Height         int      `json:"height"`
+	BitRate        int64    `json:"bit_rate,omitempty"`
+	FrameRate      float64  `json:"frame_rate,omitempty"`
+	BitDepth       int      `json:"bit_depth,omitempty"`
+	PixelFormat    string   `json:"pixel_format,omitempty"`
+	ColorRange     string   `json:"color_range,omitempty"`
+	ColorSpace     string   `json:"color_space,omitempty"`
+	ColorTransfer  string   `json:"color_transfer,omitempty"`
+	ColorPrimaries string   `json:"color_primaries,omitempty"`
+	HDR            []string `json:"hdr,omitempty"` // DV | HDR10+ | HDR10 | HLG
+	ScanType       string   `json:"scan_type,omitempty"`
+	IsDefault      bool     `json:"is_default"`
+}
+
+func NewVideoStream(vs *domain.VideoStream) *VideoStream {
+	if vs == nil {
+		return nil
+	}
+
+	out := &VideoStream{
+		StreamIndex:    vs.StreamIndex,
+		Codec:          vs.Codec,
+		Profile:        vs.Profile,
+		Level:          vs.Level,
+		Width:          vs.Width,
+		Height:         vs.Height,
+		BitRate:        vs.BitRate,
+		FrameRate:      vs.FrameRate,
+		BitDepth:       vs.BitDepth,
+		PixelFormat:    vs.PixelFormat,
+		ColorRange:     vs.ColorRange,
+		ColorSpace:     vs.ColorSpace,
+		ColorTransfer:  vs.ColorTransfer,
+		ColorPrimaries: vs.ColorPrimaries,
+		ScanType:       vs.ScanType,
+		IsDefault:      vs.IsDefault,
+	}
+	if vs.HDRFormats != "" {
+		out.HDR = strings.Split(vs.HDRFormats, ",")
+	}
+	return out
+}
@@ -1,11 +1,13 @@
 package http
 
 import (
+	"encoding/json"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 
+	"github.com/bastianvv/vio/internal/domain"
 	"github.com/bastianvv/vio/internal/http/dto"
 	"github.com/bastianvv/vio/internal/store"
 	"github.com/go-chi/chi/v5"
@@ -32,6 +34,44 @@ func (h *EpisodesHandler) GetEpisode(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, dto.NewEpisode(ep, h.episodeHasStill(ep.ID)))
 }
 
+type UpdateEpisodeRequest struct {
+	Title *string `json:"title"`
+}
+
+// UpdateEpisode saves a hand-edited title. It is marked manual, so neither
+// rescans nor TMDB enrichment replace it.
+func (h *EpisodesHandler) UpdateEpisode(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid episode id", http.StatusBadRequest)
+		return
+	}
+
+	ep, err := h.store.GetEpisode(id)
+	if err != nil || ep == nil {
+		http.Error(w, "episode not found", http.StatusNotFound)
+		return
+	}
+
+	var req UpdateEpisodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Title != nil {
+		ep.Title = *req.Title
+		ep.TitleSource = domain.TitleSourceManual
+		if err := h.store.UpdateEpisode(ep); err != nil {
+			http.Error(w, "failed to update episode", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, dto.NewEpisode(ep, h.episodeHasStill(ep.ID)))
+}
+
 func (h *EpisodesHandler) ListEpisodesBySeason(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	seasonID, err := strconv.ParseInt(idStr, 10, 64)
@@ -40,9 +80,16 @@ func (h *EpisodesHandler) ListEpisodesBySeason(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	episodes, err := h.store.ListEpisodesBySeason(seasonID)
+	filter, page, err := parseListQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.SeasonID = seasonID
+
+	episodes, total, err := h.store.QueryEpisodes(filter, page)
 	if err != nil {
-		http.Error(w, "failed to list episodes", http.StatusInternalServerError)
+		listError(w, err, "failed to list episodes")
 		return
 	}
 
@@ -52,7 +99,7 @@ func (h *EpisodesHandler) ListEpisodesBySeason(w http.ResponseWriter, r *http.Re
 		out = append(out, dto.NewEpisode(ep, h.episodeHasStill(ep.ID)))
 	}
 
-	writeJSON(w, out)
+	writeList(w, out, total)
 }
 
 func (h *EpisodesHandler) ListEpisodeFiles(w http.ResponseWriter, r *http.Request) {
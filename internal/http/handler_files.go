@@ -1,13 +1,17 @@
 package http
 
 import (
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/bastianvv/vio/internal/http/dto"
+	"github.com/bastianvv/vio/internal/language"
 	"github.com/bastianvv/vio/internal/store"
+	"github.com/bastianvv/vio/internal/subtitle"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -19,6 +23,29 @@ func NewFilesHandler(s store.Store) *FilesHandler {
 	return &FilesHandler{store: s}
 }
 
+// GET /api/files, optionally ?library_id=X, plus the parameters of
+// parseListQuery
+func (h *FilesHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
+	filter, page, err := parseListQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	files, total, err := h.store.QueryMediaFiles(filter, page)
+	if err != nil {
+		listError(w, err, "failed to list files")
+		return
+	}
+
+	out := make([]*dto.MediaFile, 0, len(files))
+	for i := range files {
+		out = append(out, dto.NewMediaFile(&files[i]))
+	}
+
+	writeList(w, out, total)
+}
+
 func (h *FilesHandler) GetFile(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 
@@ -28,7 +55,18 @@ func (h *FilesHandler) GetFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeJSON(w, dto.NewMediaFile(f))
+	streams, err := h.store.ListVideoStreams(f.ID)
+	if err != nil {
+		http.Error(w, "failed to list video streams", http.StatusInternalServerError)
+		return
+	}
+
+	out := dto.NewMediaFile(f)
+	for i := range streams {
+		out.VideoStreams = append(out.VideoStreams, dto.NewVideoStream(&streams[i]))
+	}
+
+	writeJSON(w, out)
 }
 
 func (h *FilesHandler) StreamFile(w http.ResponseWriter, r *http.Request) {
@@ -82,11 +120,64 @@ func (h *FilesHandler) ListAudioTracks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	lang := r.URL.Query().Get("language")
+
 	out := make([]*dto.AudioTrack, 0, len(tracks))
 	for i := range tracks {
+		if lang != "" && !language.Match(tracks[i].Language, lang) {
+			continue
+		}
 		out = append(out, dto.NewAudioTrack(&tracks[i]))
 	}
 
 	w.WriteHeader(http.StatusOK)
 	writeJSON(w, out)
 }
+
+// ListChapters returns the chapter markers of a file as JSON, or as a WebVTT
+// chapters track with ?format=vtt.
+func (h *FilesHandler) ListChapters(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	mediaFileID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid media file id", http.StatusBadRequest)
+		return
+	}
+
+	if mf, err := h.store.GetMediaFile(mediaFileID); err != nil || mf == nil {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+
+	chapters, err := h.store.ListChapters(mediaFileID)
+	if err != nil {
+		http.Error(w, "failed to list chapters", http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "vtt" {
+		cues := make([]subtitle.Cue, 0, len(chapters))
+		for _, ch := range chapters {
+			title := ch.Title
+			if title == "" {
+				title = fmt.Sprintf("Chapter %d", ch.Index+1)
+			}
+			cues = append(cues, subtitle.Cue{
+				Start: time.Duration(ch.StartMs) * time.Millisecond,
+				End:   time.Duration(ch.EndMs) * time.Millisecond,
+				Text:  title,
+			})
+		}
+
+		w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+		_ = subtitle.WriteVTT(w, cues)
+		return
+	}
+
+	out := make([]*dto.Chapter, 0, len(chapters))
+	for i := range chapters {
+		out = append(out, dto.NewChapter(&chapters[i]))
+	}
+
+	writeJSON(w, out)
+}
@@ -2,39 +2,77 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"sync"
 
 	"github.com/bastianvv/vio/internal/domain"
 	"github.com/bastianvv/vio/internal/http/dto"
 	"github.com/bastianvv/vio/internal/media"
+	"github.com/bastianvv/vio/internal/purge"
+	"github.com/bastianvv/vio/internal/relocate"
 	"github.com/bastianvv/vio/internal/scan"
 	"github.com/bastianvv/vio/internal/store"
+	"github.com/bastianvv/vio/internal/subsync"
 	"github.com/go-chi/chi/v5"
 )
 
 type LibrariesHandler struct {
-	store   store.Store
-	scanner media.Scanner
-	scans   *scan.Registry
+	store     store.Store
+	scanner   media.Scanner
+	scans     *scan.Registry
+	purger    *purge.Service
+	deletions *purge.Registry
+	syncs     *subsync.Service
+
+	// jobs makes checking the scan and deletion registries and starting a
+	// job one step, so a scan and a deletion of a library can't both pass
+	// their checks.
+	jobs sync.Mutex
 }
 
+// Roots lists every root folder of the library; Path alone is a library
+// with one root.
 type CreateLibraryRequest struct {
-	Name string `json:"name"`
-	Type string `json:"type"`
-	Path string `json:"path"`
+	Name  string   `json:"name"`
+	Type  string   `json:"type"`
+	Path  string   `json:"path"`
+	Roots []string `json:"roots"`
 }
 
+// Roots, when given, replaces the library's roots. Files under a root that
+// is dropped are marked missing by the next scan.
 type UpdateLibraryRequest struct {
-	Name string `json:"name"`
+	Name  string   `json:"name"`
+	Roots []string `json:"roots"`
 }
 
-func NewLibrariesHandler(s store.Store, sc media.Scanner, scans *scan.Registry) *LibrariesHandler {
+// From names the root to move; it defaults to the first.
+type RelocateLibraryRequest struct {
+	From string `json:"from"`
+	Path string `json:"path"`
+}
+
+func NewLibrariesHandler(
+	s store.Store,
+	sc media.Scanner,
+	scans *scan.Registry,
+	purger *purge.Service,
+	deletions *purge.Registry,
+	syncs *subsync.Service,
+) *LibrariesHandler {
 	return &LibrariesHandler{
-		store:   s,
-		scanner: sc,
-		scans:   scans,
+		store:     s,
+		scanner:   sc,
+		scans:     scans,
+		purger:    purger,
+		deletions: deletions,
+		syncs:     syncs,
 	}
 }
 
@@ -76,21 +114,22 @@ func (h *LibrariesHandler) CreateLibrary(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if req.Name == "" || req.Path == "" || req.Type == "" {
-		http.Error(w, "missing required fields", http.StatusBadRequest)
-		return
+	roots := req.Roots
+	if len(roots) == 0 && req.Path != "" {
+		roots = []string{req.Path}
 	}
-
-	// optional but recommended
-	if _, err := os.Stat(req.Path); err != nil {
-		http.Error(w, "library path does not exist", http.StatusBadRequest)
+	if req.Name == "" || len(roots) == 0 || req.Type == "" {
+		http.Error(w, "missing required fields", http.StatusBadRequest)
 		return
 	}
 
 	lib := &domain.Library{
 		Name: req.Name,
 		Type: domain.LibraryType(req.Type),
-		Path: req.Path,
+	}
+	if status, msg := h.checkRoots(lib, roots); status != 0 {
+		http.Error(w, msg, status)
+		return
 	}
 
 	if err := h.store.CreateLibrary(lib); err != nil {
@@ -125,6 +164,12 @@ func (h *LibrariesHandler) UpdateLibrary(w http.ResponseWriter, r *http.Request)
 	if req.Name != "" {
 		lib.Name = req.Name
 	}
+	if len(req.Roots) > 0 {
+		if status, msg := h.checkRoots(lib, req.Roots); status != 0 {
+			http.Error(w, msg, status)
+			return
+		}
+	}
 
 	if err := h.store.UpdateLibrary(lib); err != nil {
 		http.Error(w, "failed to update library", http.StatusInternalServerError)
@@ -134,6 +179,51 @@ func (h *LibrariesHandler) UpdateLibrary(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, dto.NewLibrary(lib))
 }
 
+// checkRoots sets lib's roots, cleaned, after checking that the new ones
+// exist and that no root contains another, in this library or any other:
+// a file must belong to one root only. Roots the library already has are
+// not checked again, since their disk may be offline. It returns the status
+// and message of the first problem, or 0.
+func (h *LibrariesHandler) checkRoots(lib *domain.Library, roots []string) (int, string) {
+	libs, err := h.store.ListLibraries()
+	if err != nil {
+		return http.StatusInternalServerError, "failed to list libraries"
+	}
+
+	cleaned := make([]string, 0, len(roots))
+	for _, root := range roots {
+		if root == "" {
+			return http.StatusBadRequest, "empty library root"
+		}
+		root = filepath.Clean(root)
+		if slices.Contains(cleaned, root) {
+			return http.StatusBadRequest, "duplicate library root"
+		}
+		overlaps := func(r string) bool {
+			return store.UnderRoot(root, r) || store.UnderRoot(r, root)
+		}
+		if slices.ContainsFunc(cleaned, overlaps) {
+			return http.StatusConflict, "library roots overlap each other"
+		}
+		cleaned = append(cleaned, root)
+
+		if slices.Contains(lib.Roots, root) {
+			continue
+		}
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			return http.StatusBadRequest, "library path does not exist"
+		}
+		for _, other := range libs {
+			if other.ID != lib.ID && slices.ContainsFunc(other.Roots, overlaps) {
+				return http.StatusConflict, "library root overlaps another library"
+			}
+		}
+	}
+
+	lib.Path, lib.Roots = cleaned[0], cleaned
+	return 0, ""
+}
+
 func (h *LibrariesHandler) ScanLibrary(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
@@ -148,10 +238,18 @@ func (h *LibrariesHandler) ScanLibrary(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.jobs.Lock()
+	if h.deletions.Active(id) {
+		h.jobs.Unlock()
+		http.Error(w, "library is being deleted", http.StatusConflict)
+		return
+	}
 	job := h.scans.Start(id)
+	h.jobs.Unlock()
 
 	go func(lib *domain.Library, jobID string) {
-		_, err := h.scanner.ScanLibrary(lib, media.ScanModeIncremental)
+		res, err := h.scanner.ScanLibrary(lib, media.ScanModeIncremental)
+		h.evictRemovedSubtitles(res)
 		if err != nil {
 			h.scans.Fail(jobID, err)
 			return
@@ -179,10 +277,18 @@ func (h *LibrariesHandler) RescanLibrary(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	h.jobs.Lock()
+	if h.deletions.Active(id) {
+		h.jobs.Unlock()
+		http.Error(w, "library is being deleted", http.StatusConflict)
+		return
+	}
 	job := h.scans.Start(id)
+	h.jobs.Unlock()
 
 	go func(lib *domain.Library, jobID string) {
-		_, err := h.scanner.ScanLibrary(lib, media.ScanModeRescan)
+		res, err := h.scanner.ScanLibrary(lib, media.ScanModeRescan)
+		h.evictRemovedSubtitles(res)
 		if err != nil {
 			h.scans.Fail(jobID, err)
 			return
@@ -196,6 +302,162 @@ func (h *LibrariesHandler) RescanLibrary(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// evictRemovedSubtitles drops the synced copies of subtitle tracks a scan
+// deleted; their SYNCED rows went with them by cascade.
+func (h *LibrariesHandler) evictRemovedSubtitles(res *media.ScanResult) {
+	if res == nil {
+		return
+	}
+	for _, id := range res.RemovedSubtitles {
+		if err := h.syncs.Evict(id); err != nil {
+			log.Printf("library %d: removing subtitle %d synced copy: %v", res.LibraryID, id, err)
+		}
+	}
+}
+
+// RelocateLibrary moves a library to a new root, rewriting its stored file
+// and sidecar paths. It is refused when the new root lacks the files.
+func (h *LibrariesHandler) RelocateLibrary(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid library id", http.StatusBadRequest)
+		return
+	}
+
+	lib, err := h.store.GetLibrary(id)
+	if err != nil || lib == nil {
+		http.Error(w, "library not found", http.StatusNotFound)
+		return
+	}
+
+	var req RelocateLibraryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Path == "" {
+		http.Error(w, "missing required fields", http.StatusBadRequest)
+		return
+	}
+
+	// A scan would record files under the old root meanwhile. Relocation
+	// is short (a sample of stats, then one transaction), so it holds the
+	// lock throughout rather than registering a job.
+	h.jobs.Lock()
+	defer h.jobs.Unlock()
+	if h.scans.Active(id) {
+		http.Error(w, "library is being scanned", http.StatusConflict)
+		return
+	}
+	if h.deletions.Active(id) {
+		http.Error(w, "library is being deleted", http.StatusConflict)
+		return
+	}
+
+	res, err := relocate.Relocate(h.store, id, req.From, req.Path)
+	var sampleErr *relocate.SampleError
+	switch {
+	case errors.Is(err, relocate.ErrNotDirectory), errors.Is(err, relocate.ErrUnknownRoot):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, relocate.ErrPathTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.As(err, &sampleErr):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		http.Error(w, "failed to relocate library", http.StatusInternalServerError)
+		return
+	}
+
+	out := map[string]any{
+		"library":     dto.NewLibrary(res.Library),
+		"media_files": res.MediaFiles,
+		"sidecars":    res.Sidecars,
+		"checked":     res.Checked,
+	}
+	if len(res.Absent) > 0 {
+		out["absent"] = res.Absent
+	}
+	writeJSON(w, out)
+}
+
+// DeleteLibrary removes a library with its catalog rows and cached images
+// and subtitles, as a job. Files on disk are left alone.
+func (h *LibrariesHandler) DeleteLibrary(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid library id", http.StatusBadRequest)
+		return
+	}
+
+	lib, err := h.store.GetLibrary(id)
+	if err != nil || lib == nil {
+		http.Error(w, "library not found", http.StatusNotFound)
+		return
+	}
+
+	// A scan would recreate rows behind the deletion.
+	h.jobs.Lock()
+	if h.scans.Active(id) {
+		h.jobs.Unlock()
+		http.Error(w, "library is being scanned", http.StatusConflict)
+		return
+	}
+	if h.deletions.Active(id) {
+		h.jobs.Unlock()
+		http.Error(w, "library is being deleted", http.StatusConflict)
+		return
+	}
+	job := h.deletions.Start(id)
+	h.jobs.Unlock()
+
+	go func(libraryID int64, jobID string) {
+		res, err := h.purger.DeleteLibrary(libraryID)
+		if err != nil {
+			h.deletions.Fail(jobID, err)
+			return
+		}
+		h.deletions.Finish(jobID, res)
+	}(id, job.ID)
+
+	writeJSON(w, map[string]any{
+		"job_id": job.ID,
+		"status": job.Status,
+	})
+}
+
+// ListProblems returns the files of a library that could not be probed.
+func (h *LibrariesHandler) ListProblems(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid library id", http.StatusBadRequest)
+		return
+	}
+
+	lib, err := h.store.GetLibrary(id)
+	if err != nil || lib == nil {
+		http.Error(w, "library not found", http.StatusNotFound)
+		return
+	}
+
+	files, err := h.store.ListMediaFilesWithProbeErrors(lib.ID)
+	if err != nil {
+		http.Error(w, "failed to list problems", http.StatusInternalServerError)
+		return
+	}
+
+	out := make([]*dto.MediaFile, 0, len(files))
+	for i := range files {
+		out = append(out, dto.NewMediaFile(&files[i]))
+	}
+	writeJSON(w, out)
+}
+
 func (h *LibrariesHandler) GetScanJob(w http.ResponseWriter, r *http.Request) {
 	jobID := chi.URLParam(r, "job_id")
 
@@ -207,3 +469,15 @@ func (h *LibrariesHandler) GetScanJob(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, job)
 }
+
+func (h *LibrariesHandler) GetDeletionJob(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "job_id")
+
+	job, ok := h.deletions.Get(jobID)
+	if !ok {
+		http.Error(w, "deletion job not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, job)
+}
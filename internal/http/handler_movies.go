@@ -32,22 +32,21 @@ func NewMoviesHandler(
 	}
 }
 
-// GET /api/movies?library_id=X
+// GET /api/movies?library_id=X, plus the parameters of parseListQuery
 func (h *MoviesHandler) ListMovies(w http.ResponseWriter, r *http.Request) {
-	lidStr := r.URL.Query().Get("library_id")
-	if lidStr == "" {
+	if r.URL.Query().Get("library_id") == "" {
 		http.Error(w, "library_id required", http.StatusBadRequest)
 		return
 	}
-	lid, err := strconv.ParseInt(lidStr, 10, 64)
+	filter, page, err := parseListQuery(r)
 	if err != nil {
-		http.Error(w, "invalid library_id", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	movies, err := h.store.ListMoviesByLibrary(lid)
+	movies, total, err := h.store.QueryMovies(filter, page)
 	if err != nil {
-		http.Error(w, "failed to list movies", http.StatusInternalServerError)
+		listError(w, err, "failed to list movies")
 		return
 	}
 
@@ -61,7 +60,7 @@ func (h *MoviesHandler) ListMovies(w http.ResponseWriter, r *http.Request) {
 		))
 	}
 
-	writeJSON(w, out)
+	writeList(w, out, total)
 }
 
 // GET /api/movies/{id}
@@ -0,0 +1,61 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bastianvv/vio/internal/domain"
+	"github.com/bastianvv/vio/internal/http/dto"
+	"github.com/bastianvv/vio/internal/store"
+)
+
+// searchLimit caps the hits returned for one query.
+const searchLimit = 50
+
+type SearchHandler struct {
+	store store.Store
+}
+
+func NewSearchHandler(store store.Store) *SearchHandler {
+	return &SearchHandler{store: store}
+}
+
+// GET /api/search?q=X&type=movie|series|episode&library_id=Y
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		http.Error(w, "q required", http.StatusBadRequest)
+		return
+	}
+
+	typ := domain.SearchResultType(r.URL.Query().Get("type"))
+	switch typ {
+	case "", domain.SearchResultMovie, domain.SearchResultSeries, domain.SearchResultEpisode:
+	default:
+		http.Error(w, "invalid type", http.StatusBadRequest)
+		return
+	}
+
+	var lid int64
+	if lidStr := r.URL.Query().Get("library_id"); lidStr != "" {
+		var err error
+		lid, err = strconv.ParseInt(lidStr, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid library_id", http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := h.store.Search(q, typ, lid, searchLimit)
+	if err != nil {
+		http.Error(w, "failed to search", http.StatusInternalServerError)
+		return
+	}
+
+	out := make([]*dto.SearchResult, 0, len(results))
+	for i := range results {
+		out = append(out, dto.NewSearchResult(&results[i]))
+	}
+
+	writeJSON(w, out)
+}
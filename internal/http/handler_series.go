@@ -23,16 +23,24 @@ func NewSeriesHandler(s store.Store, metadata metadata.Enricher, imageBaseDir st
 	return &SeriesHandler{store: s, metadata: metadata, imageBaseDir: imageBaseDir}
 }
 
+// GET /api/series, optionally ?library_id=X, plus the parameters of
+// parseListQuery
 func (h *SeriesHandler) ListSeries(w http.ResponseWriter, r *http.Request) {
-	seriesList, err := h.store.ListSeries()
+	filter, page, err := parseListQuery(r)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	seriesList, total, err := h.store.QuerySeries(filter, page)
+	if err != nil {
+		listError(w, err, "failed to list series")
 		return
 	}
 
-	// seriesList is []*domain.Series
 	out := make([]*dto.Series, 0, len(seriesList))
-	for _, s := range seriesList {
+	for i := range seriesList {
+		s := &seriesList[i]
 		out = append(out, dto.NewSeries(
 			s,
 			h.seriesHasPoster(s.ID),
@@ -40,7 +48,7 @@ func (h *SeriesHandler) ListSeries(w http.ResponseWriter, r *http.Request) {
 		))
 	}
 
-	writeJSON(w, out)
+	writeList(w, out, total)
 }
 
 func (h *SeriesHandler) GetSeries(w http.ResponseWriter, r *http.Request) {
@@ -1,23 +1,45 @@
 package http
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/bastianvv/vio/internal/domain"
 	"github.com/bastianvv/vio/internal/http/dto"
+	"github.com/bastianvv/vio/internal/language"
+	"github.com/bastianvv/vio/internal/media"
 	"github.com/bastianvv/vio/internal/store"
+	"github.com/bastianvv/vio/internal/subprovider"
+	"github.com/bastianvv/vio/internal/subsync"
+	"github.com/bastianvv/vio/internal/subtitle"
 	"github.com/go-chi/chi/v5"
 )
 
 type SubtitlesHandler struct {
-	store store.Store
+	store     store.Store
+	cache     *media.SubtitleCache
+	syncer    *subsync.Service
+	syncs     *subsync.Registry
+	providers *subprovider.Manager
 }
 
-func NewSubtitlesHandler(s store.Store) *SubtitlesHandler {
-	return &SubtitlesHandler{store: s}
+func NewSubtitlesHandler(
+	s store.Store,
+	cache *media.SubtitleCache,
+	syncer *subsync.Service,
+	syncs *subsync.Registry,
+	providers *subprovider.Manager,
+) *SubtitlesHandler {
+	return &SubtitlesHandler{store: s, cache: cache, syncer: syncer, syncs: syncs, providers: providers}
 }
 
 func (h *SubtitlesHandler) ListSubtitleTracks(
@@ -37,8 +59,13 @@ func (h *SubtitlesHandler) ListSubtitleTracks(
 		return
 	}
 
+	lang := r.URL.Query().Get("language")
+
 	out := make([]*dto.SubtitleTrack, 0, len(subs))
 	for i := range subs {
+		if lang != "" && !language.Match(subs[i].Language, lang) {
+			continue
+		}
 		streamURL := "/api/subtitles/" + strconv.FormatInt(subs[i].ID, 10) + "/stream"
 		out = append(out, dto.NewSubtitleTrack(&subs[i], streamURL))
 	}
@@ -68,12 +95,65 @@ func (h *SubtitlesHandler) StreamSubtitleTrack(
 		return
 	}
 
-	if st.Source != domain.SubtitleSourceExternal || st.ExternalPath == nil {
-		http.Error(w, "embedded subtitle streaming not implemented", http.StatusNotImplemented)
+	var path string
+	switch {
+	case st.Source == domain.SubtitleSourceEmbedded:
+		mf, err := h.store.GetMediaFile(st.MediaFileID)
+		if err != nil || mf == nil {
+			http.Error(w, "media file not found", http.StatusNotFound)
+			return
+		}
+
+		path, err = h.cache.Extract(r.Context(), mf, st)
+		if errors.Is(err, media.ErrBitmapSubtitle) {
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Printf("subtitle %d: extraction timed out", st.ID)
+			http.Error(w, "subtitle extraction timed out", http.StatusGatewayTimeout)
+			return
+		}
+		if err != nil {
+			log.Printf("subtitle %d: extraction failed: %v", st.ID, err)
+			http.Error(w, "failed to extract subtitle", http.StatusInternalServerError)
+			return
+		}
+
+	case st.ExternalPath != nil:
+		path = *st.ExternalPath
+
+	default:
+		http.Error(w, "subtitle track has no file", http.StatusNotFound)
+		return
+	}
+
+	// Timing corrections work on parsed cues, so they always answer with
+	// WebVTT. An explicit offset_ms replaces the track's saved offset.
+	q := r.URL.Query()
+	offset := time.Duration(st.OffsetMs) * time.Millisecond
+	if v := q.Get("offset_ms"); v != "" {
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid offset_ms", http.StatusBadRequest)
+			return
+		}
+		offset = time.Duration(ms) * time.Millisecond
+	}
+
+	fpsFrom, errFrom := parseFPS(q.Get("fps_from"))
+	fpsTo, errTo := parseFPS(q.Get("fps_to"))
+	if errFrom != nil || errTo != nil || (fpsFrom == 0) != (fpsTo == 0) {
+		http.Error(w, "fps_from and fps_to must both be positive numbers", http.StatusBadRequest)
+		return
+	}
+
+	if q.Get("format") == "vtt" || offset != 0 || fpsFrom != 0 {
+		h.serveVTT(w, path, offset, fpsFrom, fpsTo)
 		return
 	}
 
-	f, err := os.Open(*st.ExternalPath)
+	f, err := os.Open(path)
 	if err != nil {
 		http.Error(w, "cannot open subtitle file", http.StatusNotFound)
 		return
@@ -86,11 +166,273 @@ func (h *SubtitlesHandler) StreamSubtitleTrack(
 		return
 	}
 
+	ct := subtitleContentType(path)
+	if ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+
+	// Text formats are announced as UTF-8, so sidecars in Windows-1252 or
+	// UTF-16 are decoded first, the same way the VTT conversion does.
+	var content io.ReadSeeker = f
+	if strings.Contains(ct, "charset=utf-8") {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, "cannot read subtitle file", http.StatusInternalServerError)
+			return
+		}
+		content = strings.NewReader(subtitle.Decode(data))
+	}
+
 	http.ServeContent(
 		w,
 		r,
-		filepath.Base(*st.ExternalPath),
+		filepath.Base(path),
 		stat.ModTime(),
-		f,
+		content,
 	)
 }
+
+// serveVTT converts a text subtitle file to WebVTT, applying framerate
+// retiming and then the offset.
+func (h *SubtitlesHandler) serveVTT(w http.ResponseWriter, path string, offset time.Duration, fpsFrom, fpsTo float64) {
+	format, ok := subtitle.FormatFromPath(path)
+	if !ok {
+		http.Error(w, "subtitle format cannot be converted to vtt", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		http.Error(w, "cannot open subtitle file", http.StatusNotFound)
+		return
+	}
+
+	cues, err := subtitle.Parse(data, format)
+	if err != nil {
+		http.Error(w, "failed to parse subtitle file", http.StatusUnprocessableEntity)
+		return
+	}
+
+	cues = subtitle.Retime(cues, fpsFrom, fpsTo)
+	cues = subtitle.Shift(cues, offset)
+
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	_ = subtitle.WriteVTT(w, cues)
+}
+
+func parseFPS(v string) (float64, error) {
+	if v == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f <= 0 {
+		return 0, errors.New("invalid fps")
+	}
+	return f, nil
+}
+
+type UpdateSubtitleTrackRequest struct {
+	OffsetMs *int64 `json:"offset_ms"`
+}
+
+// UpdateSubtitleTrack saves per-track settings; currently the default
+// timing offset applied for every client.
+func (h *SubtitlesHandler) UpdateSubtitleTrack(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	subtitleID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid subtitle id", http.StatusBadRequest)
+		return
+	}
+
+	st, err := h.store.GetSubtitleTrack(subtitleID)
+	if err != nil {
+		http.Error(w, "failed to load subtitle track", http.StatusInternalServerError)
+		return
+	}
+	if st == nil {
+		http.Error(w, "subtitle track not found", http.StatusNotFound)
+		return
+	}
+
+	var req UpdateSubtitleTrackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.OffsetMs != nil {
+		if err := h.store.SetSubtitleTrackOffset(st.ID, *req.OffsetMs); err != nil {
+			http.Error(w, "failed to update subtitle track", http.StatusInternalServerError)
+			return
+		}
+		st.OffsetMs = *req.OffsetMs
+	}
+
+	streamURL := "/api/subtitles/" + strconv.FormatInt(st.ID, 10) + "/stream"
+	writeJSON(w, dto.NewSubtitleTrack(st, streamURL))
+}
+
+// SyncSubtitleTrack starts an offline job that aligns a sidecar subtitle to
+// the audio and stores the result as a SYNCED track.
+func (h *SubtitlesHandler) SyncSubtitleTrack(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	subtitleID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid subtitle id", http.StatusBadRequest)
+		return
+	}
+
+	st, err := h.store.GetSubtitleTrack(subtitleID)
+	if err != nil {
+		http.Error(w, "failed to load subtitle track", http.StatusInternalServerError)
+		return
+	}
+	if st == nil {
+		http.Error(w, "subtitle track not found", http.StatusNotFound)
+		return
+	}
+	if !subsync.Syncable(st) {
+		http.Error(w, subsync.ErrNotSyncable.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	job, ctx := h.syncs.Start(st.ID)
+
+	go func(subtitleID int64, jobID string) {
+		res, err := h.syncer.Sync(ctx, subtitleID)
+		if err != nil {
+			h.syncs.Fail(jobID, err)
+			return
+		}
+		h.syncs.Finish(jobID, res)
+	}(st.ID, job.ID)
+
+	writeJSON(w, map[string]any{
+		"job_id": job.ID,
+		"status": job.Status,
+	})
+}
+
+func (h *SubtitlesHandler) GetSyncJob(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "job_id")
+
+	job, ok := h.syncs.Get(jobID)
+	if !ok {
+		http.Error(w, "sync job not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, job)
+}
+
+// CancelSyncJob stops a running sync; the job shows as failed once its
+// ffmpeg has been killed.
+func (h *SubtitlesHandler) CancelSyncJob(w http.ResponseWriter, r *http.Request) {
+	jobID := chi.URLParam(r, "job_id")
+
+	if _, ok := h.syncs.Get(jobID); !ok {
+		http.Error(w, "sync job not found", http.StatusNotFound)
+		return
+	}
+	if !h.syncs.Cancel(jobID) {
+		http.Error(w, "sync job is not running", http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// SearchSubtitles asks the configured providers for subtitles matching a
+// file's fingerprint and TMDB IDs.
+func (h *SubtitlesHandler) SearchSubtitles(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	mediaFileID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid media file id", http.StatusBadRequest)
+		return
+	}
+
+	if mf, err := h.store.GetMediaFile(mediaFileID); err != nil || mf == nil {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+
+	candidates, err := h.providers.Search(r.Context(), mediaFileID)
+	if errors.Is(err, subprovider.ErrNoProviders) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if errors.Is(err, subprovider.ErrQuota) {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		log.Printf("file %d: subtitle search failed: %v", mediaFileID, err)
+		http.Error(w, "subtitle search failed", http.StatusBadGateway)
+		return
+	}
+
+	if candidates == nil {
+		candidates = []subprovider.Candidate{}
+	}
+	writeJSON(w, candidates)
+}
+
+type DownloadSubtitleRequest struct {
+	Provider string `json:"provider"`
+	ID       string `json:"id"`
+}
+
+// DownloadSubtitle fetches a candidate from the last search into the
+// subtitle cache and registers it as a DOWNLOADED track.
+func (h *SubtitlesHandler) DownloadSubtitle(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	mediaFileID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid media file id", http.StatusBadRequest)
+		return
+	}
+
+	var req DownloadSubtitleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Provider == "" || req.ID == "" {
+		http.Error(w, "provider and id are required", http.StatusBadRequest)
+		return
+	}
+
+	st, err := h.providers.Download(r.Context(), mediaFileID, req.Provider, req.ID)
+	if errors.Is(err, subprovider.ErrNotFound) {
+		http.Error(w, "unknown candidate, search again", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, subprovider.ErrQuota) {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		log.Printf("file %d: subtitle download failed: %v", mediaFileID, err)
+		http.Error(w, "subtitle download failed", http.StatusBadGateway)
+		return
+	}
+
+	streamURL := "/api/subtitles/" + strconv.FormatInt(st.ID, 10) + "/stream"
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, dto.NewSubtitleTrack(st, streamURL))
+}
+
+// subtitleContentType maps subtitle extensions that mime.TypeByExtension
+// does not know.
+func subtitleContentType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".srt":
+		return "application/x-subrip; charset=utf-8"
+	case ".ass", ".ssa":
+		return "text/x-ssa; charset=utf-8"
+	case ".vtt":
+		return "text/vtt; charset=utf-8"
+	case ".sup":
+		return "application/octet-stream"
+	default:
+		return ""
+	}
+}
@@ -2,8 +2,12 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+
+	"github.com/bastianvv/vio/internal/store"
 )
 
 type apiError struct {
@@ -22,3 +26,92 @@ func writeError(w http.ResponseWriter, status int, err error) {
 		Error: err.Error(),
 	})
 }
+
+// parseListQuery reads the paging, sorting and filtering parameters list
+// endpoints share:
+//
+//	limit, offset          window; no limit returns everything
+//	sort, order            sort key and asc|desc
+//	library_id             library
+//	year_min, year_max     year range
+//	resolution, codec      e.g. 1080p, hevc
+//	has_tmdb, missing      true|false
+func parseListQuery(r *http.Request) (store.ListFilter, store.Page, error) {
+	var (
+		f   store.ListFilter
+		p   store.Page
+		err error
+	)
+	q := r.URL.Query()
+
+	ints := []struct {
+		name string
+		dst  *int
+	}{
+		{"limit", &p.Limit},
+		{"offset", &p.Offset},
+		{"year_min", &f.YearMin},
+		{"year_max", &f.YearMax},
+	}
+	for _, n := range ints {
+		if v := q.Get(n.name); v != "" {
+			if *n.dst, err = strconv.Atoi(v); err != nil || *n.dst < 0 {
+				return f, p, fmt.Errorf("invalid %s", n.name)
+			}
+		}
+	}
+
+	if v := q.Get("library_id"); v != "" {
+		if f.LibraryID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return f, p, errors.New("invalid library_id")
+		}
+	}
+
+	bools := []struct {
+		name string
+		dst  **bool
+	}{
+		{"has_tmdb", &f.HasTMDB},
+		{"missing", &f.Missing},
+	}
+	for _, n := range bools {
+		if v := q.Get(n.name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return f, p, fmt.Errorf("invalid %s", n.name)
+			}
+			*n.dst = &b
+		}
+	}
+
+	f.Resolution = q.Get("resolution")
+	f.Codec = q.Get("codec")
+	p.Sort = store.SortKey(q.Get("sort"))
+
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		p.Desc = true
+	default:
+		return f, p, errors.New("invalid order")
+	}
+
+	return f, p, nil
+}
+
+// writeList writes one page of a listing as a JSON array, with the size of
+// the whole listing in X-Total-Count.
+func writeList(w http.ResponseWriter, v any, total int) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	writeJSON(w, v)
+}
+
+// listError answers a failed Query* call: an unsupported sort is the
+// client's mistake.
+func listError(w http.ResponseWriter, err error, msg string) {
+	if errors.Is(err, store.ErrInvalidSort) {
+		http.Error(w, "invalid sort", http.StatusBadRequest)
+		return
+	}
+	http.Error(w, msg, http.StatusInternalServerError)
+}
@@ -7,32 +7,59 @@ import (
 
 	"github.com/bastianvv/vio/internal/media"
 	"github.com/bastianvv/vio/internal/metadata"
+	"github.com/bastianvv/vio/internal/purge"
 	"github.com/bastianvv/vio/internal/scan"
 	"github.com/bastianvv/vio/internal/store"
+	"github.com/bastianvv/vio/internal/subprovider"
+	"github.com/bastianvv/vio/internal/subsync"
 )
 
-func NewRouter(s store.Store, enricher metadata.Enricher, imageBaseDir string) http.Handler {
+func NewRouter(
+	s store.Store,
+	enricher metadata.Enricher,
+	subtitleProviders *subprovider.Manager,
+	scanner media.Scanner,
+	imageBaseDir, subtitleCacheDir string,
+) http.Handler {
 	r := chi.NewRouter()
 
 	// Initialize split handlers
-	scanner := media.NewScanner(s)
 	scans := scan.NewRegistry()
+	subtitleCache := media.NewSubtitleCache(subtitleCacheDir)
+	syncs := subsync.NewService(s, subtitleCacheDir)
 	seriesHandler := NewSeriesHandler(s, enricher, imageBaseDir)
 	seasonsHandler := NewSeasonsHandler(s, imageBaseDir)
 	episodesHandler := NewEpisodesHandler(s, imageBaseDir)
 	moviesHandler := NewMoviesHandler(s, enricher, imageBaseDir)
-	librariesHandler := NewLibrariesHandler(s, scanner, scans)
+	librariesHandler := NewLibrariesHandler(
+		s,
+		scanner,
+		scans,
+		purge.NewService(s, imageBaseDir, subtitleCache, syncs, subtitleProviders),
+		purge.NewRegistry(),
+		syncs,
+	)
 	filesHandler := NewFilesHandler(s)
-	subtitlesHandler := NewSubtitlesHandler(s)
+	subtitlesHandler := NewSubtitlesHandler(
+		s,
+		subtitleCache,
+		syncs,
+		subsync.NewRegistry(),
+		subtitleProviders,
+	)
 	imageHandler := NewImageHandler(imageBaseDir)
+	searchHandler := NewSearchHandler(s)
 
 	// ---- Libraries ----
 	r.Get("/api/libraries", librariesHandler.ListLibraries)
 	r.Post("/api/libraries", librariesHandler.CreateLibrary)
 	r.Get("/api/libraries/{id}", librariesHandler.GetLibrary)
 	r.Put("/api/libraries/{id}", librariesHandler.UpdateLibrary)
+	r.Delete("/api/libraries/{id}", librariesHandler.DeleteLibrary)
 	r.Post("/api/libraries/{id}/scan", librariesHandler.ScanLibrary)
 	r.Post("/api/libraries/{id}/rescan", librariesHandler.RescanLibrary)
+	r.Post("/api/libraries/{id}/relocate", librariesHandler.RelocateLibrary)
+	r.Get("/api/libraries/{id}/problems", librariesHandler.ListProblems)
 
 	// ---- Movies ----
 	r.Get("/api/movies", moviesHandler.ListMovies)
@@ -52,19 +79,32 @@ func NewRouter(s store.Store, enricher metadata.Enricher, imageBaseDir string) h
 
 	// ---- Episodes ----
 	r.Get("/api/episodes/{id}", episodesHandler.GetEpisode)
+	r.Put("/api/episodes/{id}", episodesHandler.UpdateEpisode)
 	r.Get("/api/episodes/{id}/files", episodesHandler.ListEpisodeFiles)
 
 	// ---- Files ----
+	r.Get("/api/files", filesHandler.ListFiles)
 	r.Get("/api/files/{id}", filesHandler.GetFile)
 	r.Get("/api/files/{id}/stream", filesHandler.StreamFile)
 	r.Get("/api/files/{id}/audio-tracks", filesHandler.ListAudioTracks)
+	r.Get("/api/files/{id}/chapters", filesHandler.ListChapters)
 
 	// --- Subtitles ---
 	r.Get("/api/files/{id}/subtitles", subtitlesHandler.ListSubtitleTracks)
+	r.Post("/api/files/{id}/subtitles/search", subtitlesHandler.SearchSubtitles)
+	r.Post("/api/files/{id}/subtitles/download", subtitlesHandler.DownloadSubtitle)
+	r.Put("/api/subtitles/{id}", subtitlesHandler.UpdateSubtitleTrack)
 	r.Get("/api/subtitles/{id}/stream", subtitlesHandler.StreamSubtitleTrack)
+	r.Post("/api/subtitles/{id}/sync", subtitlesHandler.SyncSubtitleTrack)
+	r.Get("/api/subtitle-syncs/{job_id}", subtitlesHandler.GetSyncJob)
+	r.Delete("/api/subtitle-syncs/{job_id}", subtitlesHandler.CancelSyncJob)
 
 	// --- Scanner ---
 	r.Get("/api/scans/{job_id}", librariesHandler.GetScanJob)
+	r.Get("/api/library-deletions/{job_id}", librariesHandler.GetDeletionJob)
+
+	// --- Search ---
+	r.Get("/api/search", searchHandler.Search)
 
 	// --- Images ---
 	r.Get("/api/images/{entity}/{id}/{kind}", imageHandler.ServeImage)
@@ -0,0 +1,300 @@
+package language
+
+import "strings"
+
+// Language is one entry of the ISO 639 table.
+type Language struct {
+	Code  string // ISO 639-1, used as the BCP-47 primary subtag
+	Alpha string // ISO 639-2/B (bibliographic)
+	Term  string // ISO 639-2/T (terminology) when it differs from Alpha
+	Name  string // English display name
+}
+
+// Kept to the languages that show up in real libraries; anything else still
+// round-trips through Normalize as a lower-cased code.
+var languages = []Language{
+	{"af", "afr", "", "Afrikaans"},
+	{"am", "amh", "", "Amharic"},
+	{"ar", "ara", "", "Arabic"},
+	{"az", "aze", "", "Azerbaijani"},
+	{"be", "bel", "", "Belarusian"},
+	{"bg", "bul", "", "Bulgarian"},
+	{"bn", "ben", "", "Bengali"},
+	{"bs", "bos", "", "Bosnian"},
+	{"ca", "cat", "", "Catalan"},
+	{"cs", "cze", "ces", "Czech"},
+	{"cy", "wel", "cym", "Welsh"},
+	{"da", "dan", "", "Danish"},
+	{"de", "ger", "deu", "German"},
+	{"el", "gre", "ell", "Greek"},
+	{"en", "eng", "", "English"},
+	{"eo", "epo", "", "Esperanto"},
+	{"es", "spa", "", "Spanish"},
+	{"et", "est", "", "Estonian"},
+	{"eu", "baq", "eus", "Basque"},
+	{"fa", "per", "fas", "Persian"},
+	{"fi", "fin", "", "Finnish"},
+	{"fil", "fil", "", "Filipino"},
+	{"fo", "fao", "", "Faroese"},
+	{"fr", "fre", "fra", "French"},
+	{"ga", "gle", "", "Irish"},
+	{"gl", "glg", "", "Galician"},
+	{"gu", "guj", "", "Gujarati"},
+	{"he", "heb", "", "Hebrew"},
+	{"hi", "hin", "", "Hindi"},
+	{"hr", "hrv", "", "Croatian"},
+	{"hu", "hun", "", "Hungarian"},
+	{"hy", "arm", "hye", "Armenian"},
+	{"id", "ind", "", "Indonesian"},
+	{"is", "ice", "isl", "Icelandic"},
+	{"it", "ita", "", "Italian"},
+	{"ja", "jpn", "", "Japanese"},
+	{"ka", "geo", "kat", "Georgian"},
+	{"kk", "kaz", "", "Kazakh"},
+	{"km", "khm", "", "Khmer"},
+	{"kn", "kan", "", "Kannada"},
+	{"ko", "kor", "", "Korean"},
+	{"ku", "kur", "", "Kurdish"},
+	{"la", "lat", "", "Latin"},
+	{"lb", "ltz", "", "Luxembourgish"},
+	{"lo", "lao", "", "Lao"},
+	{"lt", "lit", "", "Lithuanian"},
+	{"lv", "lav", "", "Latvian"},
+	{"mk", "mac", "mkd", "Macedonian"},
+	{"ml", "mal", "", "Malayalam"},
+	{"mn", "mon", "", "Mongolian"},
+	{"mr", "mar", "", "Marathi"},
+	{"ms", "may", "msa", "Malay"},
+	{"mt", "mlt", "", "Maltese"},
+	{"my", "bur", "mya", "Burmese"},
+	{"nb", "nob", "", "Norwegian Bokmål"},
+	{"ne", "nep", "", "Nepali"},
+	{"nl", "dut", "nld", "Dutch"},
+	{"nn", "nno", "", "Norwegian Nynorsk"},
+	{"no", "nor", "", "Norwegian"},
+	{"pa", "pan", "", "Punjabi"},
+	{"pl", "pol", "", "Polish"},
+	{"ps", "pus", "", "Pashto"},
+	{"pt", "por", "", "Portuguese"},
+	{"ro", "rum", "ron", "Romanian"},
+	{"ru", "rus", "", "Russian"},
+	{"si", "sin", "", "Sinhala"},
+	{"sk", "slo", "slk", "Slovak"},
+	{"sl", "slv", "", "Slovenian"},
+	{"so", "som", "", "Somali"},
+	{"sq", "alb", "sqi", "Albanian"},
+	{"sr", "srp", "", "Serbian"},
+	{"sv", "swe", "", "Swedish"},
+	{"sw", "swa", "", "Swahili"},
+	{"ta", "tam", "", "Tamil"},
+	{"te", "tel", "", "Telugu"},
+	{"th", "tha", "", "Thai"},
+	{"tl", "tgl", "", "Tagalog"},
+	{"tr", "tur", "", "Turkish"},
+	{"uk", "ukr", "", "Ukrainian"},
+	{"ur", "urd", "", "Urdu"},
+	{"uz", "uzb", "", "Uzbek"},
+	{"vi", "vie", "", "Vietnamese"},
+	{"yi", "yid", "", "Yiddish"},
+	{"zh", "chi", "zho", "Chinese"},
+	{"zu", "zul", "", "Zulu"},
+}
+
+// Non-code spellings seen in sidecar names and stream tags. Values are full
+// BCP-47 tags.
+var aliases = map[string]string{
+	"brazilian":  "pt-BR",
+	"pob":        "pt-BR",
+	"pb":         "pt-BR",
+	"latino":     "es-419",
+	"chs":        "zh-Hans",
+	"cht":        "zh-Hant",
+	"iw":         "he",
+	"español":    "es",
+	"castellano": "es",
+	"deutsch":    "de",
+	"français":   "fr",
+	"francais":   "fr",
+	"italiano":   "it",
+	"português":  "pt",
+	"portugues":  "pt",
+	"nederlands": "nl",
+	"svenska":    "sv",
+	"norsk":      "no",
+	"dansk":      "da",
+	"suomi":      "fi",
+	"polski":     "pl",
+	"русский":    "ru",
+	"日本語":        "ja",
+	"한국어":        "ko",
+	"中文":         "zh",
+}
+
+var regionNames = map[string]string{
+	"BR":   "Brazil",
+	"PT":   "Portugal",
+	"US":   "United States",
+	"GB":   "United Kingdom",
+	"CA":   "Canada",
+	"AU":   "Australia",
+	"ES":   "Spain",
+	"MX":   "Mexico",
+	"AR":   "Argentina",
+	"419":  "Latin America",
+	"FR":   "France",
+	"BE":   "Belgium",
+	"CH":   "Switzerland",
+	"AT":   "Austria",
+	"CN":   "China",
+	"TW":   "Taiwan",
+	"HK":   "Hong Kong",
+	"Hans": "Simplified",
+	"Hant": "Traditional",
+}
+
+// Codes that mean "no particular language".
+var undetermined = map[string]bool{
+	"und": true, "unk": true, "mis": true, "zxx": true, "xx": true,
+	"undetermined": true, "unknown": true, "none": true,
+}
+
+var (
+	byCode = map[string]*Language{}
+	byName = map[string]*Language{}
+)
+
+func init() {
+	for i := range languages {
+		l := &languages[i]
+		byCode[l.Code] = l
+		byCode[l.Alpha] = l
+		if l.Term != "" {
+			byCode[l.Term] = l
+		}
+		byName[strings.ToLower(l.Name)] = l
+	}
+}
+
+// Lookup normalizes a language code or name to a BCP-47 tag ("eng" → "en",
+// "pt_br" → "pt-BR", "English" → "en"). It reports false for anything that
+// is not a known language, so it can be used to tell languages apart from
+// other tokens in a file name.
+func Lookup(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.ReplaceAll(s, "_", "-")
+	if s == "" || undetermined[s] {
+		return "", false
+	}
+
+	if tag, ok := aliases[s]; ok {
+		return tag, true
+	}
+	if l, ok := byName[s]; ok {
+		return l.Code, true
+	}
+
+	parts := strings.Split(s, "-")
+
+	primary, ok := aliases[parts[0]]
+	if !ok {
+		l, found := byCode[parts[0]]
+		if !found {
+			return "", false
+		}
+		primary = l.Code
+	}
+
+	tag := primary
+	for _, p := range parts[1:] {
+		switch {
+		case len(p) == 2 && isAlpha(p):
+			tag += "-" + strings.ToUpper(p)
+		case len(p) == 3 && isDigit(p):
+			tag += "-" + p
+		case len(p) == 4 && isAlpha(p):
+			tag += "-" + strings.ToUpper(p[:1]) + p[1:]
+		default:
+			return "", false
+		}
+	}
+
+	return tag, true
+}
+
+// Normalize is the lenient form of Lookup used for stream tags: unknown but
+// code-shaped values are kept lower-cased, undetermined ones become "".
+func Normalize(s string) string {
+	if tag, ok := Lookup(s); ok {
+		return tag
+	}
+
+	s = strings.ToLower(strings.TrimSpace(s))
+	if undetermined[s] || !isAlpha(s) || len(s) < 2 || len(s) > 3 {
+		return ""
+	}
+	return s
+}
+
+// Name returns the English display name of a BCP-47 tag, e.g. "pt-BR" →
+// "Portuguese (Brazil)". Unknown tags are returned unchanged.
+func Name(tag string) string {
+	if tag == "" {
+		return ""
+	}
+
+	parts := strings.Split(tag, "-")
+	l, ok := byCode[strings.ToLower(parts[0])]
+	if !ok {
+		return tag
+	}
+	if len(parts) == 1 {
+		return l.Name
+	}
+
+	var qualifiers []string
+	for _, p := range parts[1:] {
+		if n, ok := regionNames[p]; ok {
+			qualifiers = append(qualifiers, n)
+		} else {
+			qualifiers = append(qualifiers, p)
+		}
+	}
+	return l.Name + " (" + strings.Join(qualifiers, ", ") + ")"
+}
+
+// Match reports whether a stored tag satisfies a filter. A bare filter
+// ("pt", "por", "Portuguese") matches every region of that language; a
+// filter with a region ("pt-BR") only matches that region. Both sides are
+// normalized, so tags stored before normalization still match.
+func Match(tag, filter string) bool {
+	tag, want := Normalize(tag), Normalize(filter)
+	if want == "" || tag == "" {
+		return false
+	}
+	if strings.EqualFold(tag, want) {
+		return true
+	}
+	if strings.Contains(want, "-") {
+		return false
+	}
+	primary, _, _ := strings.Cut(tag, "-")
+	return strings.EqualFold(primary, want)
+}
+
+func isAlpha(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isDigit(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
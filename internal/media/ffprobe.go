@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"os/exec"
+	"strconv"
+	"strings"
 )
 
 // FFProbeOutput models the subset of ffprobe JSON we care about.
@@ -12,53 +14,200 @@ type FFProbeOutput struct {
 		Filename string `json:"filename"`
 		Duration string `json:"duration"` // seconds as string
 		Size     string `json:"size"`     // bytes as string
+		BitRate  string `json:"bit_rate"` // bits/s as string
 	} `json:"format"`
+	Chapters []struct {
+		ID        int64  `json:"id"`
+		StartTime string `json:"start_time"` // seconds as string
+		EndTime   string `json:"end_time"`   // seconds as string
+		Tags      struct {
+			Title string `json:"title"`
+		} `json:"tags"`
+	} `json:"chapters"`
 	Streams []struct {
 		Index     int    `json:"index"`
 		CodecType string `json:"codec_type"` // "video", "audio", "subtitle"
 		CodecName string `json:"codec_name"`
+		CodecTag  string `json:"codec_tag_string"`
+		Profile   string `json:"profile"`
+		BitRate   string `json:"bit_rate"` // bits/s as string
 
 		// Video
-		Width  int `json:"width"`
-		Height int `json:"height"`
+		Width            int    `json:"width"`
+		Height           int    `json:"height"`
+		Level            int    `json:"level"`
+		PixFmt           string `json:"pix_fmt"`
+		BitsPerRawSample string `json:"bits_per_raw_sample"`
+		RFrameRate       string `json:"r_frame_rate"`
+		AvgFrameRate     string `json:"avg_frame_rate"`
+		FieldOrder       string `json:"field_order"`
+		ColorRange       string `json:"color_range"`
+		ColorSpace       string `json:"color_space"`
+		ColorTransfer    string `json:"color_transfer"`
+		ColorPrimaries   string `json:"color_primaries"`
+		SideDataList     []struct {
+			SideDataType string `json:"side_data_type"`
+		} `json:"side_data_list"`
 
 		// Audio
 		Channels int `json:"channels"`
 
-		// Subtitles
+		// Audio and subtitles
 		Tags struct {
 			Language string `json:"language"`
 			Title    string `json:"title"`
 		} `json:"tags"`
 
 		Disposition struct {
-			Default int `json:"default"`
-			Forced  int `json:"forced"`
+			Default         int `json:"default"`
+			Forced          int `json:"forced"`
+			AttachedPic     int `json:"attached_pic"`
+			Comment         int `json:"comment"`
+			HearingImpaired int `json:"hearing_impaired"`
+			VisualImpaired  int `json:"visual_impaired"`
+			Dub             int `json:"dub"`
+			Original        int `json:"original"`
 		} `json:"disposition"`
 	} `json:"streams"`
 }
 
+// ProbeError is returned when ffprobe cannot read a file. Stderr holds
+// ffprobe's own explanation, e.g. "Invalid data found when processing input".
+type ProbeError struct {
+	Err    error
+	Stderr string
+}
+
+func (e *ProbeError) Error() string {
+	if e.Stderr == "" {
+		return "ffprobe: " + e.Err.Error()
+	}
+	return "ffprobe: " + e.Err.Error() + ": " + e.Stderr
+}
+
+func (e *ProbeError) Unwrap() error {
+	return e.Err
+}
+
 // RunFFProbe executes ffprobe and returns parsed JSON.
 func RunFFProbe(path string) (*FFProbeOutput, error) {
 	cmd := exec.Command("ffprobe",
-		"-v", "quiet",
+		"-v", "error",
 		"-print_format", "json",
 		"-show_format",
 		"-show_streams",
+		"-show_chapters",
 		path,
 	)
 
-	var out bytes.Buffer
+	var out, stderr bytes.Buffer
 	cmd.Stdout = &out
+	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		return nil, err
+		return nil, &ProbeError{Err: err, Stderr: strings.TrimSpace(stderr.String())}
 	}
 
 	var result FFProbeOutput
 	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
-		return nil, err
+		return nil, &ProbeError{Err: err, Stderr: strings.TrimSpace(stderr.String())}
 	}
 
 	return &result, nil
 }
+
+// parseBitRate turns ffprobe's "bit_rate" string into bits per second.
+func parseBitRate(s string) int64 {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// parseFrameRate turns ffprobe's rational "24000/1001" into 23.976.
+func parseFrameRate(s string) float64 {
+	num, den, ok := strings.Cut(s, "/")
+	if !ok {
+		f, _ := strconv.ParseFloat(s, 64)
+		return f
+	}
+	n, err1 := strconv.ParseFloat(num, 64)
+	d, err2 := strconv.ParseFloat(den, 64)
+	if err1 != nil || err2 != nil || d == 0 {
+		return 0
+	}
+	return float64(int(n/d*1000+0.5)) / 1000
+}
+
+// bitDepth prefers bits_per_raw_sample and falls back to the pixel format
+// (yuv420p10le → 10).
+func bitDepth(bitsPerRawSample, pixFmt string) int {
+	if n, err := strconv.Atoi(bitsPerRawSample); err == nil && n > 0 {
+		return n
+	}
+	switch {
+	case pixFmt == "":
+		return 0
+	case strings.Contains(pixFmt, "p10"), strings.HasSuffix(pixFmt, "10le"), strings.HasSuffix(pixFmt, "10be"):
+		return 10
+	case strings.Contains(pixFmt, "p12"), strings.HasSuffix(pixFmt, "12le"), strings.HasSuffix(pixFmt, "12be"):
+		return 12
+	default:
+		return 8
+	}
+}
+
+// scanType maps ffprobe's field_order to progressive/interlaced.
+func scanType(fieldOrder string) string {
+	switch fieldOrder {
+	case "progressive":
+		return "progressive"
+	case "tt", "bb", "tb", "bt":
+		return "interlaced"
+	default:
+		return ""
+	}
+}
+
+// hdrFormats detects HDR flavours from color metadata and side data, using
+// the same vocabulary as release names: DV, HDR10+, HDR10, HLG.
+func hdrFormats(codecTag, colorTransfer string, sideData []string) []string {
+	var out []string
+
+	dv := codecTag == "dvhe" || codecTag == "dvh1" || codecTag == "dav1"
+	hdr10plus := false
+	for _, sd := range sideData {
+		switch {
+		case strings.Contains(sd, "DOVI"), strings.Contains(sd, "Dolby Vision"):
+			dv = true
+		case strings.Contains(sd, "SMPTE2094-40"), strings.Contains(sd, "HDR10+"):
+			hdr10plus = true
+		}
+	}
+
+	if dv {
+		out = append(out, "DV")
+	}
+	switch colorTransfer {
+	case "smpte2084":
+		if hdr10plus {
+			out = append(out, "HDR10+")
+		} else {
+			out = append(out, "HDR10")
+		}
+	case "arib-std-b67":
+		out = append(out, "HLG")
+	}
+
+	return out
+}
+
+// parseSecondsMs turns ffprobe's "12.345000" seconds into milliseconds.
+func parseSecondsMs(s string) int64 {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(f*1000 + 0.5)
+}
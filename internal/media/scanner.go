@@ -1,14 +1,20 @@
 package media
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/bastianvv/vio/internal/domain"
+	"github.com/bastianvv/vio/internal/language"
+	"github.com/bastianvv/vio/internal/release"
 	"github.com/bastianvv/vio/internal/store"
 	"github.com/bastianvv/vio/internal/util"
 )
@@ -35,6 +41,14 @@ type ScanResult struct {
 	SeriesAdded   int
 	EpisodesAdded int
 
+	// Roots that could not be read; their files were left alone.
+	OfflineRoots []string
+
+	// Subtitle tracks that vanished and were deleted, taking the tracks
+	// derived from them along by cascade. Whatever was cached for them is
+	// the caller's to evict.
+	RemovedSubtitles []int64
+
 	Errors []error
 }
 
@@ -49,12 +63,29 @@ type Scanner interface {
 	ScanLibrary(lib *domain.Library, mode ScanMode) (*ScanResult, error)
 }
 
+// Scan writes are committed in batches: every DefaultBatchSize files or
+// DefaultBatchInterval, whichever comes first.
+const (
+	DefaultBatchSize     = 200
+	DefaultBatchInterval = 2 * time.Second
+)
+
 type FSScanner struct {
-	store store.Store
+	store         store.Store
+	batchSize     int
+	batchInterval time.Duration
 }
 
-func NewScanner(s store.Store) *FSScanner {
-	return &FSScanner{store: s}
+// NewScanner commits scan writes every batchSize files or batchInterval.
+// Zero values use the defaults.
+func NewScanner(s store.Store, batchSize int, batchInterval time.Duration) *FSScanner {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if batchInterval <= 0 {
+		batchInterval = DefaultBatchInterval
+	}
+	return &FSScanner{store: s, batchSize: batchSize, batchInterval: batchInterval}
 }
 
 // Recognized video extensions (MVP).
@@ -64,8 +95,9 @@ var videoExt = map[string]bool{
 	".avi": true,
 }
 
-// ScanLibrary walks the filesystem starting from lib.Path and
-// processes all supported video files.
+// ScanLibrary walks the filesystem under each of lib's roots and processes
+// all supported video files. A root that is offline is skipped, and its
+// files keep their state.
 func (s *FSScanner) ScanLibrary(lib *domain.Library, mode ScanMode) (*ScanResult, error) {
 
 	result := &ScanResult{
@@ -74,38 +106,53 @@ func (s *FSScanner) ScanLibrary(lib *domain.Library, mode ScanMode) (*ScanResult
 
 	scanStartedAt := time.Now().UTC()
 
-	walkErr := filepath.WalkDir(lib.Path, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
+	roots := lib.Roots
+	if len(roots) == 0 {
+		roots = []string{lib.Path}
+	}
+
+	var (
+		paths   []string
+		walkErr error
+	)
+	for _, root := range roots {
+		if err := rootAvailable(root); err != nil {
+			result.OfflineRoots = append(result.OfflineRoots, root)
 			result.Errors = append(result.Errors, err)
-			return nil
-		}
-		if d.IsDir() {
-			return nil
+			continue
 		}
 
-		ext := strings.ToLower(filepath.Ext(d.Name()))
-		if !videoExt[ext] {
-			return nil
-		}
+		err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				result.Errors = append(result.Errors, err)
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
 
-		result.FilesScanned++
+			ext := strings.ToLower(filepath.Ext(d.Name()))
+			if !videoExt[ext] {
+				return nil
+			}
 
-		err = s.store.WithTx(func(tx store.Store) error {
-			return s.processVideoFileTx(
-				tx,
-				lib,
-				mode,
-				path,
-				scanStartedAt,
-				result,
-			)
+			paths = append(paths, path)
+			return nil
 		})
+		walkErr = errors.Join(walkErr, err)
+	}
+
+	result.FilesScanned = len(paths)
+
+	for len(paths) > 0 {
+		n, err := s.scanBatch(lib, mode, paths, scanStartedAt, result)
 		if err != nil {
-			result.Errors = append(result.Errors, err)
+			// The batch's files were not marked seen; the cleanup pass
+			// would take them for missing.
+			return result, err
 		}
-
-		return nil
-	})
+		paths = paths[n:]
+	}
 
 	if result.FilesScanned == 0 {
 		return result, nil
@@ -113,7 +160,7 @@ func (s *FSScanner) ScanLibrary(lib *domain.Library, mode ScanMode) (*ScanResult
 
 	// ONE cleanup pass, ONE transaction
 	_ = s.store.WithTx(func(tx store.Store) error {
-		if _, err := tx.MarkMissingMediaFiles(lib.ID, scanStartedAt); err != nil {
+		if _, err := tx.MarkMissingMediaFiles(lib.ID, scanStartedAt, result.OfflineRoots...); err != nil {
 			return err
 		}
 		if _, err := tx.UnlinkMissingMediaFiles(lib.ID); err != nil {
@@ -134,51 +181,181 @@ func (s *FSScanner) ScanLibrary(lib *domain.Library, mode ScanMode) (*ScanResult
 	return result, walkErr
 }
 
-func (s *FSScanner) processVideoFileTx(
-	tx store.Store,
+// rootAvailable checks that a library root can be scanned: it must be a
+// directory that can be listed. An empty one is scanned like any other, so
+// the files of a disk whose mount point is left empty go missing, and come
+// back on the first scan after it is mounted again.
+func rootAvailable(root string) error {
+	f, err := os.Open(root)
+	if err != nil {
+		return fmt.Errorf("library root %s is offline: %w", root, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := f.Readdirnames(1); err != nil && err != io.EOF {
+		return fmt.Errorf("library root %s is offline: %w", root, err)
+	}
+	return nil
+}
+
+// scanBatch processes files from the front of paths until the batch is
+// full or its time is up, and returns how many it took. Files are hashed
+// and probed first; only then is the transaction opened that stores the
+// results, so the slow part doesn't hold the database writer. Each file is
+// stored in its own savepoint, so a file that fails is rolled back without
+// the rest of the batch.
+func (s *FSScanner) scanBatch(
 	lib *domain.Library,
 	mode ScanMode,
-	path string,
+	paths []string,
 	scanStartedAt time.Time,
 	result *ScanResult,
-) error {
+) (int, error) {
+
+	deadline := time.Now().Add(s.batchInterval)
+
+	var files []*probedFile
+	for len(files) < len(paths) && len(files) < s.batchSize {
+		files = append(files, s.probeFile(lib, mode, paths[len(files)], scanStartedAt))
+		if !time.Now().Before(deadline) {
+			break
+		}
+	}
+	n := len(files)
+
+	// Counts only stand once the batch commits.
+	var added ScanResult
+
+	err := s.store.WithTx(func(tx store.Store) error {
+		for _, pf := range files {
+			if pf.err != nil {
+				result.Errors = append(result.Errors, pf.err)
+				continue
+			}
+
+			file := &ScanResult{}
+			err := tx.WithTx(func(ftx store.Store) error {
+				return s.storeVideoFileTx(ftx, lib, pf, scanStartedAt, file)
+			})
+			result.Errors = append(result.Errors, file.Errors...)
+			if err != nil {
+				result.Errors = append(result.Errors, err)
+			} else {
+				added.MoviesAdded += file.MoviesAdded
+				added.SeriesAdded += file.SeriesAdded
+				added.EpisodesAdded += file.EpisodesAdded
+				added.RemovedSubtitles = append(added.RemovedSubtitles, file.RemovedSubtitles...)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return n, fmt.Errorf("scan batch of %d files: %w", n, err)
+	}
+
+	result.MoviesAdded += added.MoviesAdded
+	result.SeriesAdded += added.SeriesAdded
+	result.EpisodesAdded += added.EpisodesAdded
+	result.RemovedSubtitles = append(result.RemovedSubtitles, added.RemovedSubtitles...)
+	return n, nil
+}
+
+// probedFile is what a scan learned about a file from the disk, before
+// anything is written.
+type probedFile struct {
+	path     string
+	existing *domain.MediaFile
+
+	// unchanged files are only marked seen.
+	unchanged bool
+
+	size     int64
+	hash     string
+	ffdata   *FFProbeOutput
+	probeErr error
+
+	// err is set when the file could not be looked at at all.
+	err error
+}
+
+// probeFile hashes and probes path, unless the mode and what is stored
+// about it say it can be skipped.
+func (s *FSScanner) probeFile(
+	lib *domain.Library,
+	mode ScanMode,
+	path string,
+	scanStartedAt time.Time,
+) *probedFile {
+
+	pf := &probedFile{path: path}
 
 	// Check existing media file
-	existingMF, err := tx.GetMediaFileByPath(path)
+	existingMF, err := s.store.GetMediaFileByPath(path)
 	if err != nil {
-		return err
+		pf.err = err
+		return pf
 	}
+	pf.existing = existingMF
 
-	if existingMF != nil && mode == ScanModeIncremental {
-		return tx.MarkMediaFileSeen(existingMF.ID, scanStartedAt)
+	// Unreadable files are retried on their own schedule, in both modes.
+	if existingMF != nil && existingMF.ProbeStatus == domain.ProbeStatusError {
+		if !probeRetryDue(existingMF, scanStartedAt) {
+			pf.unchanged = true
+			return pf
+		}
+	} else if existingMF != nil && mode == ScanModeIncremental {
+		pf.unchanged = true
+		return pf
 	}
 
 	info, err := os.Stat(path)
 	if err != nil {
-		return err
+		pf.err = err
+		return pf
 	}
+	pf.size = info.Size()
 
-	var hash string
-	if mode == ScanModeRescan && existingMF != nil {
-		hash, err = util.HashFile(path)
+	if mode == ScanModeRescan && existingMF != nil && existingMF.ProbeStatus != domain.ProbeStatusError {
+		pf.hash, err = util.HashFile(path)
 		if err != nil {
-			return err
+			pf.err = err
+			return pf
 		}
-		if existingMF.Hash == hash {
-			return tx.MarkMediaFileSeen(existingMF.ID, scanStartedAt)
+		if existingMF.Hash == pf.hash {
+			pf.unchanged = true
+			return pf
 		}
 	}
 
-	if hash == "" {
-		hash, err = util.HashFile(path)
+	if pf.hash == "" {
+		pf.hash, err = util.HashFile(path)
 		if err != nil {
-			return err
+			pf.err = err
+			return pf
 		}
 	}
 
-	ffdata, err := RunFFProbe(path)
-	if err != nil {
-		return err
+	pf.ffdata, pf.probeErr = RunFFProbe(path)
+	return pf
+}
+
+// storeVideoFileTx writes what probeFile found: the media file, what it is
+// attached to, and its streams and tracks.
+func (s *FSScanner) storeVideoFileTx(
+	tx store.Store,
+	lib *domain.Library,
+	pf *probedFile,
+	scanStartedAt time.Time,
+	result *ScanResult,
+) error {
+
+	path, existingMF, ffdata := pf.path, pf.existing, pf.ffdata
+
+	if pf.unchanged {
+		return tx.MarkMediaFileSeen(existingMF.ID, scanStartedAt)
+	}
+	if pf.probeErr != nil {
+		return s.recordProbeErrorTx(tx, lib, existingMF, path, pf.size, pf.hash, scanStartedAt, pf.probeErr, result)
 	}
 
 	container := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
@@ -201,6 +378,9 @@ func (s *FSScanner) processVideoFileTx(
 	for _, st := range ffdata.Streams {
 		switch st.CodecType {
 		case "video":
+			if st.Disposition.AttachedPic == 1 {
+				continue // embedded cover art
+			}
 			videoCodec = st.CodecName
 			width, height = st.Width, st.Height
 		case "audio":
@@ -212,8 +392,8 @@ func (s *FSScanner) processVideoFileTx(
 	mf := &domain.MediaFile{
 		LibraryID:     lib.ID,
 		Path:          path,
-		SizeBytes:     info.Size(),
-		Hash:          hash,
+		SizeBytes:     pf.size,
+		Hash:          pf.hash,
 		LastSeenAt:    &scanStartedAt,
 		IsMissing:     false,
 		Container:     container,
@@ -223,7 +403,10 @@ func (s *FSScanner) processVideoFileTx(
 		VideoHeight:   height,
 		AudioChannels: audioChannels,
 		DurationSec:   durationSec,
+		BitRate:       parseBitRate(ffdata.Format.BitRate),
+		ProbeStatus:   domain.ProbeStatusOK,
 	}
+	applyReleaseInfo(mf, release.Parse(path))
 
 	if existingMF != nil {
 		mf.ID = existingMF.ID
@@ -257,6 +440,7 @@ func (s *FSScanner) processVideoFileTx(
 	}
 
 	now := time.Now().UTC()
+	var err error
 	if mf.ID == 0 {
 		mf.CreatedAt = now
 		err = tx.CreateMediaFile(mf)
@@ -278,11 +462,104 @@ func (s *FSScanner) processVideoFileTx(
 		}
 	}
 
-	if err := s.createAudioTracksTx(tx, mf, ffdata); err != nil {
+	if err := s.createVideoStreamsTx(tx, mf, ffdata); err != nil {
+		return err
+	}
+
+	if err := s.createChaptersTx(tx, mf, ffdata); err != nil {
 		return err
 	}
 
-	return s.createSubtitleTracksTx(tx, mf, ffdata)
+	if err := s.syncAudioTracksTx(tx, mf, ffdata); err != nil {
+		return err
+	}
+
+	return s.syncSubtitleTracksTx(tx, mf, ffdata, result)
+}
+
+// Backoff for files ffprobe could not read: an hour after the first
+// failure, doubling up to a week.
+const (
+	probeRetryBase = time.Hour
+	probeRetryMax  = 7 * 24 * time.Hour
+)
+
+func probeBackoff(attempts int) time.Duration {
+	d := probeRetryBase
+	for i := 1; i < attempts && d < probeRetryMax; i++ {
+		d *= 2
+	}
+	return min(d, probeRetryMax)
+}
+
+// probeRetryDue reports whether an unreadable file should be probed again:
+// its backoff has passed, or it changed size since the last attempt.
+func probeRetryDue(mf *domain.MediaFile, now time.Time) bool {
+	if mf.NextProbeAt == nil || !now.Before(*mf.NextProbeAt) {
+		return true
+	}
+	info, err := os.Stat(mf.Path)
+	return err == nil && info.Size() != mf.SizeBytes
+}
+
+// recordProbeErrorTx stores a file ffprobe could not read, so it shows up as
+// a library problem instead of vanishing with the scan. A previously good
+// file keeps its links; its streams are left as they were.
+func (s *FSScanner) recordProbeErrorTx(
+	tx store.Store,
+	lib *domain.Library,
+	existingMF *domain.MediaFile,
+	path string,
+	size int64,
+	hash string,
+	scanStartedAt time.Time,
+	probeErr error,
+	result *ScanResult,
+) error {
+	result.Errors = append(result.Errors, fmt.Errorf("%s: %w", path, probeErr))
+
+	mf := &domain.MediaFile{
+		LibraryID: lib.ID,
+		Path:      path,
+		Container: strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."),
+	}
+	applyReleaseInfo(mf, release.Parse(path))
+	if existingMF != nil {
+		mf = existingMF
+	}
+
+	msg := probeErr.Error()
+	var pe *ProbeError
+	if errors.As(probeErr, &pe) && pe.Stderr != "" {
+		msg = pe.Stderr
+	}
+
+	mf.SizeBytes = size
+	mf.Hash = hash
+	mf.LastSeenAt = &scanStartedAt
+	mf.ProbeStatus = domain.ProbeStatusError
+	mf.ProbeError = msg
+	mf.ProbeAttempts++
+	next := scanStartedAt.Add(probeBackoff(mf.ProbeAttempts))
+	mf.NextProbeAt = &next
+
+	if mf.ID == 0 {
+		return tx.CreateMediaFile(mf)
+	}
+	return tx.UpdateMediaFile(mf)
+}
+
+// applyReleaseInfo copies release-name hints onto the media file.
+func applyReleaseInfo(mf *domain.MediaFile, ri release.Info) {
+	mf.ReleaseResolution = ri.Resolution
+	mf.ReleaseSource = ri.Source
+	mf.ReleaseVideoCodec = ri.VideoCodec
+	mf.ReleaseAudioCodec = ri.AudioCodec
+	mf.ReleaseHDR = ri.HDRString()
+	mf.ReleaseGroup = ri.Group
+	mf.IsRemux = ri.Remux
+	mf.IsProper = ri.Proper
+	mf.IsRepack = ri.Repack
 }
 
 func (s *FSScanner) attachMovie(lib *domain.Library, mf *domain.MediaFile) (*attachResult, error) {
@@ -393,10 +670,11 @@ func (s *FSScanner) attachSeriesEpisode(
 	}
 
 	// 2) Single SxxExx
-	if m := reSxxExx.FindStringSubmatch(filename); len(m) == 3 {
-		season, _ := strconv.Atoi(m[1])
-		episode, _ := strconv.Atoi(m[2])
-		ep, ar, err := s.linkSeriesSingle(lib, season, episode, mf)
+	if loc := reSxxExx.FindStringSubmatchIndex(filename); loc != nil {
+		season, _ := strconv.Atoi(filename[loc[2]:loc[3]])
+		episode, _ := strconv.Atoi(filename[loc[4]:loc[5]])
+		title := episodeTitleFromFilename(filename[loc[1]:])
+		ep, ar, err := s.linkSeriesSingle(lib, season, episode, title, mf)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -406,10 +684,11 @@ func (s *FSScanner) attachSeriesEpisode(
 	}
 
 	// 3) 1x02
-	if m := reNxxN.FindStringSubmatch(filename); len(m) == 3 {
-		season, _ := strconv.Atoi(m[1])
-		episode, _ := strconv.Atoi(m[2])
-		ep, ar, err := s.linkSeriesSingle(lib, season, episode, mf)
+	if loc := reNxxN.FindStringSubmatchIndex(filename); loc != nil {
+		season, _ := strconv.Atoi(filename[loc[2]:loc[3]])
+		episode, _ := strconv.Atoi(filename[loc[4]:loc[5]])
+		title := episodeTitleFromFilename(filename[loc[1]:])
+		ep, ar, err := s.linkSeriesSingle(lib, season, episode, title, mf)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -421,7 +700,7 @@ func (s *FSScanner) attachSeriesEpisode(
 	// 4) Anime-style
 	if m := reAnimeEp.FindStringSubmatch(filename); len(m) == 3 {
 		episode, _ := strconv.Atoi(m[2])
-		ep, ar, err := s.linkSeriesSingle(lib, 1, episode, mf)
+		ep, ar, err := s.linkSeriesSingle(lib, 1, episode, "", mf)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -459,11 +738,12 @@ func (s *FSScanner) attachSeriesEpisodeTx(
 	}
 
 	// 2) Single SxxExx
-	if m := reSxxExx.FindStringSubmatch(filename); len(m) == 3 {
-		season, _ := strconv.Atoi(m[1])
-		episode, _ := strconv.Atoi(m[2])
+	if loc := reSxxExx.FindStringSubmatchIndex(filename); loc != nil {
+		season, _ := strconv.Atoi(filename[loc[2]:loc[3]])
+		episode, _ := strconv.Atoi(filename[loc[4]:loc[5]])
+		title := episodeTitleFromFilename(filename[loc[1]:])
 
-		ep, ar, err := s.linkSeriesSingleTx(tx, lib, season, episode, mf)
+		ep, ar, err := s.linkSeriesSingleTx(tx, lib, season, episode, title, mf)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -472,11 +752,12 @@ func (s *FSScanner) attachSeriesEpisodeTx(
 	}
 
 	// 3) 1x02
-	if m := reNxxN.FindStringSubmatch(filename); len(m) == 3 {
-		season, _ := strconv.Atoi(m[1])
-		episode, _ := strconv.Atoi(m[2])
+	if loc := reNxxN.FindStringSubmatchIndex(filename); loc != nil {
+		season, _ := strconv.Atoi(filename[loc[2]:loc[3]])
+		episode, _ := strconv.Atoi(filename[loc[4]:loc[5]])
+		title := episodeTitleFromFilename(filename[loc[1]:])
 
-		ep, ar, err := s.linkSeriesSingleTx(tx, lib, season, episode, mf)
+		ep, ar, err := s.linkSeriesSingleTx(tx, lib, season, episode, title, mf)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -488,7 +769,7 @@ func (s *FSScanner) attachSeriesEpisodeTx(
 	if m := reAnimeEp.FindStringSubmatch(filename); len(m) == 3 {
 		episode, _ := strconv.Atoi(m[2])
 
-		ep, ar, err := s.linkSeriesSingleTx(tx, lib, 1, episode, mf)
+		ep, ar, err := s.linkSeriesSingleTx(tx, lib, 1, episode, "", mf)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -505,8 +786,20 @@ func (s *FSScanner) attachSeriesEpisodeTx(
 	return []*domain.Episode{ep}, ar, nil
 }
 
-// createAudioTracks stores embedded audio stream metadata.
-func (s *FSScanner) createAudioTracks(mf *domain.MediaFile, ffdata *FFProbeOutput) error {
+// syncAudioTracksTx reconciles the stored audio tracks with the probed
+// streams, matching on stream index. Matching rows are updated in place so
+// their IDs stay stable across rescans.
+func (s *FSScanner) syncAudioTracksTx(tx store.Store, mf *domain.MediaFile, ffdata *FFProbeOutput) error {
+	existing, err := tx.ListAudioTracks(mf.ID)
+	if err != nil {
+		return err
+	}
+
+	byIndex := make(map[int]domain.AudioTrack, len(existing))
+	for _, at := range existing {
+		byIndex[at.StreamIndex] = at
+	}
+
 	for _, st := range ffdata.Streams {
 		if st.CodecType != "audio" {
 			continue
@@ -515,36 +808,42 @@ func (s *FSScanner) createAudioTracks(mf *domain.MediaFile, ffdata *FFProbeOutpu
 		track := &domain.AudioTrack{
 			MediaFileID: mf.ID,
 			StreamIndex: st.Index,
-			Language:    strings.TrimSpace(st.Tags.Language),
+			Language:    language.Normalize(st.Tags.Language),
+			Title:       strings.TrimSpace(st.Tags.Title),
 			Codec:       st.CodecName,
+			Profile:     st.Profile,
 			Channels:    st.Channels,
+			BitRate:     parseBitRate(st.BitRate),
 			IsDefault:   st.Disposition.Default == 1,
-		}
 
-		if err := s.store.CreateAudioTrack(track); err != nil {
-			return err
+			IsCommentary:      st.Disposition.Comment == 1 || isCommentaryTitle(st.Tags.Title),
+			IsHearingImpaired: st.Disposition.HearingImpaired == 1,
+			IsVisualImpaired:  st.Disposition.VisualImpaired == 1,
+			IsDub:             st.Disposition.Dub == 1,
+			IsOriginal:        st.Disposition.Original == 1,
 		}
-	}
-
-	return nil
-}
 
-func (s *FSScanner) createAudioTracksTx(tx store.Store, mf *domain.MediaFile, ffdata *FFProbeOutput) error {
-	for _, st := range ffdata.Streams {
-		if st.CodecType != "audio" {
+		old, ok := byIndex[st.Index]
+		if !ok {
+			if err := tx.CreateAudioTrack(track); err != nil {
+				return err
+			}
 			continue
 		}
 
-		track := &domain.AudioTrack{
-			MediaFileID: mf.ID,
-			StreamIndex: st.Index,
-			Language:    strings.TrimSpace(st.Tags.Language),
-			Codec:       st.CodecName,
-			Channels:    st.Channels,
-			IsDefault:   st.Disposition.Default == 1,
+		delete(byIndex, st.Index)
+		track.ID = old.ID
+		if *track == old {
+			continue
 		}
+		if err := tx.UpdateAudioTrack(track); err != nil {
+			return err
+		}
+	}
 
-		if err := tx.CreateAudioTrack(track); err != nil {
+	// Whatever is left no longer exists in the file.
+	for _, at := range byIndex {
+		if err := tx.DeleteAudioTrack(at.ID); err != nil {
 			return err
 		}
 	}
@@ -552,58 +851,73 @@ func (s *FSScanner) createAudioTracksTx(tx store.Store, mf *domain.MediaFile, ff
 	return nil
 }
 
-// createSubtitleTracks stores both embedded and external subtitles.
-func (s *FSScanner) createSubtitleTracks(mf *domain.MediaFile, ffdata *FFProbeOutput) error {
-	// 1) Embedded subtitles from ffprobe.
+// createVideoStreamsTx replaces the stored video streams with the probed ones.
+// Cover art attached as a video stream is skipped.
+func (s *FSScanner) createVideoStreamsTx(tx store.Store, mf *domain.MediaFile, ffdata *FFProbeOutput) error {
+	if err := tx.DeleteVideoStreams(mf.ID); err != nil {
+		return err
+	}
+
 	for _, st := range ffdata.Streams {
-		if st.CodecType != "subtitle" {
+		if st.CodecType != "video" || st.Disposition.AttachedPic == 1 {
 			continue
 		}
 
-		language := strings.TrimSpace(st.Tags.Language)
-		format := st.CodecName
-
-		isDefault := st.Disposition.Default == 1
-		isForced := st.Disposition.Forced == 1
+		sideData := make([]string, 0, len(st.SideDataList))
+		for _, sd := range st.SideDataList {
+			sideData = append(sideData, sd.SideDataType)
+		}
 
-		streamIndex := st.Index
+		frameRate := parseFrameRate(st.AvgFrameRate)
+		if frameRate == 0 {
+			frameRate = parseFrameRate(st.RFrameRate)
+		}
 
-		sub := &domain.SubtitleTrack{
-			MediaFileID: mf.ID,
-			Source:      domain.SubtitleSourceEmbedded,
-			StreamIndex: &streamIndex,
-			Language:    language,
-			IsForced:    isForced,
-			IsDefault:   isDefault,
-			Format:      format,
+		vs := &domain.VideoStream{
+			MediaFileID:    mf.ID,
+			StreamIndex:    st.Index,
+			Codec:          st.CodecName,
+			Profile:        st.Profile,
+			Level:          st.Level,
+			Width:          st.Width,
+			Height:         st.Height,
+			BitRate:        parseBitRate(st.BitRate),
+			FrameRate:      frameRate,
+			BitDepth:       bitDepth(st.BitsPerRawSample, st.PixFmt),
+			PixelFormat:    st.PixFmt,
+			ColorRange:     st.ColorRange,
+			ColorSpace:     st.ColorSpace,
+			ColorTransfer:  st.ColorTransfer,
+			ColorPrimaries: st.ColorPrimaries,
+			HDRFormats:     strings.Join(hdrFormats(st.CodecTag, st.ColorTransfer, sideData), ","),
+			ScanType:       scanType(st.FieldOrder),
+			IsDefault:      st.Disposition.Default == 1,
 		}
 
-		if err := s.store.CreateSubtitleTrack(sub); err != nil {
+		if err := tx.CreateVideoStream(vs); err != nil {
 			return err
 		}
 	}
 
-	// 2) External sidecar subtitles (.srt, .ass, .vtt, .sub).
-	externalSubs, err := findExternalSubtitles(mf.Path)
-	if err != nil {
+	return nil
+}
+
+// createChaptersTx replaces the stored chapter markers with the probed ones.
+func (s *FSScanner) createChaptersTx(tx store.Store, mf *domain.MediaFile, ffdata *FFProbeOutput) error {
+	if err := tx.DeleteChapters(mf.ID); err != nil {
 		return err
 	}
 
-	for _, ex := range externalSubs {
-		lang := ex.Language
-		format := strings.TrimPrefix(strings.ToLower(filepath.Ext(ex.Path)), ".")
-
-		sub := &domain.SubtitleTrack{
-			MediaFileID:  mf.ID,
-			Source:       domain.SubtitleSourceExternal,
-			ExternalPath: &ex.Path,
-			Language:     lang,
-			IsForced:     false,
-			IsDefault:    false,
-			Format:       format,
+	for i, c := range ffdata.Chapters {
+		ch := &domain.Chapter{
+			MediaFileID: mf.ID,
+			Index:       i,
+			StartMs:     parseSecondsMs(c.StartTime),
+			EndMs:       parseSecondsMs(c.EndTime),
+			Title:       strings.TrimSpace(c.Tags.Title),
 		}
 
-		if err := s.store.CreateSubtitleTrack(sub); err != nil {
+		if err := tx.CreateChapter(ch); err != nil {
 			return err
 		}
 	}
@@ -611,74 +925,158 @@ func (s *FSScanner) createSubtitleTracks(mf *domain.MediaFile, ffdata *FFProbeOu
 	return nil
 }
 
-func (s *FSScanner) createSubtitleTracksTx(tx store.Store, mf *domain.MediaFile, ffdata *FFProbeOutput) error {
+// syncSubtitleTracksTx reconciles the stored subtitle tracks with the
+// probed embedded streams (matched on stream index) and the sidecar files
+// next to the video (matched on path). Matching rows are updated in place so
+// their IDs stay stable across rescans.
+func (s *FSScanner) syncSubtitleTracksTx(tx store.Store, mf *domain.MediaFile, ffdata *FFProbeOutput, result *ScanResult) error {
+	var want []*domain.SubtitleTrack
+
 	// 1) Embedded subtitles from ffprobe.
 	for _, st := range ffdata.Streams {
 		if st.CodecType != "subtitle" {
 			continue
 		}
 
-		language := strings.TrimSpace(st.Tags.Language)
-		format := st.CodecName
-
-		isDefault := st.Disposition.Default == 1
-		isForced := st.Disposition.Forced == 1
-
 		streamIndex := st.Index
-
-		sub := &domain.SubtitleTrack{
-			MediaFileID: mf.ID,
-			Source:      domain.SubtitleSourceEmbedded,
-			StreamIndex: &streamIndex,
-			Language:    language,
-			IsForced:    isForced,
-			IsDefault:   isDefault,
-			Format:      format,
-		}
-
-		if err := tx.CreateSubtitleTrack(sub); err != nil {
-			return err
-		}
+		title := strings.TrimSpace(st.Tags.Title)
+
+		want = append(want, &domain.SubtitleTrack{
+			MediaFileID:      mf.ID,
+			Source:           domain.SubtitleSourceEmbedded,
+			StreamIndex:      &streamIndex,
+			Language:         language.Normalize(st.Tags.Language),
+			Title:            title,
+			IsForced:         st.Disposition.Forced == 1,
+			IsSDH:            st.Disposition.HearingImpaired == 1 || isSDHTitle(title),
+			IsDefault:        st.Disposition.Default == 1,
+			IsCommentary:     st.Disposition.Comment == 1 || isCommentaryTitle(title),
+			IsVisualImpaired: st.Disposition.VisualImpaired == 1,
+			IsDub:            st.Disposition.Dub == 1,
+			IsOriginal:       st.Disposition.Original == 1,
+			Format:           st.CodecName,
+			IsBitmap:         isBitmapSubtitle(st.CodecName),
+		})
 	}
 
-	// 2) External sidecar subtitles (.srt, .ass, .vtt, .sub).
+	// 2) External sidecar subtitles, including Subs/ folders.
 	externalSubs, err := findExternalSubtitles(mf.Path)
 	if err != nil {
 		return err
 	}
 
 	for _, ex := range externalSubs {
-		lang := ex.Language
+		path := ex.Path
 		format := strings.TrimPrefix(strings.ToLower(filepath.Ext(ex.Path)), ".")
 
-		sub := &domain.SubtitleTrack{
+		want = append(want, &domain.SubtitleTrack{
 			MediaFileID:  mf.ID,
 			Source:       domain.SubtitleSourceExternal,
-			ExternalPath: &ex.Path,
-			Language:     lang,
-			IsForced:     false,
-			IsDefault:    false,
+			ExternalPath: &path,
+			Language:     ex.Language,
+			IsForced:     ex.IsForced,
+			IsSDH:        ex.IsSDH,
+			IsDefault:    ex.IsDefault,
+			IsCommentary: ex.IsCommentary,
 			Format:       format,
+			IsBitmap:     isBitmapSubtitle(format),
+		})
+	}
+
+	existing, err := tx.ListSubtitleTracks(mf.ID)
+	if err != nil {
+		return err
+	}
+
+	byKey := make(map[string]domain.SubtitleTrack, len(existing))
+	for _, st := range existing {
+		if key := subtitleTrackKey(&st); key != "" {
+			byKey[key] = st
+		}
+	}
+
+	for _, sub := range want {
+		key := subtitleTrackKey(sub)
+
+		old, ok := byKey[key]
+		if !ok {
+			if err := tx.CreateSubtitleTrack(sub); err != nil {
+				return err
+			}
+			continue
+		}
+
+		delete(byKey, key)
+		sub.ID = old.ID
+		if sameSubtitleTrack(sub, &old) {
+			continue
 		}
+		if err := tx.UpdateSubtitleTrack(sub); err != nil {
+			return err
+		}
+	}
 
-		if err := tx.CreateSubtitleTrack(sub); err != nil {
+	// Whatever is left has vanished from the file or the directory.
+	for _, st := range byKey {
+		if err := tx.DeleteSubtitleTrack(st.ID); err != nil {
 			return err
 		}
+		result.RemovedSubtitles = append(result.RemovedSubtitles, st.ID)
 	}
 
 	return nil
 }
 
+// subtitleTrackKey identifies a scanned subtitle track across rescans. Tracks
+// that do not come from the scan return "" and are left alone.
+func subtitleTrackKey(st *domain.SubtitleTrack) string {
+	switch {
+	case st.Source == domain.SubtitleSourceEmbedded && st.StreamIndex != nil:
+		return "stream:" + strconv.Itoa(*st.StreamIndex)
+	case st.Source == domain.SubtitleSourceExternal && st.ExternalPath != nil:
+		return "path:" + *st.ExternalPath
+	default:
+		return ""
+	}
+}
+
+func sameSubtitleTrack(a, b *domain.SubtitleTrack) bool {
+	return a.Language == b.Language &&
+		a.Title == b.Title &&
+		a.IsForced == b.IsForced &&
+		a.IsSDH == b.IsSDH &&
+		a.IsDefault == b.IsDefault &&
+		a.IsCommentary == b.IsCommentary &&
+		a.IsVisualImpaired == b.IsVisualImpaired &&
+		a.IsDub == b.IsDub &&
+		a.IsOriginal == b.IsOriginal &&
+		a.Format == b.Format &&
+		a.IsBitmap == b.IsBitmap
+}
+
 type externalSubtitle struct {
-	Path     string
-	Language string
+	Path         string
+	Language     string
+	IsForced     bool
+	IsSDH        bool
+	IsDefault    bool
+	IsCommentary bool
 }
 
 var subtitleExt = map[string]bool{
 	".srt": true,
 	".ass": true,
+	".ssa": true,
 	".vtt": true,
-	".sub": true,
+	".sub": true, // MicroDVD text, or VobSub bitmaps when paired with .idx
+	".idx": true,
+	".sup": true,
+}
+
+// Folders next to the video that release packs put subtitles in.
+var subtitleDirs = map[string]bool{
+	"subs":      true,
+	"subtitles": true,
 }
 
 // findExternalSubtitles looks for files like:
@@ -687,6 +1085,10 @@ var subtitleExt = map[string]bool{
 //	Movie.srt
 //	Movie.en.srt
 //	Movie_es.srt
+//	Subs/Movie.en.srt
+//	Subs/2_English.srt          (only when Movie.mkv is alone in its folder)
+//	Subs/Movie/2_English.srt    (per-episode folders in season packs)
+//	Movie.en.idx + Movie.en.sub (VobSub, stored once as the .idx)
 func findExternalSubtitles(videoPath string) ([]externalSubtitle, error) {
 	dir := filepath.Dir(videoPath)
 	base := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
@@ -696,6 +1098,60 @@ func findExternalSubtitles(videoPath string) ([]externalSubtitle, error) {
 		return nil, err
 	}
 
+	result := subtitlesIn(dir, entries, base, false)
+
+	videos := 0
+	for _, e := range entries {
+		if !e.IsDir() && videoExt[strings.ToLower(filepath.Ext(e.Name()))] {
+			videos++
+		}
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() || !subtitleDirs[strings.ToLower(e.Name())] {
+			continue
+		}
+
+		subDir := filepath.Join(dir, e.Name())
+		subEntries, err := os.ReadDir(subDir)
+		if err != nil {
+			return nil, err
+		}
+
+		// Unprefixed names are only unambiguous when the folder holds a
+		// single video.
+		result = append(result, subtitlesIn(subDir, subEntries, base, videos == 1)...)
+
+		for _, se := range subEntries {
+			if !se.IsDir() || se.Name() != base {
+				continue
+			}
+
+			epDir := filepath.Join(subDir, se.Name())
+			epEntries, err := os.ReadDir(epDir)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, subtitlesIn(epDir, epEntries, base, true)...)
+		}
+	}
+
+	return result, nil
+}
+
+// subtitlesIn collects the subtitle files in one directory that belong to the
+// video named base. With anyName set, files that do not start with base are
+// taken as well and their whole name is parsed for tags.
+func subtitlesIn(dir string, entries []os.DirEntry, base string, anyName bool) []externalSubtitle {
+	// A .sub next to an .idx of the same name is the VobSub bitmap data, not
+	// a MicroDVD file.
+	idx := map[string]bool{}
+	for _, e := range entries {
+		if !e.IsDir() && strings.EqualFold(filepath.Ext(e.Name()), ".idx") {
+			idx[strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))] = true
+		}
+	}
+
 	var result []externalSubtitle
 
 	for _, e := range entries {
@@ -709,47 +1165,123 @@ func findExternalSubtitles(videoPath string) ([]externalSubtitle, error) {
 			continue
 		}
 
-		if !strings.HasPrefix(name, base) {
+		stem := strings.TrimSuffix(name, filepath.Ext(name))
+		if ext == ".sub" && idx[stem] {
 			continue
 		}
 
-		// Derive language from anything after base but before extension.
-		// e.g. "Movie.en.srt" -> ".en"
-		rest := strings.TrimSuffix(name, ext)
-		rest = strings.TrimPrefix(rest, base)
+		// Language and flags come from anything after base but before
+		// extension, e.g. "Movie.pt-BR.forced.srt" -> ".pt-BR.forced"
+		var rest string
+		switch {
+		case strings.HasPrefix(name, base):
+			rest = strings.TrimPrefix(stem, base)
+		case anyName:
+			rest = stem
+		default:
+			continue
+		}
 
-		rest = strings.TrimLeft(rest, "._-")
-		lang := strings.TrimSpace(rest) // may be "" if plain Movie.srt
+		ex := parseSubtitleTags(rest)
+		ex.Path = filepath.Join(dir, name)
+		result = append(result, ex)
+	}
 
-		result = append(result, externalSubtitle{
-			Path:     filepath.Join(dir, name),
-			Language: lang,
-		})
+	return result
+}
+
+// isBitmapSubtitle reports whether a subtitle format (ffprobe codec name or
+// sidecar extension) is image based and has to be burned in by clients that
+// cannot render it.
+func isBitmapSubtitle(format string) bool {
+	switch format {
+	case "hdmv_pgs_subtitle", "dvd_subtitle", "dvb_subtitle", "xsub", "idx", "sup":
+		return true
+	default:
+		return false
 	}
+}
 
-	return result, nil
+// parseSubtitleTags reads the name segments between the video base name and
+// the extension: a language ("en", "eng", "English", "pt-BR") plus optional
+// forced, sdh/cc/hi, default and commentary flags, in any order. Unknown segments are
+// ignored.
+func parseSubtitleTags(rest string) externalSubtitle {
+	var (
+		ex      externalSubtitle
+		tokens  []string
+		sawHint bool // "hi" seen: hearing impaired, or Hindi if nothing else
+	)
+
+	for _, seg := range strings.FieldsFunc(rest, func(r rune) bool {
+		return r == '.' || r == '_' || r == ' ' || r == '[' || r == ']' || r == '(' || r == ')'
+	}) {
+		// Keep "pt-BR" whole, but split "en-forced".
+		if _, ok := language.Lookup(seg); ok {
+			tokens = append(tokens, seg)
+			continue
+		}
+		tokens = append(tokens, strings.Split(seg, "-")...)
+	}
+
+	for _, tok := range tokens {
+		switch strings.ToLower(tok) {
+		case "":
+			continue
+		case "forced", "foreign":
+			ex.IsForced = true
+			continue
+		case "sdh", "cc":
+			ex.IsSDH = true
+			continue
+		case "hi":
+			sawHint = true
+			continue
+		case "default":
+			ex.IsDefault = true
+			continue
+		case "commentary":
+			ex.IsCommentary = true
+			continue
+		}
+
+		if ex.Language != "" {
+			continue
+		}
+		if tag, ok := language.Lookup(tok); ok {
+			ex.Language = tag
+		}
+	}
+
+	if sawHint {
+		if ex.Language == "" {
+			ex.Language = "hi"
+		} else {
+			ex.IsSDH = true
+		}
+	}
+
+	return ex
 }
 
 // linkSeriesSingle: one season/episode → returns the Episode.
 func (s *FSScanner) linkSeriesSingle(
 	lib *domain.Library,
 	season, episode int,
+	title string,
 	mf *domain.MediaFile,
 ) (*domain.Episode, *attachResult, error) {
 
 	res := &attachResult{}
-	title := extractSeriesTitle(mf.Path)
+	sf := parseSeriesFolder(mf.Path)
 
 	// 1) Series
-	sr, err := s.store.GetSeriesByTitle(title, lib.ID)
+	sr, err := findSeriesTx(s.store, lib, sf)
 	if err != nil {
 		return nil, nil, err
 	}
 	if sr == nil {
-		sr = &domain.Series{
-			LibraryID: lib.ID,
-			Title:     title,
-		}
+		sr = newSeries(lib, sf)
 		if err := s.store.CreateSeries(sr); err != nil {
 			return nil, nil, err
 		}
@@ -782,10 +1314,20 @@ func (s *FSScanner) linkSeriesSingle(
 			SeasonID: se.ID,
 			Number:   episode,
 		}
+		if title != "" {
+			ep.Title = title
+			ep.TitleSource = domain.TitleSourceFilename
+		}
 		if err := s.store.CreateEpisode(ep); err != nil {
 			return nil, nil, err
 		}
 		res.EpisodesCreated = 1
+	} else if title != "" && title != ep.Title && canReplaceEpisodeTitle(ep) {
+		ep.Title = title
+		ep.TitleSource = domain.TitleSourceFilename
+		if err := s.store.UpdateEpisode(ep); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	return ep, res, nil
@@ -795,22 +1337,20 @@ func (s *FSScanner) linkSeriesSingleTx(
 	tx store.Store,
 	lib *domain.Library,
 	season, episode int,
+	title string,
 	mf *domain.MediaFile,
 ) (*domain.Episode, *attachResult, error) {
 
 	res := &attachResult{}
-	title := extractSeriesTitle(mf.Path)
+	sf := parseSeriesFolder(mf.Path)
 
 	// 1) Series
-	sr, err := tx.GetSeriesByTitle(title, lib.ID)
+	sr, err := findSeriesTx(tx, lib, sf)
 	if err != nil {
 		return nil, nil, err
 	}
 	if sr == nil {
-		sr = &domain.Series{
-			LibraryID: lib.ID,
-			Title:     title,
-		}
+		sr = newSeries(lib, sf)
 		if err := tx.CreateSeries(sr); err != nil {
 			return nil, nil, err
 		}
@@ -843,10 +1383,20 @@ func (s *FSScanner) linkSeriesSingleTx(
 			SeasonID: se.ID,
 			Number:   episode,
 		}
+		if title != "" {
+			ep.Title = title
+			ep.TitleSource = domain.TitleSourceFilename
+		}
 		if err := tx.CreateEpisode(ep); err != nil {
 			return nil, nil, err
 		}
 		res.EpisodesCreated = 1
+	} else if title != "" && title != ep.Title && canReplaceEpisodeTitle(ep) {
+		ep.Title = title
+		ep.TitleSource = domain.TitleSourceFilename
+		if err := tx.UpdateEpisode(ep); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	return ep, res, nil
@@ -863,7 +1413,7 @@ func (s *FSScanner) linkSeriesRange(
 	var eps []*domain.Episode
 
 	for n := startEp; n <= endEp; n++ {
-		ep, ar, err := s.linkSeriesSingle(lib, season, n, mf)
+		ep, ar, err := s.linkSeriesSingle(lib, season, n, "", mf)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -893,7 +1443,7 @@ func (s *FSScanner) linkSeriesRangeTx(
 	var eps []*domain.Episode
 
 	for n := startEp; n <= endEp; n++ {
-		ep, ar, err := s.linkSeriesSingleTx(tx, lib, season, n, mf)
+		ep, ar, err := s.linkSeriesSingleTx(tx, lib, season, n, "", mf)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -912,12 +1462,35 @@ func (s *FSScanner) linkSeriesRangeTx(
 	return eps, res, nil
 }
 
-func extractSeriesTitle(path string) string {
+// seriesFolder is what a series folder name tells us about the show, e.g.
+//
+//	Doctor Who (2005) {tmdb-57243}
+//	Doctor Who (1963) [tvdbid-76107]
+type seriesFolder struct {
+	Title  string
+	Year   int
+	TMDBID string
+	TVDBID string
+
+	// Legacy is the title scans stored before years and tags were parsed:
+	// the whole folder name, tags and all.
+	Legacy string
+}
+
+var (
+	reSeriesIDTag = regexp.MustCompile(`(?i)[\[{](tmdb|tvdb)(?:id)?[-=](\d+)[\]}]`)
+	reSeriesYear  = regexp.MustCompile(`^(.*?)[\s._-]*\(((?:19|20)\d{2})\)$`)
+	reSceneYear   = regexp.MustCompile(`^(\S*?)\.((?:19|20)\d{2})$`)
+)
+
+// parseSeriesFolder reads the series folder of an episode path, skipping a
+// season folder if present, and extracts title, first-air year and ID tags.
+func parseSeriesFolder(path string) seriesFolder {
 	dir := filepath.Dir(path)
 	parts := strings.Split(dir, string(os.PathSeparator))
 
 	if len(parts) == 0 {
-		return ""
+		return seriesFolder{}
 	}
 
 	// Get last folder
@@ -929,14 +1502,105 @@ func extractSeriesTitle(path string) string {
 	}
 
 	if len(parts) == 0 {
-		return ""
+		return seriesFolder{}
+	}
+
+	name := parts[len(parts)-1]
+
+	sf := seriesFolder{Legacy: folderTitle(name)}
+	for _, m := range reSeriesIDTag.FindAllStringSubmatch(name, -1) {
+		switch strings.ToLower(m[1]) {
+		case "tmdb":
+			sf.TMDBID = m[2]
+		case "tvdb":
+			sf.TVDBID = m[2]
+		}
+	}
+	name = strings.TrimSpace(reSeriesIDTag.ReplaceAllString(name, ""))
+
+	// A year counts in parentheses, or bare at the end of a scene-dotted
+	// name, so "Space 1999" and "Blade Runner 2049" keep their numbers.
+	// Either way something must be left for the title, so a show
+	// literally named "1923" keeps its name.
+	m := reSeriesYear.FindStringSubmatch(name)
+	if m == nil {
+		m = reSceneYear.FindStringSubmatch(name)
+	}
+	if m != nil && strings.Trim(m[1], " ._-") != "" {
+		sf.Year, _ = strconv.Atoi(m[2])
+		name = m[1]
 	}
 
-	title := parts[len(parts)-1]
+	sf.Title = folderTitle(name)
+	return sf
+}
+
+// folderTitle turns dots and underscores in a folder name into spaces.
+func folderTitle(name string) string {
+	name = strings.ReplaceAll(name, ".", " ")
+	name = strings.ReplaceAll(name, "_", " ")
+	return strings.TrimSpace(name)
+}
+
+// findSeriesTx resolves the series a folder belongs to. Explicit ID tags win
+// over title matching so that differently named folders can still merge.
+func findSeriesTx(tx store.Store, lib *domain.Library, sf seriesFolder) (*domain.Series, error) {
+	if sf.TMDBID != "" {
+		sr, err := tx.GetSeriesByTMDBID(sf.TMDBID, lib.ID)
+		if err != nil || sr != nil {
+			return sr, err
+		}
+	}
+	if sf.TVDBID != "" {
+		sr, err := tx.GetSeriesByTVDBID(sf.TVDBID, lib.ID)
+		if err != nil || sr != nil {
+			return sr, err
+		}
+	}
+
+	sr, err := tx.GetSeriesByTitleAndYear(sf.Title, sf.Year, lib.ID)
+	if err == nil && sr == nil && sf.Legacy != sf.Title {
+		// Keep using a series an older scan stored under the raw folder
+		// name rather than adding a second one beside it.
+		sr, err = tx.GetSeriesByTitleAndYear(sf.Legacy, 0, lib.ID)
+	}
+	if err != nil || sr == nil {
+		return sr, err
+	}
+
+	// Bind IDs from tags the first time we see them on an existing series.
+	changed := false
+	if sf.TMDBID != "" && sr.TMDBID == nil {
+		sr.TMDBID = &sf.TMDBID
+		changed = true
+	}
+	if sf.TVDBID != "" && sr.TVDBID == nil {
+		sr.TVDBID = &sf.TVDBID
+		changed = true
+	}
+	if changed {
+		if err := tx.UpdateSeries(sr); err != nil {
+			return nil, err
+		}
+	}
+
+	return sr, nil
+}
 
-	title = strings.ReplaceAll(title, ".", " ")
-	title = strings.ReplaceAll(title, "_", " ")
-	return strings.TrimSpace(title)
+// newSeries builds a series from its folder, carrying over any ID tags.
+func newSeries(lib *domain.Library, sf seriesFolder) *domain.Series {
+	sr := &domain.Series{
+		LibraryID: lib.ID,
+		Title:     sf.Title,
+		Year:      sf.Year,
+	}
+	if sf.TMDBID != "" {
+		sr.TMDBID = &sf.TMDBID
+	}
+	if sf.TVDBID != "" {
+		sr.TVDBID = &sf.TVDBID
+	}
+	return sr
 }
 
 func isSeasonFolder(name string) bool {
@@ -968,7 +1632,7 @@ func (s *FSScanner) fallbackSeriesDetection(
 ) (*domain.Episode, *attachResult, error) {
 	epNum := guessEpisodeNumber(filepath.Base(mf.Path))
 
-	ep, ar, err := s.linkSeriesSingle(lib, 1, epNum, mf)
+	ep, ar, err := s.linkSeriesSingle(lib, 1, epNum, "", mf)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -985,7 +1649,7 @@ func (s *FSScanner) fallbackSeriesDetectionTx(
 
 	epNum := guessEpisodeNumber(filepath.Base(mf.Path))
 
-	ep, ar, err := s.linkSeriesSingleTx(tx, lib, 1, epNum, mf)
+	ep, ar, err := s.linkSeriesSingleTx(tx, lib, 1, epNum, "", mf)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -993,6 +1657,38 @@ func (s *FSScanner) fallbackSeriesDetectionTx(
 	return ep, ar, nil
 }
 
+// reReleaseTags marks where an episode title ends and release noise begins.
+var reReleaseTags = regexp.MustCompile(`(?i)([\s._-]+|[\[(])(\d{3,4}[pi]|4k|uhd|web[-.]?(dl|rip)?|bluray|blu-ray|bdrip|brrip|hdtv|dvdrip|remux|x26[45]|h[.]?26[45]|hevc|avc|proper|repack|internal)\b`)
+
+// episodeTitleFromFilename extracts the episode title from whatever follows
+// the episode marker, e.g. " - The Pilot Strikes Back.mkv".
+func episodeTitleFromFilename(rest string) string {
+	rest = strings.TrimSuffix(rest, filepath.Ext(rest))
+
+	if loc := reReleaseTags.FindStringIndex(rest); loc != nil {
+		rest = rest[:loc[0]]
+	}
+	if i := strings.IndexAny(rest, "[{"); i >= 0 {
+		rest = rest[:i]
+	}
+
+	// Dotted release names use dots as spaces; titles with real spaces
+	// keep their dots ("Mr. Robot").
+	if !strings.Contains(rest, " ") {
+		rest = strings.ReplaceAll(rest, ".", " ")
+	}
+	rest = strings.ReplaceAll(rest, "_", " ")
+
+	return strings.Trim(rest, " .-_")
+}
+
+// canReplaceEpisodeTitle reports whether a filename title may overwrite the
+// stored one. Metadata and manual titles always win over filenames.
+func canReplaceEpisodeTitle(ep *domain.Episode) bool {
+	return ep.TitleSource == domain.TitleSourceNone ||
+		ep.TitleSource == domain.TitleSourceFilename
+}
+
 func guessEpisodeNumber(filename string) int {
 	re := regexp.MustCompile(`\b(\d{1,4})\b`)
 	m := re.FindStringSubmatch(filename)
@@ -1002,3 +1698,20 @@ func guessEpisodeNumber(filename string) int {
 	}
 	return 1
 }
+
+// Many files only mark commentary and SDH in the stream title, e.g.
+// "Director's Commentary" or "English (SDH)".
+func isCommentaryTitle(title string) bool {
+	return strings.Contains(strings.ToLower(title), "commentary")
+}
+
+func isSDHTitle(title string) bool {
+	for _, w := range strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		if w == "sdh" || w == "cc" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bastianvv/vio/internal/subtitle"
+)
+
+var (
+	reSilenceStart = regexp.MustCompile(`silence_start:\s*(-?[\d.]+)`)
+	reSilenceEnd   = regexp.MustCompile(`silence_end:\s*(-?[\d.]+)`)
+)
+
+// DetectSpeech finds the spans of an audio stream that are not silence,
+// using ffmpeg's silencedetect behind a voice band filter. It is a rough
+// voice activity detector, good enough to align subtitles against.
+// ffmpeg is killed when ctx is done; the error then wraps ctx's.
+func DetectSpeech(ctx context.Context, videoPath string, streamIndex int, duration time.Duration) ([]subtitle.Interval, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner",
+		"-nostats",
+		"-vn", "-sn",
+		"-i", videoPath,
+		"-map", fmt.Sprintf("0:%d", streamIndex),
+		"-af", "highpass=f=200,lowpass=f=3000,silencedetect=noise=-35dB:d=0.4",
+		"-f", "null",
+		"-",
+	)
+
+	// silencedetect reports on stderr.
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("ffmpeg: %w", ctx.Err())
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if i := strings.LastIndexByte(msg, '\n'); i >= 0 {
+			msg = msg[i+1:]
+		}
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, msg)
+	}
+
+	return speechFromSilence(stderr.String(), duration), nil
+}
+
+// speechFromSilence inverts silencedetect's log into speech intervals.
+func speechFromSilence(log string, duration time.Duration) []subtitle.Interval {
+	var (
+		speech []subtitle.Interval
+		cursor time.Duration // end of the last silence
+		silent bool
+	)
+
+	for _, line := range strings.Split(log, "\n") {
+		if m := reSilenceStart.FindStringSubmatch(line); m != nil {
+			start := parseSeconds(m[1])
+			if start > cursor {
+				speech = append(speech, subtitle.Interval{Start: cursor, End: start})
+			}
+			silent = true
+			continue
+		}
+		if m := reSilenceEnd.FindStringSubmatch(line); m != nil {
+			cursor = parseSeconds(m[1])
+			silent = false
+		}
+	}
+
+	if !silent && duration > cursor {
+		speech = append(speech, subtitle.Interval{Start: cursor, End: duration})
+	}
+
+	return speech
+}
+
+func parseSeconds(s string) time.Duration {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0
+	}
+	return time.Duration(f * float64(time.Second))
+}
@@ -0,0 +1,185 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bastianvv/vio/internal/domain"
+)
+
+// ErrBitmapSubtitle is returned for image based streams (PGS, VobSub), which
+// cannot be turned into a text file.
+var ErrBitmapSubtitle = errors.New("bitmap subtitles cannot be extracted as text")
+
+// extractTimeout bounds one ffmpeg extraction. ffmpeg reads through the
+// whole file to collect a stream's packets, which takes a while for a large
+// remux on a network share, but not this long.
+const extractTimeout = 5 * time.Minute
+
+// SubtitleCache extracts embedded subtitle streams with ffmpeg and keeps the
+// results on disk as {dir}/{file hash}-{stream index}.{ext}. A changed file
+// gets a new hash, so stale extractions are never served.
+type SubtitleCache struct {
+	dir string
+
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock serializes extractions of one stream. It is dropped from the map
+// once nobody holds or waits for it, so the map only holds live keys.
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+func NewSubtitleCache(dir string) *SubtitleCache {
+	return &SubtitleCache{
+		dir:   dir,
+		locks: make(map[string]*keyLock),
+	}
+}
+
+// Dir is the cache root.
+func (c *SubtitleCache) Dir() string {
+	return c.dir
+}
+
+// Extract returns the path of the cached text file for an embedded track,
+// running ffmpeg on the first request. ffmpeg is killed when ctx is done or
+// after extractTimeout; the error then wraps ctx's.
+func (c *SubtitleCache) Extract(ctx context.Context, mf *domain.MediaFile, st *domain.SubtitleTrack) (string, error) {
+	if st.Source != domain.SubtitleSourceEmbedded || st.StreamIndex == nil {
+		return "", fmt.Errorf("subtitle track %d is not embedded", st.ID)
+	}
+	if st.IsBitmap {
+		return "", ErrBitmapSubtitle
+	}
+
+	ext, codec := extractFormat(st.Format)
+
+	path := filepath.Join(c.dir, fmt.Sprintf("%s-%d.%s", c.key(mf), *st.StreamIndex, ext))
+
+	unlock := c.lock(path)
+	defer unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return "", err
+	}
+
+	// Write next to the final file and rename, so a failed or concurrent
+	// run never leaves a half-written cache entry behind.
+	tmp := path + ".tmp." + ext
+	ctx, cancel := context.WithTimeout(ctx, extractTimeout)
+	defer cancel()
+	if err := runFFmpegExtract(ctx, mf.Path, *st.StreamIndex, codec, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+
+	return path, nil
+}
+
+// Evict removes every extraction of a media file's streams.
+func (c *SubtitleCache) Evict(mf *domain.MediaFile) error {
+	paths, err := filepath.Glob(filepath.Join(c.dir, c.key(mf)+"-*"))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// key names a file's extractions: its content hash, or its ID until it has
+// one.
+func (c *SubtitleCache) key(mf *domain.MediaFile) string {
+	if mf.Hash != "" {
+		return mf.Hash
+	}
+	return fmt.Sprintf("file%d", mf.ID)
+}
+
+// lock takes the lock of key and returns its release.
+func (c *SubtitleCache) lock(key string) func() {
+	c.mu.Lock()
+	l, ok := c.locks[key]
+	if !ok {
+		l = &keyLock{}
+		c.locks[key] = l
+	}
+	l.refs++
+	c.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+
+		c.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(c.locks, key)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// extractFormat picks the output extension and ffmpeg encoder for an
+// embedded codec. ASS/SSA and WebVTT are copied as-is to keep styling;
+// everything else (subrip, mov_text, text) becomes SRT.
+func extractFormat(codec string) (ext, encoder string) {
+	switch codec {
+	case "ass", "ssa":
+		return "ass", "copy"
+	case "webvtt":
+		return "vtt", "copy"
+	case "subrip", "srt":
+		return "srt", "copy"
+	default:
+		return "srt", "srt"
+	}
+}
+
+func runFFmpegExtract(ctx context.Context, videoPath string, streamIndex int, encoder, dst string) error {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-v", "error",
+		"-y",
+		"-i", videoPath,
+		"-map", fmt.Sprintf("0:%d", streamIndex),
+		"-c:s", encoder,
+		dst,
+	)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("ffmpeg: %w", ctx.Err())
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("ffmpeg: %w: %s", err, msg)
+		}
+		return fmt.Errorf("ffmpeg: %w", err)
+	}
+	return nil
+}
@@ -78,3 +78,8 @@ func cacheTMDBImage(
 
 	return fullPath, nil
 }
+
+// EvictImages removes everything cached for one entity, e.g. kind "movies".
+func EvictImages(baseDir, kind string, id int64) error {
+	return os.RemoveAll(filepath.Join(baseDir, kind, fmt.Sprint(id)))
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 type Client struct {
@@ -14,10 +15,18 @@ type Client struct {
 	http    *http.Client
 }
 
+const DefaultBaseURL = "https://api.themoviedb.org/3"
+
 func New(apiKey string) *Client {
+	return NewWithBaseURL(apiKey, DefaultBaseURL)
+}
+
+// NewWithBaseURL talks to baseURL instead of TMDB, e.g. a local stand-in
+// in tests.
+func NewWithBaseURL(apiKey, baseURL string) *Client {
 	return &Client{
 		apiKey:  apiKey,
-		baseURL: "https://api.themoviedb.org/3",
+		baseURL: strings.TrimRight(baseURL, "/"),
 		http:    &http.Client{},
 	}
 }
@@ -118,11 +127,15 @@ func (c *Client) GetMovie(
 func (c *Client) SearchTV(
 	ctx context.Context,
 	name string,
+	firstAirYear int,
 ) ([]TVResult, error) {
 
 	q := url.Values{}
 	q.Set("api_key", c.apiKey)
 	q.Set("query", name)
+	if firstAirYear > 0 {
+		q.Set("first_air_date_year", fmt.Sprint(firstAirYear))
+	}
 
 	req, err := http.NewRequestWithContext(
 		ctx,
@@ -162,6 +175,54 @@ func (c *Client) SearchTV(
 	return out, nil
 }
 
+// FindTVByTVDBID resolves a TheTVDB series ID to TMDB TV results.
+func (c *Client) FindTVByTVDBID(
+	ctx context.Context,
+	tvdbID string,
+) ([]TVResult, error) {
+
+	q := url.Values{}
+	q.Set("api_key", c.apiKey)
+	q.Set("external_source", "tvdb_id")
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		"GET",
+		c.baseURL+"/find/"+url.PathEscape(tvdbID)+"?"+q.Encode(),
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tmdb find failed: %s", resp.Status)
+	}
+
+	var raw findResponse
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	out := make([]TVResult, 0, len(raw.TVResults))
+	for _, r := range raw.TVResults {
+		out = append(out, TVResult{
+			ID:           fmt.Sprint(r.ID),
+			Name:         r.Name,
+			OriginalName: r.OriginalName,
+			FirstAirYear: parseYear(r.FirstAirDate),
+		})
+	}
+
+	return out, nil
+}
+
 func (c *Client) GetTV(
 	ctx context.Context,
 	tmdbID string,
@@ -9,6 +9,15 @@ type searchTVResponse struct {
 	} `json:"results"`
 }
 
+type findResponse struct {
+	TVResults []struct {
+		ID           int    `json:"id"`
+		Name         string `json:"name"`
+		OriginalName string `json:"original_name"`
+		FirstAirDate string `json:"first_air_date"`
+	} `json:"tv_results"`
+}
+
 type tvDetailsResponse struct {
 	ID           int     `json:"id"`
 	Name         string  `json:"name"`
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"path/filepath"
 
+	"github.com/bastianvv/vio/internal/domain"
 	"github.com/bastianvv/vio/internal/metadata/tmdb"
 	"github.com/bastianvv/vio/internal/store"
 )
@@ -128,14 +129,24 @@ func (e *TMDBEnricher) EnrichSeries(ctx context.Context, seriesID int64) error {
 		return errors.New("series not found")
 	}
 
-	// 1) Resolve TMDB ID
+	// 1) Resolve TMDB ID, preferring an explicit TVDB binding over search
+	if series.TMDBID == nil && series.TVDBID != nil {
+		results, err := e.tmdb.FindTVByTVDBID(ctx, *series.TVDBID)
+		if err != nil {
+			return err
+		}
+		if len(results) > 0 {
+			series.TMDBID = &results[0].ID
+		}
+	}
+
 	if series.TMDBID == nil {
-		results, err := e.tmdb.SearchTV(ctx, series.Title)
+		results, err := e.tmdb.SearchTV(ctx, series.Title, series.Year)
 		if err != nil {
 			return err
 		}
 		if len(results) == 0 {
-			return fmt.Errorf("no tmdb tv match for %q", series.Title)
+			return fmt.Errorf("no tmdb tv match for %q (%d)", series.Title, series.Year)
 		}
 
 		best := results[0]
@@ -204,7 +215,11 @@ func (e *TMDBEnricher) EnrichSeries(ctx context.Context, seriesID int64) error {
 				ep.TMDBID = &te.ID
 			}
 
-			ep.Title = te.Title
+			// Manually edited titles are kept; scanner guesses are not.
+			if te.Title != "" && ep.TitleSource != domain.TitleSourceManual {
+				ep.Title = te.Title
+				ep.TitleSource = domain.TitleSourceTMDB
+			}
 			ep.Overview = te.Overview
 			ep.RuntimeMin = te.RuntimeMin
 
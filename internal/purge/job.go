@@ -0,0 +1,95 @@
+package purge
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type JobStatus string
+
+const (
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed"
+)
+
+type Job struct {
+	ID         string     `json:"id"`
+	LibraryID  int64      `json:"library_id"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Status     JobStatus  `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	Result     *Result    `json:"result,omitempty"`
+}
+
+type Registry struct {
+	mu   sync.RWMutex
+	jobs map[string]*Job
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		jobs: make(map[string]*Job),
+	}
+}
+
+func (r *Registry) Start(libraryID int64) *Job {
+	job := &Job{
+		ID:        uuid.NewString(),
+		LibraryID: libraryID,
+		StartedAt: time.Now(),
+		Status:    JobRunning,
+	}
+
+	r.mu.Lock()
+	r.jobs[job.ID] = job
+	r.mu.Unlock()
+
+	return job
+}
+
+func (r *Registry) Finish(jobID string, res *Result) {
+	now := time.Now()
+
+	r.mu.Lock()
+	if job, ok := r.jobs[jobID]; ok {
+		job.Status = JobDone
+		job.Result = res
+		job.FinishedAt = &now
+	}
+	r.mu.Unlock()
+}
+
+func (r *Registry) Fail(jobID string, err error) {
+	now := time.Now()
+
+	r.mu.Lock()
+	if job, ok := r.jobs[jobID]; ok {
+		job.Status = JobFailed
+		job.Error = err.Error()
+		job.FinishedAt = &now
+	}
+	r.mu.Unlock()
+}
+
+func (r *Registry) Get(jobID string) (*Job, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	job, ok := r.jobs[jobID]
+	return job, ok
+}
+
+// Active reports whether the library is still being deleted.
+func (r *Registry) Active(libraryID int64) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, job := range r.jobs {
+		if job.LibraryID == libraryID && job.Status == JobRunning {
+			return true
+		}
+	}
+	return false
+}
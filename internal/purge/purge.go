@@ -0,0 +1,167 @@
+// Package purge deletes libraries: their rows and everything VIO cached for
+// them. Files under the library's path are never touched; the caches are
+// addressed by row ID, never by a stored path.
+package purge
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/bastianvv/vio/internal/domain"
+	"github.com/bastianvv/vio/internal/media"
+	"github.com/bastianvv/vio/internal/metadata"
+	"github.com/bastianvv/vio/internal/store"
+	"github.com/bastianvv/vio/internal/subprovider"
+	"github.com/bastianvv/vio/internal/subsync"
+)
+
+// Result counts what a deletion removed. Cache entries that could not be
+// removed are listed in Warnings; the rows are gone regardless.
+type Result struct {
+	Movies     int      `json:"movies"`
+	Series     int      `json:"series"`
+	Seasons    int      `json:"seasons"`
+	Episodes   int      `json:"episodes"`
+	MediaFiles int      `json:"media_files"`
+	Subtitles  int      `json:"subtitles"`
+	Warnings   []string `json:"warnings,omitempty"`
+}
+
+type Service struct {
+	store     store.Store
+	imageDir  string
+	subtitles *media.SubtitleCache
+	syncs     *subsync.Service
+	downloads *subprovider.Manager
+}
+
+func NewService(
+	s store.Store,
+	imageDir string,
+	subtitles *media.SubtitleCache,
+	syncs *subsync.Service,
+	downloads *subprovider.Manager,
+) *Service {
+	return &Service{
+		store:     s,
+		imageDir:  imageDir,
+		subtitles: subtitles,
+		syncs:     syncs,
+		downloads: downloads,
+	}
+}
+
+// DeleteLibrary notes what the library owns, deletes its rows, then clears
+// their cache entries. The rows go in one transaction, so if that fails
+// nothing has changed. A cache entry that can't be cleared afterwards is
+// left on disk with no row pointing at it, and reported in Warnings.
+func (s *Service) DeleteLibrary(libraryID int64) (*Result, error) {
+	owned, err := s.collect(libraryID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.store.DeleteLibrary(libraryID); err != nil {
+		return nil, err
+	}
+
+	res := &Result{
+		Movies:     len(owned.movies),
+		Series:     len(owned.series),
+		Seasons:    len(owned.seasons),
+		Episodes:   len(owned.episodes),
+		MediaFiles: len(owned.files),
+		Subtitles:  len(owned.subtitles),
+	}
+	warn := func(what string, err error) {
+		if err != nil {
+			log.Printf("library %d: removing %s: %v", libraryID, what, err)
+			res.Warnings = append(res.Warnings, fmt.Sprintf("%s: %v", what, err))
+		}
+	}
+
+	for _, images := range []struct {
+		kind string
+		ids  []int64
+	}{
+		{"movies", owned.movies},
+		{"series", owned.series},
+		{"seasons", owned.seasons},
+		{"episodes", owned.episodes},
+	} {
+		for _, id := range images.ids {
+			warn(fmt.Sprintf("%s %d images", images.kind, id),
+				metadata.EvictImages(s.imageDir, images.kind, id))
+		}
+	}
+
+	for i := range owned.files {
+		mf := &owned.files[i]
+		warn(fmt.Sprintf("file %d extracted subtitles", mf.ID), s.subtitles.Evict(mf))
+		warn(fmt.Sprintf("file %d downloaded subtitles", mf.ID), s.downloads.Evict(mf.ID))
+	}
+	for _, id := range owned.subtitles {
+		warn(fmt.Sprintf("subtitle %d synced copy", id), s.syncs.Evict(id))
+	}
+
+	return res, nil
+}
+
+type owned struct {
+	movies, series, seasons, episodes []int64
+	files                             []domain.MediaFile
+	subtitles                         []int64
+}
+
+func (s *Service) collect(libraryID int64) (*owned, error) {
+	var o owned
+	all := store.ListFilter{LibraryID: libraryID}
+
+	movies, _, err := s.store.QueryMovies(all, store.Page{})
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range movies {
+		o.movies = append(o.movies, m.ID)
+	}
+
+	series, _, err := s.store.QuerySeries(all, store.Page{})
+	if err != nil {
+		return nil, err
+	}
+	for _, sr := range series {
+		o.series = append(o.series, sr.ID)
+
+		seasons, err := s.store.ListSeasonsBySeries(sr.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, se := range seasons {
+			o.seasons = append(o.seasons, se.ID)
+		}
+	}
+
+	episodes, _, err := s.store.QueryEpisodes(all, store.Page{})
+	if err != nil {
+		return nil, err
+	}
+	for _, ep := range episodes {
+		o.episodes = append(o.episodes, ep.ID)
+	}
+
+	o.files, _, err = s.store.QueryMediaFiles(all, store.Page{})
+	if err != nil {
+		return nil, err
+	}
+	for _, mf := range o.files {
+		tracks, err := s.store.ListSubtitleTracks(mf.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, st := range tracks {
+			o.subtitles = append(o.subtitles, st.ID)
+		}
+	}
+
+	return &o, nil
+}
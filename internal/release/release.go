@@ -0,0 +1,196 @@
+package release
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Info is what a scene/P2P style release name says about the file, e.g.
+//
+//	Movie.Title.2021.2160p.UHD.BluRay.REMUX.HDR10.DV.TrueHD.7.1.Atmos-GROUP.mkv
+//
+// All fields are hints taken from the name only; ffprobe data wins when both
+// are available.
+type Info struct {
+	Resolution string   // 480p, 576p, 720p, 1080p, 2160p; 480i, 576i, 1080i
+	Source     string   // BluRay, WEB-DL, WEBRip, HDTV, DVD
+	VideoCodec string   // h264, hevc, av1, xvid, ...
+	AudioCodec string   // truehd, dts-hd ma, eac3, ac3, aac, ...
+	HDR        []string // HDR10, HDR10+, DV, HLG
+	Group      string
+	Remux      bool
+	Proper     bool
+	Repack     bool
+}
+
+type tag struct {
+	re    *regexp.Regexp
+	value string
+}
+
+// Boundaries: release names separate tokens with dots, spaces, dashes,
+// underscores and brackets, so a plain \b is not strict enough.
+const (
+	pre  = `(?i)(?:^|[\s._\-\[\(])`
+	post = `(?:$|[\s._\-\]\)])`
+)
+
+func t(pattern, value string) tag {
+	return tag{re: regexp.MustCompile(pre + `(?:` + pattern + `)` + post), value: value}
+}
+
+var resolutions = []tag{
+	t(`2160p|4k|uhd`, "2160p"),
+	t(`1080p`, "1080p"),
+	t(`1080i`, "1080i"),
+	t(`720p`, "720p"),
+	t(`576p`, "576p"),
+	t(`576i`, "576i"),
+	t(`480p`, "480p"),
+	t(`480i`, "480i"),
+}
+
+var sources = []tag{
+	t(`blu-?ray|bd-?rip|br-?rip|bdremux`, "BluRay"),
+	t(`web-?rip`, "WEBRip"),
+	t(`web-?dl|web`, "WEB-DL"),
+	t(`hdtv|pdtv`, "HDTV"),
+	t(`dvd-?rip|dvd(?:-?r|5|9)?`, "DVD"),
+}
+
+var videoCodecs = []tag{
+	t(`[xh][.]?265|hevc`, "hevc"),
+	t(`[xh][.]?264|avc`, "h264"),
+	t(`av1`, "av1"),
+	t(`vp9`, "vp9"),
+	t(`xvid|divx`, "mpeg4"),
+	t(`mpeg-?2`, "mpeg2video"),
+}
+
+// Order matters: the more specific codec must be tried first.
+var audioCodecs = []tag{
+	t(`truehd`, "truehd"),
+	t(`dts-?hd[.\s_-]?ma|dts-?ma`, "dts-hd ma"),
+	t(`dts-?x`, "dts:x"),
+	t(`dts-?hd`, "dts-hd"),
+	t(`dts`, "dts"),
+	t(`e-?ac-?3|ddp(?:[\s.]?\d[.\s]?\d)?|dd\+`, "eac3"),
+	t(`ac-?3|dd(?:[\s.]?\d[.\s]?\d)?`, "ac3"),
+	t(`flac`, "flac"),
+	t(`aac(?:[\s.]?\d[.\s]?\d)?`, "aac"),
+	t(`opus`, "opus"),
+	t(`mp3`, "mp3"),
+}
+
+var hdrFlags = []tag{
+	t(`hdr10\+|hdr10plus`, "HDR10+"),
+	t(`hdr10|hdr`, "HDR10"),
+	t(`dv|dovi|dolby[.\s_-]?vision`, "DV"),
+	t(`hlg`, "HLG"),
+}
+
+var (
+	reRemux  = regexp.MustCompile(pre + `(?:remux|bdremux)` + post)
+	reProper = regexp.MustCompile(pre + `proper` + post)
+	reRepack = regexp.MustCompile(pre + `(?:repack|rerip)` + post)
+
+	// Scene style "...-GROUP" suffix, or anime style "[Group] Title".
+	reGroupSuffix = regexp.MustCompile(`-([A-Za-z0-9]+)(?:\[[^\]]*\])?$`)
+	reGroupPrefix = regexp.MustCompile(`^\[([^\]]+)\]`)
+)
+
+// Parse extracts release attributes from a file name or path.
+func Parse(name string) Info {
+	base := filepath.Base(name)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+
+	var info Info
+	info.Resolution = first(resolutions, base)
+	info.Source = first(sources, base)
+	info.VideoCodec = first(videoCodecs, base)
+	info.AudioCodec = first(audioCodecs, base)
+
+	for _, h := range hdrFlags {
+		if h.re.MatchString(base) && !contains(info.HDR, h.value) {
+			// "HDR10+" also matches the plain HDR10 pattern.
+			if h.value == "HDR10" && contains(info.HDR, "HDR10+") {
+				continue
+			}
+			info.HDR = append(info.HDR, h.value)
+		}
+	}
+
+	info.Remux = reRemux.MatchString(base)
+	info.Proper = reProper.MatchString(base)
+	info.Repack = reRepack.MatchString(base)
+	info.Group = parseGroup(base)
+
+	return info
+}
+
+// HDRString joins the HDR flags as stored in the database ("DV,HDR10").
+func (i Info) HDRString() string {
+	return strings.Join(i.HDR, ",")
+}
+
+func first(tags []tag, s string) string {
+	for _, tg := range tags {
+		if tg.re.MatchString(s) {
+			return tg.value
+		}
+	}
+	return ""
+}
+
+func contains(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Tokens that look like a "-GROUP" suffix but are part of a tag.
+var notGroups = map[string]bool{
+	"dl": true, "rip": true, "ray": true, "hd": true, "ma": true,
+	"x": true, "ac3": true, "eac3": true, "web": true,
+}
+
+func parseGroup(base string) string {
+	if m := reGroupPrefix.FindStringSubmatch(base); len(m) == 2 {
+		return strings.TrimSpace(m[1])
+	}
+
+	// Only dotted/scene names carry a trailing group; "Title - 2021" does not.
+	if strings.Contains(base, " - ") {
+		return ""
+	}
+
+	m := reGroupSuffix.FindStringSubmatch(base)
+	if len(m) != 2 {
+		return ""
+	}
+	g := m[1]
+	if notGroups[strings.ToLower(g)] || isDigits(g) {
+		return ""
+	}
+	for _, tags := range [][]tag{resolutions, sources, videoCodecs, audioCodecs, hdrFlags} {
+		for _, tg := range tags {
+			if tg.re.MatchString("." + g) {
+				return ""
+			}
+		}
+	}
+	return g
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,159 @@
+// Package relocate moves a library root, for when its share is mounted
+// somewhere else. Stored paths are rewritten by prefix; a sample of
+// the new paths must be found on disk before the change is committed.
+package relocate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bastianvv/vio/internal/domain"
+	"github.com/bastianvv/vio/internal/store"
+)
+
+// sampleSize files are checked, spread evenly over the library. Up to a
+// tenth of them may be absent, for files deleted since the last scan.
+const sampleSize = 20
+
+var (
+	ErrNotDirectory = errors.New("new library path is not a directory")
+	ErrPathTaken    = errors.New("new library path overlaps another library root")
+	ErrUnknownRoot  = errors.New("not a root of this library")
+)
+
+// SampleError is returned when too many sampled files are absent from the
+// new root; nothing has been changed.
+type SampleError struct {
+	Checked int
+	Absent  []string // new paths that were not found
+}
+
+func (e *SampleError) Error() string {
+	return fmt.Sprintf("%d of %d sampled files not found under the new path, e.g. %s",
+		len(e.Absent), e.Checked, e.Absent[0])
+}
+
+type Result struct {
+	Library    *domain.Library
+	MediaFiles int
+	Sidecars   int
+	Checked    int
+	Absent     []string
+}
+
+// Relocate moves the library root from to path; an empty from names the
+// first root. Files that are already missing are rewritten too but not
+// sampled, and no file on disk is touched.
+func Relocate(s store.Store, libraryID int64, from, path string) (*Result, error) {
+	path = filepath.Clean(path)
+
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return nil, ErrNotDirectory
+	}
+
+	libs, err := s.ListLibraries()
+	if err != nil {
+		return nil, err
+	}
+	var lib *domain.Library
+	for i := range libs {
+		if libs[i].ID == libraryID {
+			lib = &libs[i]
+		}
+	}
+	if lib == nil {
+		return nil, sql.ErrNoRows
+	}
+	if from == "" {
+		from = lib.Path
+	}
+	if !slices.Contains(lib.Roots, from) {
+		return nil, ErrUnknownRoot
+	}
+
+	// Roots may not nest: a file under both would belong to two of them.
+	for _, l := range libs {
+		for _, r := range l.Roots {
+			if l.ID == libraryID && r == from {
+				continue
+			}
+			if store.UnderRoot(path, r) || store.UnderRoot(r, path) {
+				return nil, ErrPathTaken
+			}
+		}
+	}
+
+	// Check the disk before touching the database: sample the stored
+	// paths and look for them under the new root.
+	old, err := sample(s, libraryID, from)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{Checked: len(old)}
+	for _, p := range old {
+		moved := p
+		if store.UnderRoot(p, from) {
+			moved = path + strings.TrimPrefix(p, from)
+		}
+		if _, err := os.Stat(moved); err != nil {
+			res.Absent = append(res.Absent, moved)
+		}
+	}
+	if len(res.Absent) > len(old)/10 {
+		return nil, &SampleError{Checked: res.Checked, Absent: res.Absent}
+	}
+
+	err = s.WithTx(func(tx store.Store) error {
+		var err error
+		res.MediaFiles, res.Sidecars, err = tx.RelocateLibrary(libraryID, from, path)
+		if err != nil {
+			return err
+		}
+		res.Library, err = tx.GetLibrary(libraryID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// sample picks present files spread over the root, with their sidecar
+// subtitles, and returns their stored paths. Other roots are not sampled:
+// their disks may be offline.
+func sample(s store.Store, libraryID int64, root string) ([]string, error) {
+	present := false
+	all, _, err := s.QueryMediaFiles(store.ListFilter{LibraryID: libraryID, Missing: &present}, store.Page{})
+	if err != nil {
+		return nil, err
+	}
+	var files []domain.MediaFile
+	for _, mf := range all {
+		if strings.HasPrefix(mf.Path, root+string(filepath.Separator)) {
+			files = append(files, mf)
+		}
+	}
+
+	step := max(len(files)/sampleSize, 1)
+	var paths []string
+	for i := 0; i < len(files) && i/step < sampleSize; i += step {
+		paths = append(paths, files[i].Path)
+
+		tracks, err := s.ListSubtitleTracks(files[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, st := range tracks {
+			if st.Source == domain.SubtitleSourceExternal && st.ExternalPath != nil {
+				paths = append(paths, *st.ExternalPath)
+			}
+		}
+	}
+	return paths, nil
+}
@@ -62,3 +62,15 @@ func (r *Registry) Get(jobID string) (*Job, bool) {
 	job, ok := r.jobs[jobID]
 	return job, ok
 }
+
+// Active reports whether a scan of the library is still running.
+func (r *Registry) Active(libraryID int64) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, job := range r.jobs {
+		if job.LibraryID == libraryID && job.Status == JobRunning {
+			return true
+		}
+	}
+	return false
+}
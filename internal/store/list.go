@@ -0,0 +1,441 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bastianvv/vio/internal/domain"
+)
+
+// SortKey names the order of a Query* listing.
+type SortKey string
+
+const (
+	SortTitle   SortKey = "title"
+	SortYear    SortKey = "year"
+	SortAdded   SortKey = "added"   // created_at
+	SortRuntime SortKey = "runtime" // duration for files
+	SortNumber  SortKey = "number"  // episodes only
+	SortPath    SortKey = "path"    // files only
+)
+
+// ErrInvalidSort is returned for a sort key the listing does not support.
+var ErrInvalidSort = errors.New("invalid sort")
+
+// Page picks one window of a sorted listing. The zero Page is the whole
+// listing in its default order.
+type Page struct {
+	Sort   SortKey
+	Desc   bool
+	Limit  int // 0 means no limit
+	Offset int
+}
+
+// ListFilter narrows a Query* listing; zero fields don't filter.
+//
+// Resolution and Codec match a movie, series or episode with a present
+// file of that resolution or codec, and Missing one with (or without) a
+// missing file. On files they match the file itself. Year and HasTMDB don't
+// apply to files, nor Year to episodes.
+type ListFilter struct {
+	LibraryID  int64
+	SeasonID   int64 // episodes only
+	YearMin    int
+	YearMax    int
+	Resolution string // 2160p, 1080p, 720p, 576p or 480p
+	Codec      string // video codec as ffprobe names it: hevc, h264, av1...
+	HasTMDB    *bool
+	Missing    *bool
+}
+
+func (f ListFilter) fileFilter() bool {
+	return f.Resolution != "" || f.Codec != ""
+}
+
+// fileResolution is a file's resolution class: from the probed frame size,
+// by width first so letterboxed films keep their class, or else from the
+// release name, where an interlaced 1080i counts as 1080p. resolutionSQL is
+// the same in SQL.
+func fileResolution(mf *domain.MediaFile) string {
+	w, h := mf.VideoWidth, mf.VideoHeight
+	switch {
+	case w >= 3200 || h >= 2000:
+		return "2160p"
+	case w >= 1800 || h >= 1000:
+		return "1080p"
+	case w >= 1200 || h >= 700:
+		return "720p"
+	case h >= 540:
+		return "576p"
+	case w > 0 || h > 0:
+		return "480p"
+	}
+	return strings.ReplaceAll(strings.ToLower(mf.ReleaseResolution), "i", "p")
+}
+
+// fileCodec prefers the probed video codec over the release name's.
+func fileCodec(mf *domain.MediaFile) string {
+	if mf.VideoCodec != "" {
+		return strings.ToLower(mf.VideoCodec)
+	}
+	return strings.ToLower(mf.ReleaseVideoCodec)
+}
+
+func resolutionSQL(alias string) string {
+	return strings.ReplaceAll(`CASE
+            WHEN f.video_width >= 3200 OR f.video_height >= 2000 THEN '2160p'
+            WHEN f.video_width >= 1800 OR f.video_height >= 1000 THEN '1080p'
+            WHEN f.video_width >= 1200 OR f.video_height >= 700 THEN '720p'
+            WHEN f.video_height >= 540 THEN '576p'
+            WHEN f.video_width > 0 OR f.video_height > 0 THEN '480p'
+            ELSE REPLACE(LOWER(f.release_resolution), 'i', 'p')
+        END`, "f.", alias+".")
+}
+
+func codecSQL(alias string) string {
+	return fmt.Sprintf(`LOWER(COALESCE(NULLIF(%[1]s.video_codec, ''), %[1]s.release_video_codec))`, alias)
+}
+
+// listQuery collects the WHERE clause of a listing.
+type listQuery struct {
+	where []string
+	args  []any
+}
+
+func (q *listQuery) add(cond string, args ...any) {
+	q.where = append(q.where, cond)
+	q.args = append(q.args, args...)
+}
+
+func (q *listQuery) whereSQL() string {
+	if len(q.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.where, " AND ")
+}
+
+func (q *listQuery) years(col string, f ListFilter) {
+	if f.YearMin != 0 {
+		q.add(`COALESCE(`+col+`, 0) >= ?`, f.YearMin)
+	}
+	if f.YearMax != 0 {
+		q.add(`COALESCE(`+col+`, 0) <= ?`, f.YearMax)
+	}
+}
+
+func (q *listQuery) hasTMDB(col string, f ListFilter) {
+	if f.HasTMDB == nil {
+		return
+	}
+	cond := `(` + col + ` IS NOT NULL AND ` + col + ` <> '')`
+	if !*f.HasTMDB {
+		cond = `NOT ` + cond
+	}
+	q.add(cond)
+}
+
+// files adds the file filters for a catalog row, given the FROM and WHERE
+// of a subquery reaching its files as f.
+func (q *listQuery) files(from string, f ListFilter) {
+	if f.fileFilter() {
+		var conds []string
+		var args []any
+		if f.Resolution != "" {
+			conds = append(conds, resolutionSQL("f")+` = ?`)
+			args = append(args, strings.ToLower(f.Resolution))
+		}
+		if f.Codec != "" {
+			conds = append(conds, codecSQL("f")+` = ?`)
+			args = append(args, strings.ToLower(f.Codec))
+		}
+		q.add(`EXISTS (SELECT 1 FROM `+from+` AND f.is_missing = FALSE AND `+
+			strings.Join(conds, " AND ")+`)`, args...)
+	}
+	if f.Missing != nil {
+		cond := `EXISTS (SELECT 1 FROM ` + from + ` AND f.is_missing = TRUE)`
+		if !*f.Missing {
+			cond = `NOT ` + cond
+		}
+		q.add(cond)
+	}
+}
+
+// orderSQL builds ORDER BY, LIMIT and OFFSET for p from the columns each
+// sort key orders by, def when p has none. The ID column breaks ties.
+func (s *sqlStore) orderSQL(p Page, sorts map[SortKey][]string, def SortKey, id string) (string, []any, error) {
+	key := p.Sort
+	if key == "" {
+		key = def
+	}
+	cols, ok := sorts[key]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %q", ErrInvalidSort, p.Sort)
+	}
+
+	dir := ""
+	if p.Desc {
+		dir = " DESC"
+	}
+	var order []string
+	for _, c := range append(slices.Clone(cols), id) {
+		order = append(order, c+dir)
+	}
+	out := " ORDER BY " + strings.Join(order, ", ")
+
+	var args []any
+	switch {
+	case p.Limit > 0:
+		out += ` LIMIT ? OFFSET ?`
+		args = append(args, p.Limit, p.Offset)
+	case p.Offset > 0 && s.dialect == dialectSQLite:
+		out += ` LIMIT -1 OFFSET ?` // SQLite has no OFFSET without LIMIT
+		args = append(args, p.Offset)
+	case p.Offset > 0:
+		out += ` OFFSET ?`
+		args = append(args, p.Offset)
+	}
+	return out, args, nil
+}
+
+func (s *sqlStore) count(from string, q *listQuery) (int, error) {
+	var n int
+	err := s.read.QueryRow(`SELECT COUNT(*) FROM `+from+q.whereSQL(), q.args...).Scan(&n)
+	return n, err
+}
+
+// ============================================================================
+// Movies, series, episodes and files
+// ============================================================================
+
+var movieSorts = map[SortKey][]string{
+	SortTitle:   {"m.title", "COALESCE(m.year, 0)"},
+	SortYear:    {"COALESCE(m.year, 0)", "m.title"},
+	SortAdded:   {"m.created_at"},
+	SortRuntime: {"COALESCE(m.runtime_min, 0)", "m.title"},
+}
+
+func (s *sqlStore) QueryMovies(f ListFilter, p Page) ([]domain.Movie, int, error) {
+	var q listQuery
+	if f.LibraryID != 0 {
+		q.add(`m.library_id = ?`, f.LibraryID)
+	}
+	q.years("m.year", f)
+	q.hasTMDB("m.tmdb_id", f)
+	q.files(`media_files f WHERE f.movie_id = m.id`, f)
+
+	order, orderArgs, err := s.orderSQL(p, movieSorts, SortTitle, "m.id")
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.count(`movies m`, &q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.read.Query(`
+        SELECT m.id, m.library_id, m.title, m.original_title, m.year, m.tmdb_id,
+               m.overview, m.runtime_min, m.poster_path, m.backdrop_path,
+               m.created_at, m.updated_at
+        FROM movies m`+q.whereSQL()+order, append(q.args, orderArgs...)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var result []domain.Movie
+	for rows.Next() {
+		var m domain.Movie
+		err := rows.Scan(
+			&m.ID, &m.LibraryID, &m.Title, &m.OriginalTitle, &m.Year, &m.TMDBID,
+			&m.Overview, &m.RuntimeMin, &m.PosterPath, &m.BackdropPath,
+			&m.CreatedAt, &m.UpdatedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		result = append(result, m)
+	}
+	return result, total, rows.Err()
+}
+
+var seriesSorts = map[SortKey][]string{
+	SortTitle: {"sr.title", "sr.year"},
+	SortYear:  {"sr.year", "sr.title"},
+	SortAdded: {"sr.created_at"},
+}
+
+func (s *sqlStore) QuerySeries(f ListFilter, p Page) ([]domain.Series, int, error) {
+	var q listQuery
+	if f.LibraryID != 0 {
+		q.add(`sr.library_id = ?`, f.LibraryID)
+	}
+	q.years("sr.year", f)
+	q.hasTMDB("sr.tmdb_id", f)
+	q.files(`media_files f
+        JOIN media_file_episodes l ON l.media_file_id = f.id
+        JOIN episodes fe ON fe.id = l.episode_id
+        JOIN seasons fs ON fs.id = fe.season_id
+        WHERE fs.series_id = sr.id`, f)
+
+	order, orderArgs, err := s.orderSQL(p, seriesSorts, SortTitle, "sr.id")
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.count(`series sr`, &q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.read.Query(`
+        SELECT sr.id, sr.library_id, sr.title, sr.original_title, sr.year, sr.tmdb_id,
+               sr.tvdb_id, sr.overview, sr.status, sr.poster_path, sr.backdrop_path,
+               sr.created_at, sr.updated_at
+        FROM series sr`+q.whereSQL()+order, append(q.args, orderArgs...)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var result []domain.Series
+	for rows.Next() {
+		var sr domain.Series
+		err := rows.Scan(
+			&sr.ID, &sr.LibraryID, &sr.Title, &sr.OriginalTitle, &sr.Year, &sr.TMDBID,
+			&sr.TVDBID, &sr.Overview, &sr.Status, &sr.PosterPath, &sr.BackdropPath,
+			&sr.CreatedAt, &sr.UpdatedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		result = append(result, sr)
+	}
+	return result, total, rows.Err()
+}
+
+var episodeSorts = map[SortKey][]string{
+	SortNumber:  {"e.episode_number"},
+	SortTitle:   {"e.title", "e.episode_number"},
+	SortAdded:   {"e.created_at"},
+	SortRuntime: {"COALESCE(e.runtime_min, 0)", "e.episode_number"},
+}
+
+func (s *sqlStore) QueryEpisodes(f ListFilter, p Page) ([]domain.Episode, int, error) {
+	var q listQuery
+	if f.SeasonID != 0 {
+		q.add(`e.season_id = ?`, f.SeasonID)
+	}
+	if f.LibraryID != 0 {
+		q.add(`e.season_id IN (
+            SELECT se.id FROM seasons se
+            JOIN series sr ON sr.id = se.series_id
+            WHERE sr.library_id = ?)`, f.LibraryID)
+	}
+	q.hasTMDB("e.tmdb_id", f)
+	q.files(`media_files f
+        JOIN media_file_episodes l ON l.media_file_id = f.id
+        WHERE l.episode_id = e.id`, f)
+
+	order, orderArgs, err := s.orderSQL(p, episodeSorts, SortNumber, "e.id")
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.count(`episodes e`, &q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.read.Query(`
+        SELECT e.id, e.season_id, e.episode_number, e.title, e.overview,
+               e.air_date, e.runtime_min, e.still_path, e.tmdb_id, e.title_source,
+               e.created_at, e.updated_at
+        FROM episodes e`+q.whereSQL()+order, append(q.args, orderArgs...)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var result []domain.Episode
+	for rows.Next() {
+		var ep domain.Episode
+		err := rows.Scan(
+			&ep.ID, &ep.SeasonID, &ep.Number, &ep.Title, &ep.Overview,
+			&ep.AirDate, &ep.RuntimeMin, &ep.StillPath, &ep.TMDBID, &ep.TitleSource,
+			&ep.CreatedAt, &ep.UpdatedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		result = append(result, ep)
+	}
+	return result, total, rows.Err()
+}
+
+var mediaFileSorts = map[SortKey][]string{
+	SortPath:    {"mf.path"},
+	SortAdded:   {"mf.created_at"},
+	SortRuntime: {"COALESCE(mf.duration_sec, 0)", "mf.path"},
+}
+
+func (s *sqlStore) QueryMediaFiles(f ListFilter, p Page) ([]domain.MediaFile, int, error) {
+	var q listQuery
+	if f.LibraryID != 0 {
+		q.add(`mf.library_id = ?`, f.LibraryID)
+	}
+	if f.Resolution != "" {
+		q.add(resolutionSQL("mf")+` = ?`, strings.ToLower(f.Resolution))
+	}
+	if f.Codec != "" {
+		q.add(codecSQL("mf")+` = ?`, strings.ToLower(f.Codec))
+	}
+	if f.Missing != nil {
+		q.add(`mf.is_missing = ?`, *f.Missing)
+	}
+
+	order, orderArgs, err := s.orderSQL(p, mediaFileSorts, SortPath, "mf.id")
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.count(`media_files mf`, &q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.read.Query(`
+        SELECT mf.id, mf.library_id, mf.movie_id, mf.episode_id, mf.path, mf.size_bytes,
+               mf.hash, mf.is_missing, mf.last_seen_at, mf.missing_since, mf.container,
+               mf.video_codec, mf.audio_codec, mf.video_width, mf.video_height,
+               mf.audio_channels, mf.duration_sec, mf.bit_rate,
+               mf.release_resolution, mf.release_source, mf.release_video_codec,
+               mf.release_audio_codec, mf.release_hdr, mf.release_group,
+               mf.is_remux, mf.is_proper, mf.is_repack,
+               mf.probe_status, mf.probe_error, mf.probe_attempts, mf.next_probe_at,
+               mf.created_at, mf.updated_at
+        FROM media_files mf`+q.whereSQL()+order, append(q.args, orderArgs...)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var result []domain.MediaFile
+	for rows.Next() {
+		var mf domain.MediaFile
+		err := rows.Scan(
+			&mf.ID, &mf.LibraryID, &mf.MovieID, &mf.EpisodeID, &mf.Path, &mf.SizeBytes,
+			&mf.Hash, &mf.IsMissing, &mf.LastSeenAt, &mf.MissingSince, &mf.Container,
+			&mf.VideoCodec, &mf.AudioCodec, &mf.VideoWidth, &mf.VideoHeight,
+			&mf.AudioChannels, &mf.DurationSec, &mf.BitRate,
+			&mf.ReleaseResolution, &mf.ReleaseSource, &mf.ReleaseVideoCodec,
+			&mf.ReleaseAudioCodec, &mf.ReleaseHDR, &mf.ReleaseGroup,
+			&mf.IsRemux, &mf.IsProper, &mf.IsRepack,
+			&mf.ProbeStatus, &mf.ProbeError, &mf.ProbeAttempts, &mf.NextProbeAt,
+			&mf.CreatedAt, &mf.UpdatedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		result = append(result, mf)
+	}
+	return result, total, rows.Err()
+}
@@ -0,0 +1,1479 @@
+package store
+
+import (
+	"cmp"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bastianvv/vio/internal/domain"
+)
+
+// MemoryStore keeps everything in maps, for fast scanner and handler tests
+// (tests/media, tests/http).
+// It enforces the same unique keys, foreign keys, cascades and lookup
+// conventions as the SQL stores; storetest checks that they agree.
+type MemoryStore struct {
+	mu   *sync.Mutex   // serializes writers; held for a whole WithTx
+	rw   *sync.RWMutex // guards data against concurrent reads
+	data *memData
+	inTx bool // the caller of WithTx already holds mu
+}
+
+type memData struct {
+	seq map[string]int64 // per-table ID sequences
+
+	libraries         map[int64]domain.Library
+	movies            map[int64]domain.Movie
+	series            map[int64]domain.Series
+	seasons           map[int64]domain.Season
+	episodes          map[int64]domain.Episode
+	mediaFiles        map[int64]domain.MediaFile
+	mediaFileEpisodes map[int64]domain.MediaFileEpisode
+	subtitles         map[int64]domain.SubtitleTrack
+	audio             map[int64]domain.AudioTrack
+	video             map[int64]domain.VideoStream
+	chapters          map[int64]domain.Chapter
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mu: &sync.Mutex{},
+		rw: &sync.RWMutex{},
+		data: &memData{
+			seq:               make(map[string]int64),
+			libraries:         make(map[int64]domain.Library),
+			movies:            make(map[int64]domain.Movie),
+			series:            make(map[int64]domain.Series),
+			seasons:           make(map[int64]domain.Season),
+			episodes:          make(map[int64]domain.Episode),
+			mediaFiles:        make(map[int64]domain.MediaFile),
+			mediaFileEpisodes: make(map[int64]domain.MediaFileEpisode),
+			subtitles:         make(map[int64]domain.SubtitleTrack),
+			audio:             make(map[int64]domain.AudioTrack),
+			video:             make(map[int64]domain.VideoStream),
+			chapters:          make(map[int64]domain.Chapter),
+		},
+	}
+}
+
+func (d *memData) clone() *memData {
+	return &memData{
+		seq:               maps.Clone(d.seq),
+		libraries:         maps.Clone(d.libraries),
+		movies:            maps.Clone(d.movies),
+		series:            maps.Clone(d.series),
+		seasons:           maps.Clone(d.seasons),
+		episodes:          maps.Clone(d.episodes),
+		mediaFiles:        maps.Clone(d.mediaFiles),
+		mediaFileEpisodes: maps.Clone(d.mediaFileEpisodes),
+		subtitles:         maps.Clone(d.subtitles),
+		audio:             maps.Clone(d.audio),
+		video:             maps.Clone(d.video),
+		chapters:          maps.Clone(d.chapters),
+	}
+}
+
+func (d *memData) nextID(table string) int64 {
+	d.seq[table]++
+	return d.seq[table]
+}
+
+func (s *MemoryStore) lock() func() {
+	if s.inTx {
+		return func() {}
+	}
+	s.mu.Lock()
+	s.rw.Lock()
+	return func() {
+		s.rw.Unlock()
+		s.mu.Unlock()
+	}
+}
+
+func (s *MemoryStore) rlock() func() {
+	if s.inTx {
+		return func() {}
+	}
+	s.rw.RLock()
+	return s.rw.RUnlock
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// WithTx runs fn on a copy of the data and keeps the copy only if fn
+// succeeds. Writers wait for the transaction, like SQLite's single writer
+// connection; readers keep seeing the last committed data. Nested calls
+// behave as savepoints.
+func (s *MemoryStore) WithTx(fn func(tx Store) error) error {
+	if s.inTx {
+		sp := &MemoryStore{mu: s.mu, rw: s.rw, data: s.data.clone(), inTx: true}
+		if err := fn(sp); err != nil {
+			return err
+		}
+		s.data = sp.data
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &MemoryStore{mu: s.mu, rw: s.rw, data: s.data.clone(), inTx: true}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	s.rw.Lock()
+	s.data = tx.data
+	s.rw.Unlock()
+	return nil
+}
+
+func errUnique(table string, cols ...string) error {
+	return fmt.Errorf("UNIQUE constraint failed: %s%v", table, cols)
+}
+
+var (
+	errForeignKey = errors.New("FOREIGN KEY constraint failed")
+	errCheck      = errors.New("CHECK constraint failed: media_files")
+)
+
+// list returns the values matching keep in ID order, then stably sorted by
+// less when given. Like the SQL stores it returns nil when nothing matches.
+func list[V any](m map[int64]V, keep func(*V) bool, less func(a, b *V) bool) []V {
+	var out []V
+	for _, id := range slices.Sorted(maps.Keys(m)) {
+		v := m[id]
+		if keep(&v) {
+			out = append(out, v)
+		}
+	}
+	if less != nil {
+		sort.SliceStable(out, func(i, j int) bool { return less(&out[i], &out[j]) })
+	}
+	return out
+}
+
+// first returns the lowest-ID value matching keep.
+func first[V any](m map[int64]V, keep func(*V) bool) (*V, bool) {
+	for _, v := range list(m, keep, nil) {
+		return &v, true
+	}
+	return nil, false
+}
+
+// ============================================================================
+// Libraries
+// ============================================================================
+
+// rootTaken reports whether another library has root among its roots.
+func (d *memData) rootTaken(root string, libraryID int64) bool {
+	_, ok := first(d.libraries, func(l *domain.Library) bool {
+		return l.ID != libraryID && slices.Contains(l.Roots, root)
+	})
+	return ok
+}
+
+func (s *MemoryStore) CreateLibrary(lib *domain.Library) error {
+	if err := setRoots(lib); err != nil {
+		return err
+	}
+
+	defer s.lock()()
+	d := s.data
+
+	for _, r := range lib.Roots {
+		if d.rootTaken(r, 0) {
+			return errUnique("library_roots", "path")
+		}
+	}
+
+	now := time.Now()
+	lib.CreatedAt = now
+	lib.UpdatedAt = now
+	lib.ID = d.nextID("libraries")
+	stored := *lib
+	stored.Roots = slices.Clone(lib.Roots)
+	d.libraries[lib.ID] = stored
+	return nil
+}
+
+func (s *MemoryStore) ListLibraries() ([]domain.Library, error) {
+	defer s.rlock()()
+	libs := list(s.data.libraries, func(*domain.Library) bool { return true }, nil)
+	for i := range libs {
+		libs[i].Roots = slices.Clone(libs[i].Roots)
+	}
+	return libs, nil
+}
+
+func (s *MemoryStore) GetLibrary(id int64) (*domain.Library, error) {
+	defer s.rlock()()
+	l, ok := s.data.libraries[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	l.Roots = slices.Clone(l.Roots)
+	return &l, nil
+}
+
+func (s *MemoryStore) UpdateLibrary(lib *domain.Library) error {
+	if lib.ID == 0 {
+		return errors.New("cannot update library without ID")
+	}
+	if err := setRoots(lib); err != nil {
+		return err
+	}
+
+	defer s.lock()()
+	d := s.data
+
+	old, ok := d.libraries[lib.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	for _, r := range lib.Roots {
+		if d.rootTaken(r, lib.ID) {
+			return errUnique("library_roots", "path")
+		}
+	}
+
+	now := time.Now().UTC()
+	old.Name, old.Type, old.Path, old.UpdatedAt = lib.Name, lib.Type, lib.Path, now
+	old.Roots = slices.Clone(lib.Roots)
+	d.libraries[lib.ID] = old
+	lib.UpdatedAt = now
+	return nil
+}
+
+func (s *MemoryStore) RelocateLibrary(id int64, from, to string) (files, sidecars int, err error) {
+	defer s.lock()()
+	d := s.data
+
+	lib, ok := d.libraries[id]
+	if !ok {
+		return 0, 0, sql.ErrNoRows
+	}
+	i := slices.Index(lib.Roots, from)
+	if i < 0 {
+		return 0, 0, sql.ErrNoRows
+	}
+	if d.rootTaken(to, id) || slices.Contains(lib.Roots, to) && to != from {
+		return 0, 0, errUnique("library_roots", "path")
+	}
+
+	root := strings.TrimSuffix(from, string(filepath.Separator))
+	rebase := func(p string) (string, bool) {
+		if p == root {
+			return to, true
+		}
+		if rest, ok := strings.CutPrefix(p, root+string(filepath.Separator)); ok {
+			return to + string(filepath.Separator) + rest, true
+		}
+		return p, false
+	}
+
+	now := time.Now().UTC()
+	moved := make(map[int64]domain.MediaFile)
+	for mid, mf := range d.mediaFiles {
+		if mf.LibraryID != id {
+			continue
+		}
+		if p, ok := rebase(mf.Path); ok {
+			mf.Path, mf.UpdatedAt = p, now
+			moved[mid] = mf
+		}
+	}
+	for mid, mf := range d.mediaFiles {
+		if _, ok := moved[mid]; !ok {
+			if _, ok := first(moved, func(x *domain.MediaFile) bool { return x.Path == mf.Path }); ok {
+				return 0, 0, errUnique("media_files", "path")
+			}
+		}
+	}
+	maps.Copy(d.mediaFiles, moved)
+
+	for sid, st := range d.subtitles {
+		if st.ExternalPath == nil || d.mediaFiles[st.MediaFileID].LibraryID != id {
+			continue
+		}
+		if p, ok := rebase(*st.ExternalPath); ok {
+			st.ExternalPath = &p
+			d.subtitles[sid] = st
+			sidecars++
+		}
+	}
+
+	lib.Roots = slices.Clone(lib.Roots)
+	lib.Roots[i] = to
+	if i == 0 {
+		lib.Path, lib.UpdatedAt = to, now
+	}
+	d.libraries[id] = lib
+	return len(moved), sidecars, nil
+}
+
+func (s *MemoryStore) DeleteLibrary(id int64) error {
+	defer s.lock()()
+	d := s.data
+
+	if _, ok := d.libraries[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(d.libraries, id)
+
+	maps.DeleteFunc(d.movies, func(_ int64, m domain.Movie) bool { return m.LibraryID == id })
+	for sid, sr := range d.series {
+		if sr.LibraryID == id {
+			d.deleteSeries(sid)
+		}
+	}
+	for mid, mf := range d.mediaFiles {
+		if mf.LibraryID == id {
+			d.deleteMediaFile(mid)
+		}
+	}
+	return nil
+}
+
+// ============================================================================
+// Movies
+// ============================================================================
+
+func (s *MemoryStore) CreateMovie(m *domain.Movie) error {
+	defer s.lock()()
+	d := s.data
+
+	if _, ok := d.libraries[m.LibraryID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := first(d.movies, func(x *domain.Movie) bool {
+		return x.LibraryID == m.LibraryID && x.Title == m.Title && x.Year == m.Year
+	}); ok {
+		return errUnique("movies", "library_id", "title", "year")
+	}
+
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	m.ID = d.nextID("movies")
+	d.movies[m.ID] = *m
+	return nil
+}
+
+func (s *MemoryStore) GetMovie(id int64) (*domain.Movie, error) {
+	defer s.rlock()()
+	m, ok := s.data.movies[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &m, nil
+}
+
+func (s *MemoryStore) GetMovieByTitleAndYear(title string, year int, libraryID int64) (*domain.Movie, error) {
+	defer s.rlock()()
+	m, _ := first(s.data.movies, func(x *domain.Movie) bool {
+		return x.Title == title && x.Year == year && x.LibraryID == libraryID
+	})
+	return m, nil
+}
+
+func (s *MemoryStore) ListMoviesByLibrary(libraryID int64) ([]domain.Movie, error) {
+	defer s.rlock()()
+	return list(s.data.movies,
+		func(m *domain.Movie) bool { return m.LibraryID == libraryID },
+		func(a, b *domain.Movie) bool { return a.Title < b.Title },
+	), nil
+}
+
+func (s *MemoryStore) UpdateMovie(m *domain.Movie) error {
+	defer s.lock()()
+	m.UpdatedAt = time.Now().UTC()
+
+	old, ok := s.data.movies[m.ID]
+	if !ok {
+		return nil
+	}
+	old.OriginalTitle = m.OriginalTitle
+	old.TMDBID = m.TMDBID
+	old.Overview = m.Overview
+	old.RuntimeMin = m.RuntimeMin
+	old.PosterPath = m.PosterPath
+	old.BackdropPath = m.BackdropPath
+	old.UpdatedAt = m.UpdatedAt
+	s.data.movies[m.ID] = old
+	return nil
+}
+
+// ============================================================================
+// Series / Seasons / Episodes
+// ============================================================================
+
+func (s *MemoryStore) CreateSeries(sr *domain.Series) error {
+	defer s.lock()()
+	d := s.data
+
+	if _, ok := d.libraries[sr.LibraryID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := first(d.series, func(x *domain.Series) bool {
+		return x.LibraryID == sr.LibraryID && x.Title == sr.Title && x.Year == sr.Year
+	}); ok {
+		return errUnique("series", "library_id", "title", "year")
+	}
+
+	now := time.Now()
+	sr.CreatedAt = now
+	sr.UpdatedAt = now
+	sr.ID = d.nextID("series")
+	d.series[sr.ID] = *sr
+	return nil
+}
+
+func (s *MemoryStore) GetSeries(id int64) (*domain.Series, error) {
+	defer s.rlock()()
+	sr, ok := s.data.series[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &sr, nil
+}
+
+func (s *MemoryStore) GetSeriesByTitleAndYear(title string, year int, libraryID int64) (*domain.Series, error) {
+	defer s.rlock()()
+	sr, _ := first(s.data.series, func(x *domain.Series) bool {
+		return x.Title == title && x.Year == year && x.LibraryID == libraryID
+	})
+	return sr, nil
+}
+
+func (s *MemoryStore) GetSeriesByTMDBID(tmdbID string, libraryID int64) (*domain.Series, error) {
+	defer s.rlock()()
+	sr, _ := first(s.data.series, func(x *domain.Series) bool {
+		return x.TMDBID != nil && *x.TMDBID == tmdbID && x.LibraryID == libraryID
+	})
+	return sr, nil
+}
+
+func (s *MemoryStore) GetSeriesByTVDBID(tvdbID string, libraryID int64) (*domain.Series, error) {
+	defer s.rlock()()
+	sr, _ := first(s.data.series, func(x *domain.Series) bool {
+		return x.TVDBID != nil && *x.TVDBID == tvdbID && x.LibraryID == libraryID
+	})
+	return sr, nil
+}
+
+func (s *MemoryStore) ListSeries() ([]*domain.Series, error) {
+	defer s.rlock()()
+	all := list(s.data.series,
+		func(*domain.Series) bool { return true },
+		func(a, b *domain.Series) bool {
+			if a.Title != b.Title {
+				return a.Title < b.Title
+			}
+			return a.Year < b.Year
+		},
+	)
+
+	var out []*domain.Series
+	for i := range all {
+		out = append(out, &all[i])
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) UpdateSeries(sr *domain.Series) error {
+	defer s.lock()()
+	sr.UpdatedAt = time.Now().UTC()
+
+	old, ok := s.data.series[sr.ID]
+	if !ok {
+		return nil
+	}
+	old.OriginalTitle = sr.OriginalTitle
+	old.TMDBID = sr.TMDBID
+	old.TVDBID = sr.TVDBID
+	old.Overview = sr.Overview
+	old.Status = sr.Status
+	old.PosterPath = sr.PosterPath
+	old.BackdropPath = sr.BackdropPath
+	old.UpdatedAt = sr.UpdatedAt
+	s.data.series[sr.ID] = old
+	return nil
+}
+
+func (s *MemoryStore) CreateSeason(se *domain.Season) error {
+	defer s.lock()()
+	d := s.data
+
+	if _, ok := d.series[se.SeriesID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := first(d.seasons, func(x *domain.Season) bool {
+		return x.SeriesID == se.SeriesID && x.Number == se.Number
+	}); ok {
+		return errUnique("seasons", "series_id", "season_number")
+	}
+
+	now := time.Now()
+	se.CreatedAt = now
+	se.UpdatedAt = now
+	se.ID = d.nextID("seasons")
+
+	stored := *se
+	stored.HasPoster = false // not a column
+	d.seasons[se.ID] = stored
+	return nil
+}
+
+func (s *MemoryStore) GetSeason(id int64) (*domain.Season, error) {
+	defer s.rlock()()
+	se, ok := s.data.seasons[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &se, nil
+}
+
+func (s *MemoryStore) GetSeasonBySeriesAndNumber(seriesID int64, number int) (*domain.Season, error) {
+	defer s.rlock()()
+	se, _ := first(s.data.seasons, func(x *domain.Season) bool {
+		return x.SeriesID == seriesID && x.Number == number
+	})
+	return se, nil
+}
+
+func (s *MemoryStore) ListSeasonsBySeries(seriesID int64) ([]domain.Season, error) {
+	defer s.rlock()()
+	return list(s.data.seasons,
+		func(se *domain.Season) bool { return se.SeriesID == seriesID },
+		func(a, b *domain.Season) bool { return a.Number < b.Number },
+	), nil
+}
+
+func (s *MemoryStore) UpdateSeason(se *domain.Season) error {
+	defer s.lock()()
+	se.UpdatedAt = time.Now().UTC()
+
+	old, ok := s.data.seasons[se.ID]
+	if !ok {
+		return nil
+	}
+	old.Title = se.Title
+	old.Overview = se.Overview
+	old.PosterPath = se.PosterPath
+	old.AirDate = se.AirDate
+	old.TMDBID = se.TMDBID
+	old.UpdatedAt = se.UpdatedAt
+	s.data.seasons[se.ID] = old
+	return nil
+}
+
+func (s *MemoryStore) CreateEpisode(ep *domain.Episode) error {
+	defer s.lock()()
+	d := s.data
+
+	if _, ok := d.seasons[ep.SeasonID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := first(d.episodes, func(x *domain.Episode) bool {
+		return x.SeasonID == ep.SeasonID && x.Number == ep.Number
+	}); ok {
+		return errUnique("episodes", "season_id", "episode_number")
+	}
+
+	now := time.Now()
+	ep.CreatedAt = now
+	ep.UpdatedAt = now
+	ep.ID = d.nextID("episodes")
+	d.episodes[ep.ID] = *ep
+	return nil
+}
+
+func (s *MemoryStore) GetEpisode(id int64) (*domain.Episode, error) {
+	defer s.rlock()()
+	ep, ok := s.data.episodes[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &ep, nil
+}
+
+func (s *MemoryStore) GetEpisodeBySeasonAndNumber(seasonID int64, number int) (*domain.Episode, error) {
+	defer s.rlock()()
+	ep, _ := first(s.data.episodes, func(x *domain.Episode) bool {
+		return x.SeasonID == seasonID && x.Number == number
+	})
+	return ep, nil
+}
+
+func (s *MemoryStore) ListEpisodesBySeason(seasonID int64) ([]domain.Episode, error) {
+	defer s.rlock()()
+	return list(s.data.episodes,
+		func(ep *domain.Episode) bool { return ep.SeasonID == seasonID },
+		func(a, b *domain.Episode) bool { return a.Number < b.Number },
+	), nil
+}
+
+func (s *MemoryStore) UpdateEpisode(e *domain.Episode) error {
+	defer s.lock()()
+	e.UpdatedAt = time.Now().UTC()
+
+	old, ok := s.data.episodes[e.ID]
+	if !ok {
+		return nil
+	}
+	old.Title = e.Title
+	old.Overview = e.Overview
+	old.AirDate = e.AirDate
+	old.RuntimeMin = e.RuntimeMin
+	old.StillPath = e.StillPath
+	old.TMDBID = e.TMDBID
+	old.TitleSource = e.TitleSource
+	old.UpdatedAt = e.UpdatedAt
+	s.data.episodes[e.ID] = old
+	return nil
+}
+
+// ============================================================================
+// Media Files
+// ============================================================================
+
+func (d *memData) checkMediaFileRefs(mf *domain.MediaFile) error {
+	if _, ok := d.libraries[mf.LibraryID]; !ok {
+		return errForeignKey
+	}
+	if mf.MovieID != nil {
+		if _, ok := d.movies[*mf.MovieID]; !ok {
+			return errForeignKey
+		}
+	}
+	if mf.EpisodeID != nil {
+		if _, ok := d.episodes[*mf.EpisodeID]; !ok {
+			return errForeignKey
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) CreateMediaFile(mf *domain.MediaFile) error {
+	defer s.lock()()
+	d := s.data
+
+	if err := d.checkMediaFileRefs(mf); err != nil {
+		return err
+	}
+	if mf.IsMissing != (mf.MissingSince != nil) {
+		return errCheck
+	}
+	if _, ok := first(d.mediaFiles, func(x *domain.MediaFile) bool { return x.Path == mf.Path }); ok {
+		return errUnique("media_files", "path")
+	}
+
+	now := time.Now().UTC()
+	mf.CreatedAt = now
+	mf.UpdatedAt = now
+	mf.ID = d.nextID("media_files")
+
+	stored := *mf
+	stored.ProbeStatus = probeStatus(mf.ProbeStatus)
+	d.mediaFiles[mf.ID] = stored
+	return nil
+}
+
+func (s *MemoryStore) GetMediaFile(id int64) (*domain.MediaFile, error) {
+	defer s.rlock()()
+	mf, ok := s.data.mediaFiles[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &mf, nil
+}
+
+func (s *MemoryStore) GetMediaFileByPath(path string) (*domain.MediaFile, error) {
+	defer s.rlock()()
+	mf, _ := first(s.data.mediaFiles, func(x *domain.MediaFile) bool { return x.Path == path })
+	return mf, nil
+}
+
+func (s *MemoryStore) ListMediaFilesByMovie(movieID int64) ([]domain.MediaFile, error) {
+	defer s.rlock()()
+	return list(s.data.mediaFiles, func(mf *domain.MediaFile) bool {
+		return mf.MovieID != nil && *mf.MovieID == movieID
+	}, nil), nil
+}
+
+func (s *MemoryStore) ListMediaFilesByEpisode(episodeID int64) ([]domain.MediaFile, error) {
+	defer s.rlock()()
+	return list(s.data.mediaFiles, func(mf *domain.MediaFile) bool {
+		return mf.EpisodeID != nil && *mf.EpisodeID == episodeID
+	}, nil), nil
+}
+
+func (s *MemoryStore) ListMediaFilesWithProbeErrors(libraryID int64) ([]domain.MediaFile, error) {
+	defer s.rlock()()
+	return list(s.data.mediaFiles,
+		func(mf *domain.MediaFile) bool {
+			return mf.LibraryID == libraryID && mf.ProbeStatus == domain.ProbeStatusError && !mf.IsMissing
+		},
+		func(a, b *domain.MediaFile) bool { return a.Path < b.Path },
+	), nil
+}
+
+func (s *MemoryStore) UpdateMediaFile(mf *domain.MediaFile) error {
+	defer s.lock()()
+	d := s.data
+
+	old, ok := d.mediaFiles[mf.ID]
+	if !ok {
+		return nil
+	}
+
+	next := *mf
+	next.LibraryID = old.LibraryID
+	next.Path = old.Path
+	next.CreatedAt = old.CreatedAt
+	next.IsMissing = false
+	next.MissingSince = nil
+	next.ProbeStatus = probeStatus(mf.ProbeStatus)
+	next.UpdatedAt = time.Now().UTC()
+
+	if err := d.checkMediaFileRefs(&next); err != nil {
+		return err
+	}
+	d.mediaFiles[mf.ID] = next
+	return nil
+}
+
+func (s *MemoryStore) MarkMissingMediaFiles(libraryID int64, scanStartedAt time.Time, skipRoots ...string) (int64, error) {
+	defer s.lock()()
+	d := s.data
+
+	var n int64
+	for id, mf := range d.mediaFiles {
+		if mf.LibraryID != libraryID || mf.IsMissing {
+			continue
+		}
+		if slices.ContainsFunc(skipRoots, func(root string) bool { return UnderRoot(mf.Path, root) }) {
+			continue
+		}
+		if mf.LastSeenAt != nil && !mf.LastSeenAt.Before(scanStartedAt) {
+			continue
+		}
+		mf.IsMissing = true
+		if mf.MissingSince == nil {
+			t := scanStartedAt
+			mf.MissingSince = &t
+		}
+		d.mediaFiles[id] = mf
+		n++
+	}
+	return n, nil
+}
+
+func (s *MemoryStore) MarkMediaFileSeen(id int64, seenAt time.Time) error {
+	defer s.lock()()
+
+	mf, ok := s.data.mediaFiles[id]
+	if !ok {
+		return nil
+	}
+	mf.LastSeenAt = &seenAt
+	mf.IsMissing = false
+	mf.MissingSince = nil
+	mf.UpdatedAt = seenAt
+	s.data.mediaFiles[id] = mf
+	return nil
+}
+
+func (s *MemoryStore) CreateMediaFileEpisode(link *domain.MediaFileEpisode) error {
+	defer s.lock()()
+	d := s.data
+
+	if _, ok := d.mediaFiles[link.MediaFileID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := d.episodes[link.EpisodeID]; !ok {
+		return errForeignKey
+	}
+
+	// Already linked: ignored, like ON CONFLICT DO NOTHING.
+	if old, ok := first(d.mediaFileEpisodes, func(x *domain.MediaFileEpisode) bool {
+		return x.MediaFileID == link.MediaFileID && x.EpisodeID == link.EpisodeID
+	}); ok {
+		link.ID = old.ID
+		return nil
+	}
+
+	link.ID = d.nextID("media_file_episodes")
+	d.mediaFileEpisodes[link.ID] = *link
+	return nil
+}
+
+func (s *MemoryStore) ListEpisodesByMediaFile(mediaFileID int64) ([]domain.Episode, error) {
+	defer s.rlock()()
+	d := s.data
+
+	linked := make(map[int64]bool)
+	for _, l := range d.mediaFileEpisodes {
+		if l.MediaFileID == mediaFileID {
+			linked[l.EpisodeID] = true
+		}
+	}
+	return list(d.episodes,
+		func(ep *domain.Episode) bool { return linked[ep.ID] },
+		func(a, b *domain.Episode) bool { return a.Number < b.Number },
+	), nil
+}
+
+// ============================================================================
+// Subtitles
+// ============================================================================
+
+func (s *MemoryStore) CreateSubtitleTrack(st *domain.SubtitleTrack) error {
+	defer s.lock()()
+	d := s.data
+
+	if _, ok := d.mediaFiles[st.MediaFileID]; !ok {
+		return errForeignKey
+	}
+	if st.DerivedFromID != nil {
+		if _, ok := d.subtitles[*st.DerivedFromID]; !ok {
+			return errForeignKey
+		}
+	}
+
+	st.ID = d.nextID("subtitle_tracks")
+	stored := *st
+	stored.OffsetMs = 0 // not part of the insert
+	d.subtitles[st.ID] = stored
+	return nil
+}
+
+func (s *MemoryStore) ListSubtitleTracks(mediaFileID int64) ([]domain.SubtitleTrack, error) {
+	defer s.rlock()()
+	return list(s.data.subtitles, func(st *domain.SubtitleTrack) bool {
+		return st.MediaFileID == mediaFileID
+	}, nil), nil
+}
+
+func (s *MemoryStore) GetSubtitleTrack(id int64) (*domain.SubtitleTrack, error) {
+	defer s.rlock()()
+	st, ok := s.data.subtitles[id]
+	if !ok {
+		return nil, nil
+	}
+	return &st, nil
+}
+
+func (s *MemoryStore) UpdateSubtitleTrack(st *domain.SubtitleTrack) error {
+	defer s.lock()()
+
+	old, ok := s.data.subtitles[st.ID]
+	if !ok {
+		return nil
+	}
+	next := *st
+	next.MediaFileID = old.MediaFileID
+	next.OffsetMs = old.OffsetMs
+	next.DerivedFromID = old.DerivedFromID
+	s.data.subtitles[st.ID] = next
+	return nil
+}
+
+func (s *MemoryStore) SetSubtitleTrackOffset(id int64, offsetMs int64) error {
+	defer s.lock()()
+
+	st, ok := s.data.subtitles[id]
+	if !ok {
+		return nil
+	}
+	st.OffsetMs = offsetMs
+	s.data.subtitles[id] = st
+	return nil
+}
+
+func (s *MemoryStore) DeleteSubtitleTrack(id int64) error {
+	defer s.lock()()
+	s.data.deleteSubtitleTrack(id)
+	return nil
+}
+
+// deleteMediaFile cascades to the file's tracks, streams, chapters and
+// episode links.
+func (d *memData) deleteMediaFile(id int64) {
+	delete(d.mediaFiles, id)
+	maps.DeleteFunc(d.mediaFileEpisodes, func(_ int64, l domain.MediaFileEpisode) bool {
+		return l.MediaFileID == id
+	})
+	for sid, st := range d.subtitles {
+		if st.MediaFileID == id {
+			d.deleteSubtitleTrack(sid)
+		}
+	}
+	maps.DeleteFunc(d.audio, func(_ int64, at domain.AudioTrack) bool { return at.MediaFileID == id })
+	maps.DeleteFunc(d.video, func(_ int64, vs domain.VideoStream) bool { return vs.MediaFileID == id })
+	maps.DeleteFunc(d.chapters, func(_ int64, ch domain.Chapter) bool { return ch.MediaFileID == id })
+}
+
+// deleteSubtitleTrack cascades to tracks derived from the deleted one.
+func (d *memData) deleteSubtitleTrack(id int64) {
+	delete(d.subtitles, id)
+	for sid, st := range d.subtitles {
+		if st.DerivedFromID != nil && *st.DerivedFromID == id {
+			d.deleteSubtitleTrack(sid)
+		}
+	}
+}
+
+func (s *MemoryStore) CreateAudioTrack(at *domain.AudioTrack) error {
+	defer s.lock()()
+	d := s.data
+
+	if _, ok := d.mediaFiles[at.MediaFileID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := first(d.audio, func(x *domain.AudioTrack) bool {
+		return x.MediaFileID == at.MediaFileID && x.StreamIndex == at.StreamIndex
+	}); ok {
+		return errUnique("audio_tracks", "media_file_id", "stream_index")
+	}
+
+	at.ID = d.nextID("audio_tracks")
+	d.audio[at.ID] = *at
+	return nil
+}
+
+func (s *MemoryStore) ListAudioTracks(mediaFileID int64) ([]domain.AudioTrack, error) {
+	defer s.rlock()()
+	return list(s.data.audio,
+		func(at *domain.AudioTrack) bool { return at.MediaFileID == mediaFileID },
+		func(a, b *domain.AudioTrack) bool { return a.StreamIndex < b.StreamIndex },
+	), nil
+}
+
+func (s *MemoryStore) UpdateAudioTrack(at *domain.AudioTrack) error {
+	defer s.lock()()
+	d := s.data
+
+	old, ok := d.audio[at.ID]
+	if !ok {
+		return nil
+	}
+	if _, ok := first(d.audio, func(x *domain.AudioTrack) bool {
+		return x.MediaFileID == old.MediaFileID && x.StreamIndex == at.StreamIndex && x.ID != at.ID
+	}); ok {
+		return errUnique("audio_tracks", "media_file_id", "stream_index")
+	}
+
+	next := *at
+	next.MediaFileID = old.MediaFileID
+	d.audio[at.ID] = next
+	return nil
+}
+
+func (s *MemoryStore) DeleteAudioTrack(id int64) error {
+	defer s.lock()()
+	delete(s.data.audio, id)
+	return nil
+}
+
+// ============================================================================
+// Video Streams
+// ============================================================================
+
+func (s *MemoryStore) CreateVideoStream(vs *domain.VideoStream) error {
+	defer s.lock()()
+	d := s.data
+
+	if _, ok := d.mediaFiles[vs.MediaFileID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := first(d.video, func(x *domain.VideoStream) bool {
+		return x.MediaFileID == vs.MediaFileID && x.StreamIndex == vs.StreamIndex
+	}); ok {
+		return errUnique("video_streams", "media_file_id", "stream_index")
+	}
+
+	vs.ID = d.nextID("video_streams")
+	d.video[vs.ID] = *vs
+	return nil
+}
+
+func (s *MemoryStore) ListVideoStreams(mediaFileID int64) ([]domain.VideoStream, error) {
+	defer s.rlock()()
+	return list(s.data.video,
+		func(vs *domain.VideoStream) bool { return vs.MediaFileID == mediaFileID },
+		func(a, b *domain.VideoStream) bool { return a.StreamIndex < b.StreamIndex },
+	), nil
+}
+
+func (s *MemoryStore) DeleteVideoStreams(mediaFileID int64) error {
+	defer s.lock()()
+	maps.DeleteFunc(s.data.video, func(_ int64, vs domain.VideoStream) bool {
+		return vs.MediaFileID == mediaFileID
+	})
+	return nil
+}
+
+// ============================================================================
+// Chapters
+// ============================================================================
+
+func (s *MemoryStore) CreateChapter(ch *domain.Chapter) error {
+	defer s.lock()()
+	d := s.data
+
+	if _, ok := d.mediaFiles[ch.MediaFileID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := first(d.chapters, func(x *domain.Chapter) bool {
+		return x.MediaFileID == ch.MediaFileID && x.Index == ch.Index
+	}); ok {
+		return errUnique("chapters", "media_file_id", "chapter_index")
+	}
+
+	ch.ID = d.nextID("chapters")
+	d.chapters[ch.ID] = *ch
+	return nil
+}
+
+func (s *MemoryStore) ListChapters(mediaFileID int64) ([]domain.Chapter, error) {
+	defer s.rlock()()
+	return list(s.data.chapters,
+		func(ch *domain.Chapter) bool { return ch.MediaFileID == mediaFileID },
+		func(a, b *domain.Chapter) bool { return a.Index < b.Index },
+	), nil
+}
+
+func (s *MemoryStore) DeleteChapters(mediaFileID int64) error {
+	defer s.lock()()
+	maps.DeleteFunc(s.data.chapters, func(_ int64, ch domain.Chapter) bool {
+		return ch.MediaFileID == mediaFileID
+	})
+	return nil
+}
+
+// ============================================================================
+// Clean-up
+// ============================================================================
+
+func (d *memData) seriesLibrary(seriesID int64) int64 {
+	return d.series[seriesID].LibraryID
+}
+
+func (d *memData) seasonLibrary(seasonID int64) int64 {
+	return d.seriesLibrary(d.seasons[seasonID].SeriesID)
+}
+
+// deleteSeries, deleteSeason and deleteEpisode follow the schema's
+// ON DELETE rules: children cascade, direct media file links are nulled.
+func (d *memData) deleteSeries(id int64) {
+	delete(d.series, id)
+	for sid, se := range d.seasons {
+		if se.SeriesID == id {
+			d.deleteSeason(sid)
+		}
+	}
+}
+
+func (d *memData) deleteSeason(id int64) {
+	delete(d.seasons, id)
+	for eid, ep := range d.episodes {
+		if ep.SeasonID == id {
+			d.deleteEpisode(eid)
+		}
+	}
+}
+
+func (d *memData) deleteEpisode(id int64) {
+	delete(d.episodes, id)
+	maps.DeleteFunc(d.mediaFileEpisodes, func(_ int64, l domain.MediaFileEpisode) bool {
+		return l.EpisodeID == id
+	})
+	for mid, mf := range d.mediaFiles {
+		if mf.EpisodeID != nil && *mf.EpisodeID == id {
+			mf.EpisodeID = nil
+			d.mediaFiles[mid] = mf
+		}
+	}
+}
+
+func (s *MemoryStore) CleanupEmptySeries(libraryID int64) (int64, error) {
+	defer s.lock()()
+	d := s.data
+
+	hasSeasons := make(map[int64]bool)
+	for _, se := range d.seasons {
+		hasSeasons[se.SeriesID] = true
+	}
+
+	var n int64
+	for id, sr := range d.series {
+		if sr.LibraryID == libraryID && !hasSeasons[id] {
+			d.deleteSeries(id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *MemoryStore) CleanupEmptySeasons(libraryID int64) (int64, error) {
+	defer s.lock()()
+	d := s.data
+
+	hasEpisodes := make(map[int64]bool)
+	for _, ep := range d.episodes {
+		hasEpisodes[ep.SeasonID] = true
+	}
+
+	var n int64
+	for id, se := range d.seasons {
+		if d.seriesLibrary(se.SeriesID) == libraryID && !hasEpisodes[id] {
+			d.deleteSeason(id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// CleanupEmptyEpisodes deletes episodes without a present file linked
+// through media_file_episodes.
+func (s *MemoryStore) CleanupEmptyEpisodes(libraryID int64) (int64, error) {
+	defer s.lock()()
+	d := s.data
+
+	hasFile := make(map[int64]bool)
+	for _, l := range d.mediaFileEpisodes {
+		if mf, ok := d.mediaFiles[l.MediaFileID]; ok && !mf.IsMissing {
+			hasFile[l.EpisodeID] = true
+		}
+	}
+
+	var orphans []int64
+	for id, ep := range d.episodes {
+		if d.seasonLibrary(ep.SeasonID) == libraryID && !hasFile[id] {
+			orphans = append(orphans, id)
+		}
+	}
+	for _, id := range orphans {
+		d.deleteEpisode(id)
+	}
+	return int64(len(orphans)), nil
+}
+
+func (s *MemoryStore) CleanupMissingMediaFileLinks(libraryID int64) (int64, error) {
+	defer s.lock()()
+	return s.data.deleteMissingLinks(libraryID), nil
+}
+
+func (d *memData) deleteMissingLinks(libraryID int64) int64 {
+	var n int64
+	maps.DeleteFunc(d.mediaFileEpisodes, func(_ int64, l domain.MediaFileEpisode) bool {
+		mf := d.mediaFiles[l.MediaFileID]
+		if mf.LibraryID == libraryID && mf.IsMissing {
+			n++
+			return true
+		}
+		return false
+	})
+	return n
+}
+
+func (s *MemoryStore) UnlinkMissingMediaFiles(libraryID int64) (int64, error) {
+	defer s.lock()()
+	d := s.data
+
+	d.deleteMissingLinks(libraryID)
+
+	now := time.Now().UTC()
+	var n int64
+	for id, mf := range d.mediaFiles {
+		if mf.LibraryID != libraryID || !mf.IsMissing || (mf.EpisodeID == nil && mf.MovieID == nil) {
+			continue
+		}
+		mf.EpisodeID = nil
+		mf.MovieID = nil
+		mf.UpdatedAt = now
+		d.mediaFiles[id] = mf
+		n++
+	}
+	return n, nil
+}
+
+// ============================================================================
+// Search
+// ============================================================================
+
+// Search matches like the SQL stores' plain index, but over the live rows.
+func (s *MemoryStore) Search(query string, typ domain.SearchResultType, libraryID int64, limit int) ([]domain.SearchResult, error) {
+	defer s.rlock()()
+	d := s.data
+
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	type hit struct {
+		domain.SearchResult
+		score int
+		title string
+	}
+	var hits []hit
+
+	add := func(r domain.SearchResult, title, originalTitle, overview string) {
+		if (typ != "" && r.Type != typ) || (libraryID != 0 && r.LibraryID != libraryID) {
+			return
+		}
+		title, originalTitle, overview = foldText(title), foldText(originalTitle), foldText(overview)
+		score := 0
+		for _, t := range terms {
+			w := " " + t
+			n := 0
+			if strings.Contains(title, w) {
+				n += searchWeightTitle
+			}
+			if strings.Contains(originalTitle, w) {
+				n += searchWeightOriginal
+			}
+			if strings.Contains(overview, w) {
+				n += searchWeightOverview
+			}
+			if n == 0 {
+				return
+			}
+			score += n
+		}
+		hits = append(hits, hit{r, score, title})
+	}
+
+	for _, m := range list(d.movies, func(*domain.Movie) bool { return true }, nil) {
+		add(domain.SearchResult{
+			Type: domain.SearchResultMovie, ID: m.ID, LibraryID: m.LibraryID,
+			Title: m.Title, Year: m.Year,
+		}, m.Title, m.OriginalTitle, m.Overview)
+	}
+	for _, sr := range list(d.series, func(*domain.Series) bool { return true }, nil) {
+		add(domain.SearchResult{
+			Type: domain.SearchResultSeries, ID: sr.ID, LibraryID: sr.LibraryID,
+			Title: sr.Title, Year: sr.Year,
+		}, sr.Title, sr.OriginalTitle, sr.Overview)
+	}
+	for _, ep := range list(d.episodes, func(*domain.Episode) bool { return true }, nil) {
+		se := d.seasons[ep.SeasonID]
+		sr := d.series[se.SeriesID]
+		add(domain.SearchResult{
+			Type: domain.SearchResultEpisode, ID: ep.ID, LibraryID: sr.LibraryID,
+			Title: ep.Title, SeriesID: sr.ID, SeriesTitle: sr.Title,
+			SeasonNumber: se.Number, EpisodeNumber: ep.Number,
+		}, ep.Title, "", ep.Overview)
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].title < hits[j].title
+	})
+
+	var out []domain.SearchResult
+	for i, h := range hits {
+		if i == limit {
+			break
+		}
+		out = append(out, h.SearchResult)
+	}
+	return out, nil
+}
+
+// ============================================================================
+// Listings
+// ============================================================================
+
+// page sorts all by the comparisons of p's sort key, def when p has none,
+// with the ID breaking ties, and cuts p's window out of it.
+func page[V any](all []V, p Page, sorts map[SortKey]func(a, b *V) int, def SortKey, id func(*V) int64) ([]V, int, error) {
+	key := p.Sort
+	if key == "" {
+		key = def
+	}
+	less, ok := sorts[key]
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %q", ErrInvalidSort, p.Sort)
+	}
+
+	slices.SortStableFunc(all, func(a, b V) int {
+		c := less(&a, &b)
+		if c == 0 {
+			c = cmp.Compare(id(&a), id(&b))
+		}
+		if p.Desc {
+			c = -c
+		}
+		return c
+	})
+
+	total := len(all)
+	if p.Offset >= total {
+		return nil, total, nil
+	}
+	all = all[p.Offset:]
+	if p.Limit > 0 && p.Limit < len(all) {
+		all = all[:p.Limit]
+	}
+	return all, total, nil
+}
+
+func hasTMDB(id *string) bool {
+	return id != nil && *id != ""
+}
+
+func yearIn(f ListFilter, year int) bool {
+	return (f.YearMin == 0 || year >= f.YearMin) && (f.YearMax == 0 || year <= f.YearMax)
+}
+
+// filesMatch applies f's file filters to the files of one catalog row.
+func filesMatch(f ListFilter, files []domain.MediaFile) bool {
+	if f.fileFilter() && !slices.ContainsFunc(files, func(mf domain.MediaFile) bool {
+		return !mf.IsMissing &&
+			(f.Resolution == "" || fileResolution(&mf) == strings.ToLower(f.Resolution)) &&
+			(f.Codec == "" || fileCodec(&mf) == strings.ToLower(f.Codec))
+	}) {
+		return false
+	}
+	if f.Missing != nil {
+		missing := slices.ContainsFunc(files, func(mf domain.MediaFile) bool { return mf.IsMissing })
+		if missing != *f.Missing {
+			return false
+		}
+	}
+	return true
+}
+
+// episodeFiles follows media_file_episodes, like the SQL listings.
+func (d *memData) episodeFiles(episodeIDs ...int64) []domain.MediaFile {
+	var out []domain.MediaFile
+	for _, l := range d.mediaFileEpisodes {
+		if slices.Contains(episodeIDs, l.EpisodeID) {
+			out = append(out, d.mediaFiles[l.MediaFileID])
+		}
+	}
+	return out
+}
+
+func (s *MemoryStore) QueryMovies(f ListFilter, p Page) ([]domain.Movie, int, error) {
+	defer s.rlock()()
+	d := s.data
+
+	all := list(d.movies, func(m *domain.Movie) bool {
+		if (f.LibraryID != 0 && m.LibraryID != f.LibraryID) || !yearIn(f, m.Year) ||
+			(f.HasTMDB != nil && hasTMDB(m.TMDBID) != *f.HasTMDB) {
+			return false
+		}
+		if !f.fileFilter() && f.Missing == nil {
+			return true
+		}
+		return filesMatch(f, list(d.mediaFiles, func(mf *domain.MediaFile) bool {
+			return mf.MovieID != nil && *mf.MovieID == m.ID
+		}, nil))
+	}, nil)
+
+	return page(all, p, map[SortKey]func(a, b *domain.Movie) int{
+		SortTitle: func(a, b *domain.Movie) int {
+			return cmp.Or(cmp.Compare(a.Title, b.Title), cmp.Compare(a.Year, b.Year))
+		},
+		SortYear: func(a, b *domain.Movie) int {
+			return cmp.Or(cmp.Compare(a.Year, b.Year), cmp.Compare(a.Title, b.Title))
+		},
+		SortAdded: func(a, b *domain.Movie) int { return a.CreatedAt.Compare(b.CreatedAt) },
+		SortRuntime: func(a, b *domain.Movie) int {
+			return cmp.Or(cmp.Compare(a.RuntimeMin, b.RuntimeMin), cmp.Compare(a.Title, b.Title))
+		},
+	}, SortTitle, func(m *domain.Movie) int64 { return m.ID })
+}
+
+func (s *MemoryStore) QuerySeries(f ListFilter, p Page) ([]domain.Series, int, error) {
+	defer s.rlock()()
+	d := s.data
+
+	all := list(d.series, func(sr *domain.Series) bool {
+		if (f.LibraryID != 0 && sr.LibraryID != f.LibraryID) || !yearIn(f, sr.Year) ||
+			(f.HasTMDB != nil && hasTMDB(sr.TMDBID) != *f.HasTMDB) {
+			return false
+		}
+		if !f.fileFilter() && f.Missing == nil {
+			return true
+		}
+		var eps []int64
+		for _, ep := range d.episodes {
+			if d.seasons[ep.SeasonID].SeriesID == sr.ID {
+				eps = append(eps, ep.ID)
+			}
+		}
+		return filesMatch(f, d.episodeFiles(eps...))
+	}, nil)
+
+	return page(all, p, map[SortKey]func(a, b *domain.Series) int{
+		SortTitle: func(a, b *domain.Series) int {
+			return cmp.Or(cmp.Compare(a.Title, b.Title), cmp.Compare(a.Year, b.Year))
+		},
+		SortYear: func(a, b *domain.Series) int {
+			return cmp.Or(cmp.Compare(a.Year, b.Year), cmp.Compare(a.Title, b.Title))
+		},
+		SortAdded: func(a, b *domain.Series) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	}, SortTitle, func(sr *domain.Series) int64 { return sr.ID })
+}
+
+func (s *MemoryStore) QueryEpisodes(f ListFilter, p Page) ([]domain.Episode, int, error) {
+	defer s.rlock()()
+	d := s.data
+
+	all := list(d.episodes, func(ep *domain.Episode) bool {
+		if (f.SeasonID != 0 && ep.SeasonID != f.SeasonID) ||
+			(f.LibraryID != 0 && d.seasonLibrary(ep.SeasonID) != f.LibraryID) ||
+			(f.HasTMDB != nil && hasTMDB(ep.TMDBID) != *f.HasTMDB) {
+			return false
+		}
+		return filesMatch(f, d.episodeFiles(ep.ID))
+	}, nil)
+
+	return page(all, p, map[SortKey]func(a, b *domain.Episode) int{
+		SortNumber: func(a, b *domain.Episode) int { return cmp.Compare(a.Number, b.Number) },
+		SortTitle: func(a, b *domain.Episode) int {
+			return cmp.Or(cmp.Compare(a.Title, b.Title), cmp.Compare(a.Number, b.Number))
+		},
+		SortAdded: func(a, b *domain.Episode) int { return a.CreatedAt.Compare(b.CreatedAt) },
+		SortRuntime: func(a, b *domain.Episode) int {
+			return cmp.Or(cmp.Compare(a.RuntimeMin, b.RuntimeMin), cmp.Compare(a.Number, b.Number))
+		},
+	}, SortNumber, func(ep *domain.Episode) int64 { return ep.ID })
+}
+
+func (s *MemoryStore) QueryMediaFiles(f ListFilter, p Page) ([]domain.MediaFile, int, error) {
+	defer s.rlock()()
+
+	all := list(s.data.mediaFiles, func(mf *domain.MediaFile) bool {
+		return (f.LibraryID == 0 || mf.LibraryID == f.LibraryID) &&
+			(f.Resolution == "" || fileResolution(mf) == strings.ToLower(f.Resolution)) &&
+			(f.Codec == "" || fileCodec(mf) == strings.ToLower(f.Codec)) &&
+			(f.Missing == nil || mf.IsMissing == *f.Missing)
+	}, nil)
+
+	return page(all, p, map[SortKey]func(a, b *domain.MediaFile) int{
+		SortPath:  func(a, b *domain.MediaFile) int { return cmp.Compare(a.Path, b.Path) },
+		SortAdded: func(a, b *domain.MediaFile) int { return a.CreatedAt.Compare(b.CreatedAt) },
+		SortRuntime: func(a, b *domain.MediaFile) int {
+			return cmp.Or(cmp.Compare(a.DurationSec, b.DurationSec), cmp.Compare(a.Path, b.Path))
+		},
+	}, SortPath, func(mf *domain.MediaFile) int64 { return mf.ID })
+}
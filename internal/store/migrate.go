@@ -0,0 +1,566 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bastianvv/vio/internal/language"
+)
+
+//go:embed migrations/0001_initial.sql
+var initialSQL string
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer
+// build than this one.
+var ErrSchemaTooNew = errors.New("database schema is newer than this build")
+
+// migration is one step of the schema history. Steps run in order, each in
+// its own transaction, and are recorded in schema_migrations.
+//
+// SQLite and PostgreSQL keep separate lists (see postgresMigrations); a
+// schema change needs a step in both.
+//
+// SQLite databases created before versioning have no schema_migrations
+// table but may already have some of the later columns, so SQLite steps must
+// be idempotent: use addColumns and CREATE ... IF NOT EXISTS.
+type migration struct {
+	Version int
+	Name    string
+
+	// Destructive steps rebuild or drop tables. The DB file is backed up
+	// first and foreign keys are off while they run, as SQLite requires for
+	// table rebuilds.
+	Destructive bool
+
+	Up func(tx *sql.Tx) error
+}
+
+var migrations = []migration{
+	{Version: 1, Name: "initial", Up: func(tx *sql.Tx) error {
+		_, err := tx.Exec(initialSQL)
+		return err
+	}},
+	{Version: 2, Name: "series year and tvdb id", Up: func(tx *sql.Tx) error {
+		return addColumns(tx, "series",
+			"year INTEGER NOT NULL DEFAULT 0",
+			"tvdb_id TEXT",
+		)
+	}},
+	{Version: 3, Name: "series unique per year", Destructive: true, Up: rebuildSeriesUnique},
+	{Version: 4, Name: "episode title source", Up: func(tx *sql.Tx) error {
+		if err := addColumns(tx, "episodes", "title_source TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+		// Titles on matched episodes came from TMDB; without a source a
+		// rescan would take them for guesses and put the filename back.
+		_, err := tx.Exec(`
+            UPDATE episodes SET title_source = 'tmdb'
+            WHERE title_source = '' AND tmdb_id IS NOT NULL AND title <> ''
+        `)
+		return err
+	}},
+	{Version: 5, Name: "media file release info", Up: func(tx *sql.Tx) error {
+		return addColumns(tx, "media_files",
+			"release_resolution TEXT NOT NULL DEFAULT ''",
+			"release_source TEXT NOT NULL DEFAULT ''",
+			"release_video_codec TEXT NOT NULL DEFAULT ''",
+			"release_audio_codec TEXT NOT NULL DEFAULT ''",
+			"release_hdr TEXT NOT NULL DEFAULT ''",
+			"release_group TEXT NOT NULL DEFAULT ''",
+			"is_remux BOOLEAN NOT NULL DEFAULT FALSE",
+			"is_proper BOOLEAN NOT NULL DEFAULT FALSE",
+			"is_repack BOOLEAN NOT NULL DEFAULT FALSE",
+		)
+	}},
+	{Version: 6, Name: "video streams", Up: func(tx *sql.Tx) error {
+		if err := addColumns(tx, "media_files", "bit_rate INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+		if err := addColumns(tx, "audio_tracks",
+			"profile TEXT NOT NULL DEFAULT ''",
+			"bit_rate INTEGER NOT NULL DEFAULT 0",
+		); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+            CREATE TABLE IF NOT EXISTS video_streams (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                media_file_id INTEGER NOT NULL,
+                stream_index INTEGER NOT NULL,
+                codec TEXT,
+                profile TEXT,
+                level INTEGER,
+                width INTEGER,
+                height INTEGER,
+                bit_rate INTEGER,
+                frame_rate REAL,
+                bit_depth INTEGER,
+                pixel_format TEXT,
+                color_range TEXT,
+                color_space TEXT,
+                color_transfer TEXT,
+                color_primaries TEXT,
+                hdr_formats TEXT, -- comma separated: DV, HDR10+, HDR10, HLG
+                scan_type TEXT,
+                is_default BOOLEAN,
+                FOREIGN KEY(media_file_id) REFERENCES media_files(id) ON DELETE CASCADE,
+                UNIQUE(media_file_id, stream_index)
+            )
+        `)
+		return err
+	}},
+	{Version: 7, Name: "chapters", Up: func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+            CREATE TABLE IF NOT EXISTS chapters (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                media_file_id INTEGER NOT NULL,
+                chapter_index INTEGER NOT NULL,
+                start_ms INTEGER NOT NULL,
+                end_ms INTEGER NOT NULL,
+                title TEXT,
+                FOREIGN KEY(media_file_id) REFERENCES media_files(id) ON DELETE CASCADE,
+                UNIQUE(media_file_id, chapter_index)
+            )
+        `)
+		return err
+	}},
+	{Version: 8, Name: "subtitle sdh and bitmap flags", Up: func(tx *sql.Tx) error {
+		return addColumns(tx, "subtitle_tracks",
+			"is_sdh BOOLEAN NOT NULL DEFAULT 0",
+			"is_bitmap BOOLEAN NOT NULL DEFAULT 0",
+		)
+	}},
+	{Version: 9, Name: "subtitle offset", Up: func(tx *sql.Tx) error {
+		return addColumns(tx, "subtitle_tracks", "offset_ms INTEGER NOT NULL DEFAULT 0")
+	}},
+	{Version: 10, Name: "derived subtitle tracks", Up: func(tx *sql.Tx) error {
+		return addColumns(tx, "subtitle_tracks",
+			"derived_from_id INTEGER REFERENCES subtitle_tracks(id) ON DELETE CASCADE",
+		)
+	}},
+	{Version: 11, Name: "track titles and dispositions", Up: func(tx *sql.Tx) error {
+		if err := addColumns(tx, "subtitle_tracks",
+			"title TEXT NOT NULL DEFAULT ''",
+			"is_commentary BOOLEAN NOT NULL DEFAULT 0",
+			"is_visual_impaired BOOLEAN NOT NULL DEFAULT 0",
+			"is_dub BOOLEAN NOT NULL DEFAULT 0",
+			"is_original BOOLEAN NOT NULL DEFAULT 0",
+		); err != nil {
+			return err
+		}
+		return addColumns(tx, "audio_tracks",
+			"title TEXT NOT NULL DEFAULT ''",
+			"is_commentary BOOLEAN NOT NULL DEFAULT 0",
+			"is_hearing_impaired BOOLEAN NOT NULL DEFAULT 0",
+			"is_visual_impaired BOOLEAN NOT NULL DEFAULT 0",
+			"is_dub BOOLEAN NOT NULL DEFAULT 0",
+			"is_original BOOLEAN NOT NULL DEFAULT 0",
+		)
+	}},
+	{Version: 12, Name: "media file probe status", Up: func(tx *sql.Tx) error {
+		return addColumns(tx, "media_files",
+			"probe_status TEXT NOT NULL DEFAULT 'OK'", // 'OK' | 'ERROR'
+			"probe_error TEXT NOT NULL DEFAULT ''",
+			"probe_attempts INTEGER NOT NULL DEFAULT 0",
+			"next_probe_at DATETIME NULL",
+		)
+	}},
+	{Version: 13, Name: "search index", Up: func(tx *sql.Tx) error {
+		var fts5 bool
+		if err := tx.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts5); err != nil {
+			return err
+		}
+		return createSQLiteSearchIndex(tx, fts5)
+	}},
+	{Version: 14, Name: "library roots", Up: func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`
+            CREATE TABLE IF NOT EXISTS library_roots (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                library_id INTEGER NOT NULL,
+                path TEXT NOT NULL,
+                FOREIGN KEY(library_id) REFERENCES libraries(id) ON DELETE CASCADE,
+                UNIQUE(path)
+            )
+        `); err != nil {
+			return err
+		}
+		_, err := tx.Exec(libraryRootsBackfill)
+		return err
+	}},
+	{Version: 15, Name: "normalized track languages", Up: func(tx *sql.Tx) error {
+		return normalizeTrackLanguages(tx)
+	}},
+}
+
+// Every library so far has its path as its one root.
+const libraryRootsBackfill = `
+    INSERT INTO library_roots (library_id, path)
+    SELECT id, path FROM libraries
+    WHERE path NOT IN (SELECT path FROM library_roots)
+    ORDER BY id
+`
+
+// normalizeTrackLanguages rewrites track languages stored before the
+// scanner normalized them ("eng", "English", "pt_br") to the tags it stores
+// now. It works on the distinct values, so it is cheap on large libraries.
+func normalizeTrackLanguages(e sqlExec) error {
+	for _, table := range []string{"audio_tracks", "subtitle_tracks"} {
+		rows, err := e.Query(`SELECT DISTINCT language FROM ` + table + ` WHERE language IS NOT NULL AND language <> ''`)
+		if err != nil {
+			return err
+		}
+		var stored []string
+		for rows.Next() {
+			var l string
+			if err := rows.Scan(&l); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			stored = append(stored, l)
+		}
+		err = rows.Err()
+		_ = rows.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, l := range stored {
+			tag := language.Normalize(l)
+			if tag == l {
+				continue
+			}
+			if _, err := e.Exec(`UPDATE `+table+` SET language = ? WHERE language = ?`, tag, l); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func latestVersion(ms []migration) int {
+	return ms[len(ms)-1].Version
+}
+
+// Migrate brings the database up to date. It refuses to touch a database
+// written by a newer build.
+func (s *SQLiteStore) Migrate() error {
+	ctx := context.Background()
+
+	// One connection throughout: the foreign_keys pragma is per connection.
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	if _, err := conn.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INTEGER PRIMARY KEY,
+            name TEXT NOT NULL,
+            applied_at DATETIME NOT NULL
+        )
+    `); err != nil {
+		return err
+	}
+
+	var current int
+	if err := conn.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`,
+	).Scan(&current); err != nil {
+		return err
+	}
+
+	latest := latestVersion(migrations)
+	if current > latest {
+		return fmt.Errorf("%w: database is at version %d, this build supports up to %d",
+			ErrSchemaTooNew, current, latest)
+	}
+
+	// Databases from before versioning already hold data at version 0.
+	var tables int
+	if err := conn.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'libraries'`,
+	).Scan(&tables); err != nil {
+		return err
+	}
+	hasData := current > 0 || tables > 0
+	backedUp := false
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+
+		if m.Destructive && hasData && !backedUp {
+			if err := s.backup(ctx, conn, current); err != nil {
+				return fmt.Errorf("backup before migration %d: %w", m.Version, err)
+			}
+			backedUp = true
+		}
+
+		if err := runMigration(ctx, conn, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+
+	mode, err := sqliteSearchMode(ctx, conn)
+	if err != nil {
+		return err
+	}
+	s.search = mode
+
+	return nil
+}
+
+// createSQLiteSearchIndex creates and fills search_index, as an FTS5 table
+// when the build has FTS5 and as a plain one matched with LIKE otherwise.
+func createSQLiteSearchIndex(tx *sql.Tx, fts5 bool) error {
+	ddl := `
+        CREATE TABLE IF NOT EXISTS search_index (
+            id INTEGER PRIMARY KEY,
+            kind TEXT NOT NULL,
+            ref_id INTEGER NOT NULL,
+            library_id INTEGER NOT NULL,
+            title TEXT NOT NULL,
+            original_title TEXT NOT NULL,
+            overview TEXT NOT NULL
+        )`
+	if fts5 {
+		// Text arrives folded, so the tokenizer only has to split it.
+		ddl = `
+            CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+                title, original_title, overview,
+                kind UNINDEXED, ref_id UNINDEXED, library_id UNINDEXED,
+                tokenize = 'unicode61 remove_diacritics 2',
+                prefix = '2 3'
+            )`
+	}
+	if _, err := tx.Exec(ddl); err != nil {
+		return err
+	}
+	return rebuildSearchIndex(tx, dialectSQLite)
+}
+
+// sqliteSearchMode reports which kind of search index this build can use.
+// The database may have been indexed by a build with or without FTS5; the
+// index is only derived data, so a mismatch is fixed by rebuilding it in the
+// form this build supports.
+func sqliteSearchMode(ctx context.Context, conn *sql.Conn) (searchMode, error) {
+	var fts5 bool
+	if err := conn.QueryRowContext(ctx,
+		`SELECT sqlite_compileoption_used('ENABLE_FTS5')`,
+	).Scan(&fts5); err != nil {
+		return searchPlain, err
+	}
+	mode := searchPlain
+	if fts5 {
+		mode = searchFTS5
+	}
+
+	var ddl string
+	err := conn.QueryRowContext(ctx,
+		`SELECT sql FROM sqlite_master WHERE name = 'search_index'`,
+	).Scan(&ddl)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// Dropped by an interrupted rebuild.
+	case err != nil:
+		return mode, err
+	case strings.Contains(strings.ToLower(ddl), "using fts5") == fts5:
+		return mode, nil
+	default:
+		if err := dropSQLiteSearchIndex(ctx, conn, fts5); err != nil {
+			return mode, fmt.Errorf("drop search index: %w", err)
+		}
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return mode, err
+	}
+	if err := createSQLiteSearchIndex(tx, fts5); err != nil {
+		_ = tx.Rollback()
+		return mode, fmt.Errorf("rebuild search index: %w", err)
+	}
+	return mode, tx.Commit()
+}
+
+// dropSQLiteSearchIndex drops search_index. Without FTS5 an FTS5 table
+// can't be dropped ("no such module"), so its shadow tables are dropped and
+// its schema entry removed by hand.
+func dropSQLiteSearchIndex(ctx context.Context, conn *sql.Conn, fts5 bool) error {
+	if fts5 {
+		_, err := conn.ExecContext(ctx, `DROP TABLE search_index`)
+		return err
+	}
+
+	stmts := []string{
+		`DROP TABLE IF EXISTS search_index_data`,
+		`DROP TABLE IF EXISTS search_index_idx`,
+		`DROP TABLE IF EXISTS search_index_content`,
+		`DROP TABLE IF EXISTS search_index_docsize`,
+		`DROP TABLE IF EXISTS search_index_config`,
+		`PRAGMA writable_schema = ON`,
+		`DELETE FROM sqlite_master WHERE type = 'table' AND name = 'search_index'`,
+		`PRAGMA writable_schema = RESET`,
+	}
+	for _, q := range stmts {
+		if _, err := conn.ExecContext(ctx, q); err != nil {
+			_, _ = conn.ExecContext(ctx, `PRAGMA writable_schema = RESET`)
+			return err
+		}
+	}
+	return nil
+}
+
+func runMigration(ctx context.Context, conn *sql.Conn, m migration) (err error) {
+	if m.Destructive {
+		if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+			return err
+		}
+		defer func() {
+			if _, e := conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`); err == nil {
+				err = e
+			}
+		}()
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := m.Up(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if m.Destructive {
+		if err := checkForeignKeys(tx); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`
+        INSERT INTO schema_migrations (version, name, applied_at)
+        VALUES (?, ?, ?)
+    `, m.Version, m.Name, time.Now().UTC()); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// backup copies the database next to itself before a destructive step,
+// e.g. vio.db.v3-20260102T150405.bak. In-memory databases are skipped.
+func (s *SQLiteStore) backup(ctx context.Context, conn *sql.Conn, version int) error {
+	if s.path == "" || s.path == ":memory:" || strings.HasPrefix(s.path, "file:") {
+		return nil
+	}
+
+	dst := fmt.Sprintf("%s.v%d-%s.bak", s.path, version, time.Now().UTC().Format("20060102T150405"))
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("backup %s already exists", dst)
+	}
+
+	_, err := conn.ExecContext(ctx, `VACUUM INTO ?`, dst)
+	return err
+}
+
+// addColumns adds the columns that are not there yet. Each definition starts
+// with the column name.
+func addColumns(tx *sql.Tx, table string, defs ...string) error {
+	for _, def := range defs {
+		name := strings.Fields(def)[0]
+
+		ok, err := hasColumn(tx, table, name)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+
+		if _, err := tx.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + def); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasColumn(tx *sql.Tx, table, column string) (bool, error) {
+	var n int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n)
+	return n > 0, err
+}
+
+func checkForeignKeys(tx *sql.Tx) error {
+	rows, err := tx.Query(`PRAGMA foreign_key_check`)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	if rows.Next() {
+		return errors.New("foreign key check failed")
+	}
+	return rows.Err()
+}
+
+// rebuildSeriesUnique widens the series unique key from (library, title) to
+// (library, title, year), so remakes sharing a title can coexist. SQLite
+// cannot alter constraints, so the table is rebuilt.
+func rebuildSeriesUnique(tx *sql.Tx) error {
+	var ddl string
+	if err := tx.QueryRow(
+		`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'series'`,
+	).Scan(&ddl); err != nil {
+		return err
+	}
+	if strings.Contains(strings.ReplaceAll(ddl, " ", ""), "UNIQUE(library_id,title,year)") {
+		return nil
+	}
+
+	stmts := []string{
+		`CREATE TABLE series_new (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            library_id INTEGER NOT NULL,
+            title TEXT NOT NULL,
+            original_title TEXT,
+            year INTEGER NOT NULL DEFAULT 0, -- first-air year, 0 when unknown
+            tmdb_id TEXT,
+            tvdb_id TEXT,
+            overview TEXT,
+            status TEXT,
+            poster_path TEXT,
+            backdrop_path TEXT,
+            created_at DATETIME NOT NULL,
+            updated_at DATETIME NOT NULL,
+            FOREIGN KEY(library_id) REFERENCES libraries(id) ON DELETE CASCADE,
+            UNIQUE(library_id, title, year)
+        )`,
+		`INSERT INTO series_new (
+            id, library_id, title, original_title, year, tmdb_id, tvdb_id,
+            overview, status, poster_path, backdrop_path, created_at, updated_at
+        )
+        SELECT id, library_id, title, original_title, year, tmdb_id, tvdb_id,
+               overview, status, poster_path, backdrop_path, created_at, updated_at
+        FROM series`,
+		`DROP TABLE series`,
+		`ALTER TABLE series_new RENAME TO series`,
+	}
+	for _, q := range stmts {
+		if _, err := tx.Exec(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,5 +1,3 @@
-PRAGMA foreign_keys = ON;
-
 -- Libraries
 CREATE TABLE IF NOT EXISTS libraries (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -0,0 +1,233 @@
+-- PostgreSQL schema, equivalent to the SQLite one after its migration 12.
+
+-- Libraries
+CREATE TABLE IF NOT EXISTS libraries (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL,
+    path TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    UNIQUE(path)
+);
+
+-- Movies
+CREATE TABLE IF NOT EXISTS movies (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    library_id BIGINT NOT NULL,
+    title TEXT NOT NULL,
+    original_title TEXT,
+    year INTEGER,
+    tmdb_id TEXT,
+    overview TEXT,
+    runtime_min INTEGER,
+    poster_path TEXT,
+    backdrop_path TEXT,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY(library_id) REFERENCES libraries(id) ON DELETE CASCADE,
+    UNIQUE(library_id, title, year)
+);
+
+-- Series
+CREATE TABLE IF NOT EXISTS series (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    library_id BIGINT NOT NULL,
+    title TEXT NOT NULL,
+    original_title TEXT,
+    year INTEGER NOT NULL DEFAULT 0, -- first-air year, 0 when unknown
+    tmdb_id TEXT,
+    tvdb_id TEXT,
+    overview TEXT,
+    status TEXT,
+    poster_path TEXT,
+    backdrop_path TEXT,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY(library_id) REFERENCES libraries(id) ON DELETE CASCADE,
+    UNIQUE(library_id, title, year)
+);
+
+-- Seasons
+CREATE TABLE IF NOT EXISTS seasons (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    series_id BIGINT NOT NULL,
+    season_number INTEGER NOT NULL,
+    title TEXT,
+    overview TEXT,
+    poster_path TEXT,
+    air_date TIMESTAMPTZ,
+    tmdb_id TEXT,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY(series_id) REFERENCES series(id) ON DELETE CASCADE,
+    UNIQUE(series_id, season_number)
+);
+
+-- Episodes
+CREATE TABLE IF NOT EXISTS episodes (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    season_id BIGINT NOT NULL,
+    episode_number INTEGER NOT NULL,
+    title TEXT,
+    overview TEXT,
+    air_date TIMESTAMPTZ,
+    runtime_min INTEGER,
+    still_path TEXT,
+    tmdb_id TEXT,
+    title_source TEXT NOT NULL DEFAULT '', -- '', filename, tmdb, manual
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY(season_id) REFERENCES seasons(id) ON DELETE CASCADE,
+    UNIQUE(season_id, episode_number)
+);
+
+-- Media Files
+CREATE TABLE IF NOT EXISTS media_files (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    library_id BIGINT NOT NULL,
+
+    is_missing BOOLEAN NOT NULL DEFAULT FALSE,
+    missing_since TIMESTAMPTZ NULL,
+    last_seen_at TIMESTAMPTZ NULL,
+
+    movie_id BIGINT NULL,
+    episode_id BIGINT NULL,
+
+    path TEXT NOT NULL,
+    size_bytes BIGINT,
+    hash TEXT, -- NOT UNIQUE (same content across different paths is valid)
+
+    container TEXT,
+    video_codec TEXT,
+    audio_codec TEXT,
+    video_width INTEGER,
+    video_height INTEGER,
+    audio_channels INTEGER,
+    duration_sec INTEGER,
+    bit_rate BIGINT NOT NULL DEFAULT 0,
+
+    -- Release-name hints, available even without ffprobe
+    release_resolution TEXT NOT NULL DEFAULT '',
+    release_source TEXT NOT NULL DEFAULT '',
+    release_video_codec TEXT NOT NULL DEFAULT '',
+    release_audio_codec TEXT NOT NULL DEFAULT '',
+    release_hdr TEXT NOT NULL DEFAULT '', -- comma separated: HDR10, HDR10+, DV, HLG
+    release_group TEXT NOT NULL DEFAULT '',
+    is_remux BOOLEAN NOT NULL DEFAULT FALSE,
+    is_proper BOOLEAN NOT NULL DEFAULT FALSE,
+    is_repack BOOLEAN NOT NULL DEFAULT FALSE,
+
+    -- Files ffprobe cannot read are kept with the error and retried later
+    probe_status TEXT NOT NULL DEFAULT 'OK', -- 'OK' | 'ERROR'
+    probe_error TEXT NOT NULL DEFAULT '',
+    probe_attempts INTEGER NOT NULL DEFAULT 0,
+    next_probe_at TIMESTAMPTZ NULL,
+
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+
+    FOREIGN KEY(library_id) REFERENCES libraries(id) ON DELETE CASCADE,
+    FOREIGN KEY(movie_id) REFERENCES movies(id) ON DELETE SET NULL,
+    FOREIGN KEY(episode_id) REFERENCES episodes(id) ON DELETE SET NULL,
+
+    UNIQUE(path),
+
+    CHECK (
+      (is_missing = FALSE AND missing_since IS NULL)
+      OR
+      (is_missing = TRUE AND missing_since IS NOT NULL)
+    )
+);
+
+-- Multi-episode links (range files)
+CREATE TABLE IF NOT EXISTS media_file_episodes (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    media_file_id BIGINT NOT NULL,
+    episode_id BIGINT NOT NULL,
+    FOREIGN KEY(media_file_id) REFERENCES media_files(id) ON DELETE CASCADE,
+    FOREIGN KEY(episode_id) REFERENCES episodes(id) ON DELETE CASCADE,
+    UNIQUE(media_file_id, episode_id)
+);
+
+-- Subtitles
+CREATE TABLE IF NOT EXISTS subtitle_tracks (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    media_file_id BIGINT NOT NULL,
+    source TEXT NOT NULL,
+    external_path TEXT,
+    stream_index INTEGER,
+    language TEXT,
+    title TEXT NOT NULL DEFAULT '',
+    is_forced BOOLEAN,
+    is_sdh BOOLEAN NOT NULL DEFAULT FALSE,
+    is_default BOOLEAN,
+    is_commentary BOOLEAN NOT NULL DEFAULT FALSE,
+    is_visual_impaired BOOLEAN NOT NULL DEFAULT FALSE,
+    is_dub BOOLEAN NOT NULL DEFAULT FALSE,
+    is_original BOOLEAN NOT NULL DEFAULT FALSE,
+    format TEXT,
+    is_bitmap BOOLEAN NOT NULL DEFAULT FALSE,
+    offset_ms BIGINT NOT NULL DEFAULT 0,
+    derived_from_id BIGINT,
+    FOREIGN KEY(media_file_id) REFERENCES media_files(id) ON DELETE CASCADE,
+    FOREIGN KEY(derived_from_id) REFERENCES subtitle_tracks(id) ON DELETE CASCADE
+);
+
+-- Audio tracks
+CREATE TABLE IF NOT EXISTS audio_tracks (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    media_file_id BIGINT NOT NULL,
+    stream_index INTEGER NOT NULL,
+    language TEXT,
+    title TEXT NOT NULL DEFAULT '',
+    codec TEXT,
+    profile TEXT NOT NULL DEFAULT '',
+    channels INTEGER,
+    bit_rate BIGINT NOT NULL DEFAULT 0,
+    is_default BOOLEAN,
+    is_commentary BOOLEAN NOT NULL DEFAULT FALSE,
+    is_hearing_impaired BOOLEAN NOT NULL DEFAULT FALSE,
+    is_visual_impaired BOOLEAN NOT NULL DEFAULT FALSE,
+    is_dub BOOLEAN NOT NULL DEFAULT FALSE,
+    is_original BOOLEAN NOT NULL DEFAULT FALSE,
+    FOREIGN KEY(media_file_id) REFERENCES media_files(id) ON DELETE CASCADE,
+    UNIQUE(media_file_id, stream_index)
+);
+
+-- Video streams
+CREATE TABLE IF NOT EXISTS video_streams (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    media_file_id BIGINT NOT NULL,
+    stream_index INTEGER NOT NULL,
+    codec TEXT,
+    profile TEXT,
+    level INTEGER,
+    width INTEGER,
+    height INTEGER,
+    bit_rate BIGINT,
+    frame_rate DOUBLE PRECISION,
+    bit_depth INTEGER,
+    pixel_format TEXT,
+    color_range TEXT,
+    color_space TEXT,
+    color_transfer TEXT,
+    color_primaries TEXT,
+    hdr_formats TEXT, -- comma separated: DV, HDR10+, HDR10, HLG
+    scan_type TEXT,
+    is_default BOOLEAN,
+    FOREIGN KEY(media_file_id) REFERENCES media_files(id) ON DELETE CASCADE,
+    UNIQUE(media_file_id, stream_index)
+);
+
+-- Chapters
+CREATE TABLE IF NOT EXISTS chapters (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    media_file_id BIGINT NOT NULL,
+    chapter_index INTEGER NOT NULL,
+    start_ms BIGINT NOT NULL,
+    end_ms BIGINT NOT NULL,
+    title TEXT,
+    FOREIGN KEY(media_file_id) REFERENCES media_files(id) ON DELETE CASCADE,
+    UNIQUE(media_file_id, chapter_index)
+);
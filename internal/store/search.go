@@ -0,0 +1,339 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/bastianvv/vio/internal/domain"
+)
+
+// The search index holds one document per movie, series and episode, with
+// its text folded by foldText. Its key packs the row ID and type together:
+// id*4 + searchKind.
+//
+// SQLite builds that include FTS5 (-tags sqlite_fts5) get an FTS5 table
+// ranked by bm25; PostgreSQL and other SQLite builds get a plain table
+// matched with LIKE. Documents are written the same way for both.
+
+type searchMode int
+
+const (
+	searchPlain searchMode = iota
+	searchFTS5
+)
+
+var searchKinds = map[domain.SearchResultType]int64{
+	domain.SearchResultMovie:   1,
+	domain.SearchResultSeries:  2,
+	domain.SearchResultEpisode: 3,
+}
+
+// Column weights: a title hit outranks an original title hit, which
+// outranks an overview hit.
+const (
+	searchWeightTitle    = 10
+	searchWeightOriginal = 5
+	searchWeightOverview = 1
+)
+
+func searchKey(typ domain.SearchResultType, id int64) int64 {
+	return id*4 + searchKinds[typ]
+}
+
+// foldText lowercases s, strips diacritics and turns everything but letters
+// and digits into single spaces. The result is padded with spaces so a
+// prefix match on a word is LIKE '% word%'.
+func foldText(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte(' ')
+
+	space := true
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r == '\'' || r == '’' || unicode.Is(unicode.Mn, r):
+			// "Schindler's" is searched as "schindlers"; combining
+			// marks are diacritics.
+		case foldRunes[r] != "":
+			b.WriteString(foldRunes[r])
+			space = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+			space = false
+		case !space:
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+	if !space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+func searchTerms(query string) []string {
+	return strings.Fields(foldText(query))
+}
+
+var foldRunes = func() map[rune]string {
+	m := make(map[rune]string)
+	for to, from := range map[string]string{
+		"a": "àáâãäåāăą", "c": "çćĉċč", "d": "ďđð", "e": "èéêëēĕėęě",
+		"g": "ĝğġģ", "h": "ĥħ", "i": "ìíîïĩīĭįı", "j": "ĵ", "k": "ķ",
+		"l": "ĺļľŀł", "n": "ñńņňŉ", "o": "òóôõöøōŏő", "r": "ŕŗř",
+		"s": "śŝşšș", "t": "ţťŧț", "u": "ùúûüũūŭůűų", "w": "ŵ",
+		"y": "ýÿŷ", "z": "źżž", "ae": "æ", "oe": "œ", "ss": "ß",
+		"th": "þ", "ij": "ĳ",
+	} {
+		for _, r := range from {
+			m[r] = to
+		}
+	}
+	return m
+}()
+
+type searchDoc struct {
+	typ           domain.SearchResultType
+	id            int64
+	libraryID     int64
+	title         string
+	originalTitle string
+	overview      string
+}
+
+// searchKeyColumn is the index's key column: SQLite's rowid, which both the
+// FTS5 and the plain table have, or id on PostgreSQL.
+func searchKeyColumn(d dialect) string {
+	if d == dialectPostgres {
+		return "id"
+	}
+	return "rowid"
+}
+
+func putSearchDoc(e sqlExec, d dialect, doc searchDoc) error {
+	key := searchKey(doc.typ, doc.id)
+	col := searchKeyColumn(d)
+
+	if _, err := e.Exec(`DELETE FROM search_index WHERE `+col+` = ?`, key); err != nil {
+		return err
+	}
+	_, err := e.Exec(`
+        INSERT INTO search_index (`+col+`, kind, ref_id, library_id, title, original_title, overview)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+    `, key, doc.typ, doc.id, doc.libraryID,
+		foldText(doc.title), foldText(doc.originalTitle), foldText(doc.overview))
+	return err
+}
+
+// Documents are read back from their tables, since the Update methods only
+// write some columns.
+
+func (s *sqlStore) indexMovie(id int64) error {
+	doc := searchDoc{typ: domain.SearchResultMovie, id: id}
+	err := s.exec.QueryRow(`
+        SELECT library_id, title, COALESCE(original_title, ''), COALESCE(overview, '')
+        FROM movies WHERE id = ?
+    `, id).Scan(&doc.libraryID, &doc.title, &doc.originalTitle, &doc.overview)
+	if err == sql.ErrNoRows {
+		return nil // updating a missing row is a no-op
+	}
+	if err != nil {
+		return err
+	}
+	return putSearchDoc(s.exec, s.dialect, doc)
+}
+
+func (s *sqlStore) indexSeries(id int64) error {
+	doc := searchDoc{typ: domain.SearchResultSeries, id: id}
+	err := s.exec.QueryRow(`
+        SELECT library_id, title, COALESCE(original_title, ''), COALESCE(overview, '')
+        FROM series WHERE id = ?
+    `, id).Scan(&doc.libraryID, &doc.title, &doc.originalTitle, &doc.overview)
+	if err == sql.ErrNoRows {
+		return nil // updating a missing row is a no-op
+	}
+	if err != nil {
+		return err
+	}
+	return putSearchDoc(s.exec, s.dialect, doc)
+}
+
+func (s *sqlStore) indexEpisode(id int64) error {
+	doc := searchDoc{typ: domain.SearchResultEpisode, id: id}
+	err := s.exec.QueryRow(`
+        SELECT sr.library_id, COALESCE(e.title, ''), COALESCE(e.overview, '')
+        FROM episodes e
+        JOIN seasons se ON se.id = e.season_id
+        JOIN series sr ON sr.id = se.series_id
+        WHERE e.id = ?
+    `, id).Scan(&doc.libraryID, &doc.title, &doc.overview)
+	if err == sql.ErrNoRows {
+		return nil // updating a missing row is a no-op
+	}
+	if err != nil {
+		return err
+	}
+	return putSearchDoc(s.exec, s.dialect, doc)
+}
+
+// pruneSearchIndex drops the documents of deleted rows.
+func (s *sqlStore) pruneSearchIndex() error {
+	for _, q := range []string{
+		`DELETE FROM search_index WHERE kind = 'movie' AND ref_id NOT IN (SELECT id FROM movies)`,
+		`DELETE FROM search_index WHERE kind = 'series' AND ref_id NOT IN (SELECT id FROM series)`,
+		`DELETE FROM search_index WHERE kind = 'episode' AND ref_id NOT IN (SELECT id FROM episodes)`,
+	} {
+		if _, err := s.exec.Exec(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildSearchIndex indexes every existing row, for the migration that
+// adds the index.
+func rebuildSearchIndex(e sqlExec, d dialect) error {
+	var docs []searchDoc
+
+	for _, q := range []struct {
+		typ   domain.SearchResultType
+		query string
+	}{
+		{domain.SearchResultMovie, `
+            SELECT id, library_id, title, COALESCE(original_title, ''), COALESCE(overview, '')
+            FROM movies`},
+		{domain.SearchResultSeries, `
+            SELECT id, library_id, title, COALESCE(original_title, ''), COALESCE(overview, '')
+            FROM series`},
+		{domain.SearchResultEpisode, `
+            SELECT e.id, sr.library_id, COALESCE(e.title, ''), '', COALESCE(e.overview, '')
+            FROM episodes e
+            JOIN seasons se ON se.id = e.season_id
+            JOIN series sr ON sr.id = se.series_id`},
+	} {
+		rows, err := e.Query(q.query)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			doc := searchDoc{typ: q.typ}
+			if err := rows.Scan(&doc.id, &doc.libraryID, &doc.title, &doc.originalTitle, &doc.overview); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			docs = append(docs, doc)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+	}
+
+	for _, doc := range docs {
+		if err := putSearchDoc(e, d, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlStore) Search(query string, typ domain.SearchResultType, libraryID int64, limit int) ([]domain.SearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	var (
+		hits  string
+		args  []any
+		where []string
+	)
+
+	if s.search == searchFTS5 {
+		// Every term, as a quoted prefix: "doc"* "who"*
+		match := make([]string, len(terms))
+		for i, t := range terms {
+			match[i] = `"` + t + `"*`
+		}
+		hits = fmt.Sprintf(`
+            SELECT kind, ref_id, library_id, title,
+                   -bm25(search_index, %d.0, %d.0, %d.0) AS score
+            FROM search_index
+            WHERE search_index MATCH ?`,
+			searchWeightTitle, searchWeightOriginal, searchWeightOverview)
+		args = append(args, strings.Join(match, " "))
+	} else {
+		var score []string
+		for _, t := range terms {
+			p := "% " + t + "%"
+			where = append(where, `(title LIKE ? OR original_title LIKE ? OR overview LIKE ?)`)
+			args = append(args, p, p, p)
+			score = append(score, fmt.Sprintf(`CASE WHEN title LIKE ? THEN %d ELSE 0 END
+                + CASE WHEN original_title LIKE ? THEN %d ELSE 0 END
+                + CASE WHEN overview LIKE ? THEN %d ELSE 0 END`,
+				searchWeightTitle, searchWeightOriginal, searchWeightOverview))
+		}
+		// Score arguments come first in the SELECT.
+		scoreArgs := make([]any, 0, len(args))
+		for _, t := range terms {
+			p := "% " + t + "%"
+			scoreArgs = append(scoreArgs, p, p, p)
+		}
+		args = append(scoreArgs, args...)
+
+		hits = `
+            SELECT kind, ref_id, library_id, title,
+                   ` + strings.Join(score, " + ") + ` AS score
+            FROM search_index
+            WHERE ` + strings.Join(where, " AND ")
+		where = nil
+	}
+
+	if typ != "" {
+		where = append(where, `kind = ?`)
+		args = append(args, typ)
+	}
+	if libraryID != 0 {
+		where = append(where, `library_id = ?`)
+		args = append(args, libraryID)
+	}
+	for _, w := range where {
+		hits += " AND " + w
+	}
+	hits += ` ORDER BY score DESC, title LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.read.Query(`
+        SELECT h.kind, h.ref_id, h.library_id,
+               COALESCE(m.title, sr.title, e.title, ''),
+               COALESCE(m.year, sr.year, 0),
+               COALESCE(esr.id, 0), COALESCE(esr.title, ''),
+               COALESCE(se.season_number, 0), COALESCE(e.episode_number, 0)
+        FROM (`+hits+`) h
+        LEFT JOIN movies m ON h.kind = 'movie' AND m.id = h.ref_id
+        LEFT JOIN series sr ON h.kind = 'series' AND sr.id = h.ref_id
+        LEFT JOIN episodes e ON h.kind = 'episode' AND e.id = h.ref_id
+        LEFT JOIN seasons se ON se.id = e.season_id
+        LEFT JOIN series esr ON esr.id = se.series_id
+        WHERE m.id IS NOT NULL OR sr.id IS NOT NULL OR e.id IS NOT NULL
+        ORDER BY h.score DESC, h.title
+    `, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []domain.SearchResult
+	for rows.Next() {
+		var r domain.SearchResult
+		if err := rows.Scan(
+			&r.Type, &r.ID, &r.LibraryID, &r.Title, &r.Year,
+			&r.SeriesID, &r.SeriesTitle, &r.SeasonNumber, &r.EpisodeNumber,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
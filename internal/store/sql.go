@@ -0,0 +1,2136 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bastianvv/vio/internal/domain"
+)
+
+// sqlExec is what queries run against: a *sql.DB, or the *sql.Tx of WithTx.
+type sqlExec interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+type dialect int
+
+const (
+	dialectSQLite dialect = iota
+	dialectPostgres
+)
+
+// sqlStore implements Store on database/sql for every supported database.
+// Queries are written once, with ? placeholders and portable SQL; the
+// dialect covers placeholders, insert IDs and per-transaction setup.
+//
+// Writes go through exec and plain reads through read. SQLite gives read its
+// own pool of connections so API lookups do not queue behind a scan's
+// transaction; inside WithTx both are the transaction.
+type sqlStore struct {
+	db      *sql.DB // writer
+	readDB  *sql.DB // may be db
+	tx      *sql.Tx // set on the store WithTx hands out
+	exec    sqlExec
+	read    sqlExec
+	dialect dialect
+	search  searchMode // set by Migrate
+
+	// Prepared statements for the queries a scan runs per file. A
+	// transaction prepares its own, since the writer's only connection is
+	// busy with it, and keeps them until it ends.
+	writeStmts *stmtCache
+	readStmts  *stmtCache
+	txStmts    map[string]*sql.Stmt
+}
+
+func newSQLStore(db, readDB *sql.DB, d dialect) *sqlStore {
+	s := &sqlStore{db: db, readDB: readDB, dialect: d}
+	s.exec = s.wrap(db)
+	s.read = s.wrap(readDB)
+	s.writeStmts = newStmtCache(db)
+	s.readStmts = s.writeStmts
+	if readDB != db {
+		s.readStmts = newStmtCache(readDB)
+	}
+	return s
+}
+
+func (s *sqlStore) wrap(e sqlExec) sqlExec {
+	if s.dialect == dialectPostgres {
+		return rebinder{e}
+	}
+	return e
+}
+
+func (s *sqlStore) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	s.writeStmts.close()
+	if s.readDB != s.db {
+		s.readStmts.close()
+		_ = s.readDB.Close()
+	}
+	return s.db.Close()
+}
+
+// WithTx runs fn in a transaction. Called on the store of an open
+// transaction it runs fn in a savepoint instead, so one failing step can be
+// undone without losing the rest of the transaction.
+func (s *sqlStore) WithTx(fn func(tx Store) error) error {
+	if s.tx != nil {
+		return s.withSavepoint(fn)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if s.dialect == dialectSQLite {
+		_, _ = tx.Exec(`PRAGMA foreign_keys = ON;`)
+	}
+
+	exec := s.wrap(tx)
+	txStore := &sqlStore{
+		db:      nil,
+		tx:      tx,
+		exec:    exec,
+		read:    exec,
+		dialect: s.dialect,
+		search:  s.search,
+		txStmts: make(map[string]*sql.Stmt),
+	}
+
+	if err := fn(txStore); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Nested savepoints may share a name; ROLLBACK TO and RELEASE pick the
+// innermost one.
+func (s *sqlStore) withSavepoint(fn func(tx Store) error) error {
+	if _, err := s.exec.Exec(`SAVEPOINT vio_step`); err != nil {
+		return err
+	}
+
+	if err := fn(s); err != nil {
+		if _, rbErr := s.exec.Exec(`ROLLBACK TO SAVEPOINT vio_step`); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		_, _ = s.exec.Exec(`RELEASE SAVEPOINT vio_step`)
+		return err
+	}
+
+	_, err := s.exec.Exec(`RELEASE SAVEPOINT vio_step`)
+	return err
+}
+
+// stmtCache keeps prepared statements for one *sql.DB, keyed by query.
+type stmtCache struct {
+	db    *sql.DB
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+func newStmtCache(db *sql.DB) *stmtCache {
+	return &stmtCache{db: db, stmts: make(map[string]*sql.Stmt)}
+}
+
+func (c *stmtCache) get(query string) (*sql.Stmt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if st, ok := c.stmts[query]; ok {
+		return st, nil
+	}
+	st, err := c.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	c.stmts[query] = st
+	return st, nil
+}
+
+func (c *stmtCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, st := range c.stmts {
+		_ = st.Close()
+	}
+	clear(c.stmts)
+}
+
+// prepared returns a prepared statement for query: from c, or from the
+// transaction's own set inside WithTx. The latter close with the
+// transaction.
+func (s *sqlStore) prepared(c *stmtCache, query string) (*sql.Stmt, error) {
+	if s.dialect == dialectPostgres {
+		query = rebind(query)
+	}
+
+	if s.tx == nil {
+		return c.get(query)
+	}
+
+	if st, ok := s.txStmts[query]; ok {
+		return st, nil
+	}
+	st, err := s.tx.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	s.txStmts[query] = st
+	return st, nil
+}
+
+// insert runs an INSERT and returns the ID of the new row. An insert skipped
+// by ON CONFLICT DO NOTHING returns 0 on PostgreSQL.
+func (s *sqlStore) insert(query string, args ...any) (int64, error) {
+	if s.dialect == dialectPostgres {
+		var id int64
+		err := s.exec.QueryRow(query+" RETURNING id", args...).Scan(&id)
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return id, err
+	}
+
+	res, err := s.exec.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// insertPrepared is insert through a cached prepared statement, for the
+// inserts a scan repeats per file.
+func (s *sqlStore) insertPrepared(query string, args ...any) (int64, error) {
+	if s.dialect == dialectPostgres {
+		st, err := s.prepared(s.writeStmts, query+" RETURNING id")
+		if err != nil {
+			return 0, err
+		}
+		var id int64
+		err = st.QueryRow(args...).Scan(&id)
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return id, err
+	}
+
+	st, err := s.prepared(s.writeStmts, query)
+	if err != nil {
+		return 0, err
+	}
+	res, err := st.Exec(args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// ============================================================================
+// Libraries
+// ============================================================================
+
+func (s *sqlStore) CreateLibrary(lib *domain.Library) error {
+	if err := setRoots(lib); err != nil {
+		return err
+	}
+	now := time.Now()
+
+	return s.WithTx(func(tx Store) error {
+		t := tx.(*sqlStore)
+		id, err := t.insert(`
+            INSERT INTO libraries (name, type, path, created_at, updated_at)
+            VALUES (?, ?, ?, ?, ?)
+        `, lib.Name, lib.Type, lib.Path, now, now)
+		if err != nil {
+			return err
+		}
+		if err := t.insertRoots(id, lib.Roots); err != nil {
+			return err
+		}
+		lib.ID = id
+		lib.CreatedAt = now
+		lib.UpdatedAt = now
+		return nil
+	})
+}
+
+func (s *sqlStore) insertRoots(libraryID int64, roots []string) error {
+	for _, r := range roots {
+		if _, err := s.exec.Exec(`
+            INSERT INTO library_roots (library_id, path) VALUES (?, ?)
+        `, libraryID, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadRoots fills in the roots of libs, in the order they were given.
+func (s *sqlStore) loadRoots(libs []domain.Library) error {
+	rows, err := s.read.Query(`SELECT library_id, path FROM library_roots ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	roots := make(map[int64][]string)
+	for rows.Next() {
+		var (
+			id   int64
+			path string
+		)
+		if err := rows.Scan(&id, &path); err != nil {
+			return err
+		}
+		roots[id] = append(roots[id], path)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i := range libs {
+		libs[i].Roots = roots[libs[i].ID]
+	}
+	return nil
+}
+
+func (s *sqlStore) ListLibraries() ([]domain.Library, error) {
+	rows, err := s.read.Query(`
+        SELECT id, name, type, path, created_at, updated_at
+        FROM libraries
+        ORDER BY id
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var libs []domain.Library
+	for rows.Next() {
+		var l domain.Library
+		if err := rows.Scan(
+			&l.ID, &l.Name, &l.Type, &l.Path,
+			&l.CreatedAt, &l.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		libs = append(libs, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	_ = rows.Close() // inside WithTx, rows must close before the next query
+
+	if err := s.loadRoots(libs); err != nil {
+		return nil, err
+	}
+	return libs, nil
+}
+
+func (s *sqlStore) GetLibrary(id int64) (*domain.Library, error) {
+	var l domain.Library
+	err := s.read.QueryRow(`
+        SELECT id, name, type, path, created_at, updated_at
+        FROM libraries
+        WHERE id = ?
+    `, id).Scan(
+		&l.ID, &l.Name, &l.Type, &l.Path,
+		&l.CreatedAt, &l.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.read.Query(`
+        SELECT path FROM library_roots WHERE library_id = ? ORDER BY id
+    `, id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		l.Roots = append(l.Roots, path)
+	}
+	return &l, rows.Err()
+}
+
+// UpdateLibrary writes the name, type and roots.
+func (s *sqlStore) UpdateLibrary(lib *domain.Library) error {
+	if lib.ID == 0 {
+		return errors.New("cannot update library without ID")
+	}
+	if err := setRoots(lib); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	err := s.WithTx(func(tx Store) error {
+		t := tx.(*sqlStore)
+		res, err := t.exec.Exec(`
+            UPDATE libraries
+            SET name = ?, type = ?, path = ?, updated_at = ?
+            WHERE id = ?
+        `,
+			lib.Name,
+			string(lib.Type),
+			lib.Path,
+			now,
+			lib.ID,
+		)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return sql.ErrNoRows
+		}
+
+		// Rewritten whole, so the roots keep the order given.
+		if _, err := t.exec.Exec(`DELETE FROM library_roots WHERE library_id = ?`, lib.ID); err != nil {
+			return err
+		}
+		return t.insertRoots(lib.ID, lib.Roots)
+	})
+	if err != nil {
+		return err
+	}
+
+	lib.UpdatedAt = now
+	return nil
+}
+
+// rootCond matches col against root and everything below it. substr counts
+// characters on both dialects, so the prefix is compared by rune count.
+func rootCond(col, root string) (string, []any) {
+	root = strings.TrimSuffix(root, string(filepath.Separator))
+	dir := root + string(filepath.Separator)
+	return `(` + col + ` = ? OR substr(` + col + `, 1, ?) = ?)`,
+		[]any{root, utf8.RuneCountInString(dir), dir}
+}
+
+// RelocateLibrary moves the library's root from to a new place, to: the
+// root, and every media file and sidecar subtitle path under it, are
+// rewritten to sit under to instead. Paths outside from are left as they
+// are.
+func (s *sqlStore) RelocateLibrary(id int64, from, to string) (files, sidecars int, err error) {
+	now := time.Now().UTC()
+	err = s.WithTx(func(tx Store) error {
+		t := tx.(*sqlStore)
+
+		lib, err := t.GetLibrary(id)
+		if err != nil {
+			return err
+		}
+		i := slices.Index(lib.Roots, from)
+		if i < 0 {
+			return sql.ErrNoRows
+		}
+
+		if _, err := t.exec.Exec(`
+            UPDATE library_roots SET path = ? WHERE library_id = ? AND path = ?
+        `, to, id, from); err != nil {
+			return err
+		}
+		if i == 0 {
+			if _, err := t.exec.Exec(`
+                UPDATE libraries SET path = ?, updated_at = ? WHERE id = ?
+            `, to, now, id); err != nil {
+				return err
+			}
+		}
+
+		// Everything after the old root is kept.
+		rest := utf8.RuneCountInString(strings.TrimSuffix(from, string(filepath.Separator))) + 1
+
+		cond, args := rootCond("path", from)
+		res, err := t.exec.Exec(`
+            UPDATE media_files
+            SET path = CAST(? AS TEXT) || substr(path, ?), updated_at = ?
+            WHERE library_id = ? AND `+cond,
+			append([]any{to, rest, now, id}, args...)...)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		files = int(n)
+
+		cond, args = rootCond("external_path", from)
+		res, err = t.exec.Exec(`
+            UPDATE subtitle_tracks
+            SET external_path = CAST(? AS TEXT) || substr(external_path, ?)
+            WHERE media_file_id IN (SELECT id FROM media_files WHERE library_id = ?)
+              AND `+cond,
+			append([]any{to, rest, id}, args...)...)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		sidecars = int(n)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return files, sidecars, nil
+}
+
+// DeleteLibrary removes the library's rows; the schema cascades the delete
+// to its movies, series and media files and everything below them.
+func (s *sqlStore) DeleteLibrary(id int64) error {
+	return s.WithTx(func(tx Store) error {
+		t := tx.(*sqlStore)
+		res, err := t.exec.Exec(`DELETE FROM libraries WHERE id = ?`, id)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			return sql.ErrNoRows
+		}
+
+		return t.pruneSearchIndex()
+	})
+}
+
+// ============================================================================
+// Movies
+// ============================================================================
+
+func (s *sqlStore) CreateMovie(m *domain.Movie) error {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+
+	id, err := s.insert(`
+        INSERT INTO movies (library_id, title, original_title, year, tmdb_id,
+                            overview, runtime_min, poster_path, backdrop_path,
+                            created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, m.LibraryID, m.Title, m.OriginalTitle, m.Year, m.TMDBID,
+		m.Overview, m.RuntimeMin, m.PosterPath, m.BackdropPath,
+		m.CreatedAt, m.UpdatedAt)
+
+	if err != nil {
+		return err
+	}
+	m.ID = id
+	return s.indexMovie(id)
+}
+
+func (s *sqlStore) GetMovie(id int64) (*domain.Movie, error) {
+	var m domain.Movie
+	err := s.read.QueryRow(`
+        SELECT id, library_id, title, original_title, year, tmdb_id,
+               overview, runtime_min, poster_path, backdrop_path,
+               created_at, updated_at
+        FROM movies
+        WHERE id = ?
+    `, id).Scan(
+		&m.ID, &m.LibraryID, &m.Title, &m.OriginalTitle, &m.Year, &m.TMDBID,
+		&m.Overview, &m.RuntimeMin, &m.PosterPath, &m.BackdropPath,
+		&m.CreatedAt, &m.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (s *sqlStore) GetMovieByTitleAndYear(title string, year int, libraryID int64) (*domain.Movie, error) {
+	row := s.read.QueryRow(`
+        SELECT id, library_id, title, original_title, year, tmdb_id, overview,
+               runtime_min, poster_path, backdrop_path, created_at, updated_at
+        FROM movies
+        WHERE title = ? AND year = ? AND library_id = ?
+    `, title, year, libraryID)
+
+	var m domain.Movie
+	err := row.Scan(
+		&m.ID,
+		&m.LibraryID,
+		&m.Title,
+		&m.OriginalTitle,
+		&m.Year,
+		&m.TMDBID,
+		&m.Overview,
+		&m.RuntimeMin,
+		&m.PosterPath,
+		&m.BackdropPath,
+		&m.CreatedAt,
+		&m.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil // no movie found — not an error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+func (s *sqlStore) ListMoviesByLibrary(libraryID int64) ([]domain.Movie, error) {
+	rows, err := s.read.Query(`
+        SELECT id, library_id, title, original_title, year, tmdb_id,
+               overview, runtime_min, poster_path, backdrop_path,
+               created_at, updated_at
+        FROM movies
+        WHERE library_id = ?
+        ORDER BY title
+    `, libraryID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var result []domain.Movie
+	for rows.Next() {
+		var m domain.Movie
+		err := rows.Scan(
+			&m.ID, &m.LibraryID, &m.Title, &m.OriginalTitle, &m.Year, &m.TMDBID,
+			&m.Overview, &m.RuntimeMin, &m.PosterPath, &m.BackdropPath,
+			&m.CreatedAt, &m.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, m)
+	}
+	return result, rows.Err()
+}
+
+func (s *sqlStore) UpdateMovie(m *domain.Movie) error {
+	m.UpdatedAt = time.Now().UTC()
+
+	_, err := s.exec.Exec(`
+		UPDATE movies
+		SET
+			original_title = ?,
+			tmdb_id = ?,
+			overview = ?,
+			runtime_min = ?,
+			poster_path = ?,
+			backdrop_path = ?,
+			updated_at = ?
+		WHERE id = ?
+	`,
+		m.OriginalTitle,
+		m.TMDBID,
+		m.Overview,
+		m.RuntimeMin,
+		m.PosterPath,
+		m.BackdropPath,
+		m.UpdatedAt,
+		m.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return s.indexMovie(m.ID)
+}
+
+// ============================================================================
+// Series / Seasons / Episodes
+// ============================================================================
+
+func (s *sqlStore) CreateSeries(sr *domain.Series) error {
+	now := time.Now()
+	sr.CreatedAt = now
+	sr.UpdatedAt = now
+
+	id, err := s.insert(`
+        INSERT INTO series (library_id, title, original_title, year, tmdb_id, tvdb_id,
+                            overview, status, poster_path, backdrop_path,
+                            created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, sr.LibraryID, sr.Title, sr.OriginalTitle, sr.Year, sr.TMDBID, sr.TVDBID,
+		sr.Overview, sr.Status, sr.PosterPath, sr.BackdropPath,
+		sr.CreatedAt, sr.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	sr.ID = id
+	return s.indexSeries(id)
+}
+
+func (s *sqlStore) GetSeriesByTitleAndYear(title string, year int, libraryID int64) (*domain.Series, error) {
+	row := s.read.QueryRow(`
+        SELECT id, library_id, title, original_title, year, tmdb_id, tvdb_id,
+               overview, status, poster_path, backdrop_path, created_at, updated_at
+        FROM series
+        WHERE title = ? AND year = ? AND library_id = ?
+    `, title, year, libraryID)
+
+	return scanSeriesRow(row)
+}
+
+func (s *sqlStore) GetSeriesByTMDBID(tmdbID string, libraryID int64) (*domain.Series, error) {
+	row := s.read.QueryRow(`
+        SELECT id, library_id, title, original_title, year, tmdb_id, tvdb_id,
+               overview, status, poster_path, backdrop_path, created_at, updated_at
+        FROM series
+        WHERE tmdb_id = ? AND library_id = ?
+        ORDER BY id
+        LIMIT 1
+    `, tmdbID, libraryID)
+
+	return scanSeriesRow(row)
+}
+
+func (s *sqlStore) GetSeriesByTVDBID(tvdbID string, libraryID int64) (*domain.Series, error) {
+	row := s.read.QueryRow(`
+        SELECT id, library_id, title, original_title, year, tmdb_id, tvdb_id,
+               overview, status, poster_path, backdrop_path, created_at, updated_at
+        FROM series
+        WHERE tvdb_id = ? AND library_id = ?
+        ORDER BY id
+        LIMIT 1
+    `, tvdbID, libraryID)
+
+	return scanSeriesRow(row)
+}
+
+// scanSeriesRow scans a single series lookup; a missing row is not an error.
+func scanSeriesRow(row *sql.Row) (*domain.Series, error) {
+	var sr domain.Series
+	err := row.Scan(
+		&sr.ID,
+		&sr.LibraryID,
+		&sr.Title,
+		&sr.OriginalTitle,
+		&sr.Year,
+		&sr.TMDBID,
+		&sr.TVDBID,
+		&sr.Overview,
+		&sr.Status,
+		&sr.PosterPath,
+		&sr.BackdropPath,
+		&sr.CreatedAt,
+		&sr.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &sr, nil
+}
+
+func (s *sqlStore) GetSeries(id int64) (*domain.Series, error) {
+	var sr domain.Series
+	err := s.read.QueryRow(`
+        SELECT id, library_id, title, original_title, year, tmdb_id, tvdb_id,
+               overview, status, poster_path, backdrop_path, created_at, updated_at
+        FROM series
+        WHERE id = ?
+    `, id).Scan(
+		&sr.ID, &sr.LibraryID, &sr.Title, &sr.OriginalTitle, &sr.Year, &sr.TMDBID,
+		&sr.TVDBID, &sr.Overview, &sr.Status, &sr.PosterPath, &sr.BackdropPath,
+		&sr.CreatedAt, &sr.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &sr, nil
+}
+
+func (s *sqlStore) ListSeries() ([]*domain.Series, error) {
+	rows, err := s.read.Query(`
+        SELECT id, library_id, title, year, overview, poster_path, backdrop_path,
+               created_at, updated_at
+        FROM series
+        ORDER BY title, year
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []*domain.Series
+	for rows.Next() {
+		sr := &domain.Series{}
+		err := rows.Scan(
+			&sr.ID,
+			&sr.LibraryID,
+			&sr.Title,
+			&sr.Year,
+			&sr.Overview,
+			&sr.PosterPath,
+			&sr.BackdropPath,
+			&sr.CreatedAt,
+			&sr.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sr)
+	}
+
+	return out, rows.Err()
+}
+
+func (s *sqlStore) CreateSeason(se *domain.Season) error {
+	now := time.Now()
+	se.CreatedAt = now
+	se.UpdatedAt = now
+
+	id, err := s.insert(`
+        INSERT INTO seasons (
+            series_id,
+            season_number,
+            title,
+            overview,
+            poster_path,
+            air_date,
+            tmdb_id,
+            created_at,
+            updated_at
+        )
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `,
+		se.SeriesID,
+		se.Number,
+		se.Title,
+		se.Overview,
+		se.PosterPath,
+		se.AirDate,
+		se.TMDBID,
+		se.CreatedAt,
+		se.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	se.ID = id
+	return nil
+}
+
+func (s *sqlStore) GetSeasonBySeriesAndNumber(seriesID int64, number int) (*domain.Season, error) {
+	row := s.read.QueryRow(`
+        SELECT id, series_id, season_number, title, overview, poster_path,
+               air_date, tmdb_id, created_at, updated_at
+        FROM seasons
+        WHERE series_id = ? AND season_number = ?
+    `, seriesID, number)
+
+	var se domain.Season
+	err := row.Scan(
+		&se.ID,
+		&se.SeriesID,
+		&se.Number,
+		&se.Title,
+		&se.Overview,
+		&se.PosterPath,
+		&se.AirDate,
+		&se.TMDBID,
+		&se.CreatedAt,
+		&se.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &se, nil
+}
+
+func (s *sqlStore) GetSeason(id int64) (*domain.Season, error) {
+	row := s.read.QueryRow(`
+        SELECT id, series_id, season_number, title, overview, poster_path,
+               air_date, tmdb_id, created_at, updated_at
+        FROM seasons
+        WHERE id = ?
+    `, id)
+
+	var se domain.Season
+	err := row.Scan(
+		&se.ID,
+		&se.SeriesID,
+		&se.Number,
+		&se.Title,
+		&se.Overview,
+		&se.PosterPath,
+		&se.AirDate,
+		&se.TMDBID,
+		&se.CreatedAt,
+		&se.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &se, nil
+}
+
+func (s *sqlStore) ListSeasonsBySeries(seriesID int64) ([]domain.Season, error) {
+	rows, err := s.read.Query(`
+        SELECT id, series_id, season_number, title, overview, poster_path,
+               air_date, tmdb_id, created_at, updated_at
+        FROM seasons
+        WHERE series_id = ?
+        ORDER BY season_number
+    `, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []domain.Season
+	for rows.Next() {
+		var se domain.Season
+		err := rows.Scan(
+			&se.ID,
+			&se.SeriesID,
+			&se.Number,
+			&se.Title,
+			&se.Overview,
+			&se.PosterPath,
+			&se.AirDate,
+			&se.TMDBID,
+			&se.CreatedAt,
+			&se.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, se)
+	}
+	return out, rows.Err()
+}
+
+func (s *sqlStore) CreateEpisode(ep *domain.Episode) error {
+	now := time.Now()
+	ep.CreatedAt = now
+	ep.UpdatedAt = now
+
+	id, err := s.insert(`
+        INSERT INTO episodes (
+            season_id,
+            episode_number,
+            title,
+            overview,
+            air_date,
+            runtime_min,
+            still_path,
+            tmdb_id,
+            title_source,
+            created_at,
+            updated_at
+        )
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `,
+		ep.SeasonID,
+		ep.Number,
+		ep.Title,
+		ep.Overview,
+		ep.AirDate,
+		ep.RuntimeMin,
+		ep.StillPath,
+		ep.TMDBID,
+		ep.TitleSource,
+		ep.CreatedAt,
+		ep.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	ep.ID = id
+	return s.indexEpisode(id)
+}
+
+func (s *sqlStore) GetEpisode(id int64) (*domain.Episode, error) {
+	var ep domain.Episode
+	err := s.read.QueryRow(`
+        SELECT id, season_id, episode_number, title, overview,
+               air_date, runtime_min, still_path, tmdb_id, title_source,
+               created_at, updated_at
+        FROM episodes
+        WHERE id = ?
+    `, id).Scan(
+		&ep.ID,
+		&ep.SeasonID,
+		&ep.Number,
+		&ep.Title,
+		&ep.Overview,
+		&ep.AirDate,
+		&ep.RuntimeMin,
+		&ep.StillPath,
+		&ep.TMDBID,
+		&ep.TitleSource,
+		&ep.CreatedAt,
+		&ep.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &ep, nil
+}
+
+func (s *sqlStore) GetEpisodeBySeasonAndNumber(seasonID int64, number int) (*domain.Episode, error) {
+	row := s.read.QueryRow(`
+        SELECT id, season_id, episode_number, title, overview,
+               air_date, runtime_min, still_path, tmdb_id, title_source,
+               created_at, updated_at
+        FROM episodes
+        WHERE season_id = ? AND episode_number = ?
+    `, seasonID, number)
+
+	var ep domain.Episode
+	err := row.Scan(
+		&ep.ID,
+		&ep.SeasonID,
+		&ep.Number,
+		&ep.Title,
+		&ep.Overview,
+		&ep.AirDate,
+		&ep.RuntimeMin,
+		&ep.StillPath,
+		&ep.TMDBID,
+		&ep.TitleSource,
+		&ep.CreatedAt,
+		&ep.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ep, nil
+}
+
+func (s *sqlStore) ListEpisodesBySeason(seasonID int64) ([]domain.Episode, error) {
+	rows, err := s.read.Query(`
+        SELECT id, season_id, episode_number, title, overview,
+               air_date, runtime_min, still_path, tmdb_id, title_source,
+               created_at, updated_at
+        FROM episodes
+        WHERE season_id = ?
+        ORDER BY episode_number
+    `, seasonID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var episodes []domain.Episode
+	for rows.Next() {
+		var ep domain.Episode
+		err := rows.Scan(
+			&ep.ID,
+			&ep.SeasonID,
+			&ep.Number,
+			&ep.Title,
+			&ep.Overview,
+			&ep.AirDate,
+			&ep.RuntimeMin,
+			&ep.StillPath,
+			&ep.TMDBID,
+			&ep.TitleSource,
+			&ep.CreatedAt,
+			&ep.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		episodes = append(episodes, ep)
+	}
+	return episodes, rows.Err()
+}
+
+func (s *sqlStore) UpdateEpisode(e *domain.Episode) error {
+	e.UpdatedAt = time.Now().UTC()
+
+	_, err := s.exec.Exec(`
+		UPDATE episodes
+		SET
+			title = ?,
+			overview = ?,
+			air_date = ?,
+			runtime_min = ?,
+			still_path = ?,
+			tmdb_id = ?,
+			title_source = ?,
+			updated_at = ?
+		WHERE id = ?
+	`,
+		e.Title,
+		e.Overview,
+		e.AirDate,
+		e.RuntimeMin,
+		e.StillPath,
+		e.TMDBID,
+		e.TitleSource,
+		e.UpdatedAt,
+		e.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return s.indexEpisode(e.ID)
+}
+
+func (s *sqlStore) UpdateSeries(sr *domain.Series) error {
+	sr.UpdatedAt = time.Now().UTC()
+
+	_, err := s.exec.Exec(`
+		UPDATE series
+		SET
+			original_title = ?,
+			tmdb_id = ?,
+			tvdb_id = ?,
+			overview = ?,
+			status = ?,
+			poster_path = ?,
+			backdrop_path = ?,
+			updated_at = ?
+		WHERE id = ?
+	`,
+		sr.OriginalTitle,
+		sr.TMDBID,
+		sr.TVDBID,
+		sr.Overview,
+		sr.Status,
+		sr.PosterPath,
+		sr.BackdropPath,
+		sr.UpdatedAt,
+		sr.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return s.indexSeries(sr.ID)
+}
+
+func (s *sqlStore) UpdateSeason(se *domain.Season) error {
+	se.UpdatedAt = time.Now().UTC()
+
+	_, err := s.exec.Exec(`
+		UPDATE seasons
+		SET
+			title = ?,
+			overview = ?,
+			poster_path = ?,
+			air_date = ?,
+			tmdb_id = ?,
+			updated_at = ?
+		WHERE id = ?
+	`,
+		se.Title,
+		se.Overview,
+		se.PosterPath,
+		se.AirDate,
+		se.TMDBID,
+		se.UpdatedAt,
+		se.ID,
+	)
+
+	return err
+}
+
+// ============================================================================
+// Media Files
+// ============================================================================
+func (s *sqlStore) GetMediaFile(id int64) (*domain.MediaFile, error) {
+	row := s.read.QueryRow(`
+        SELECT id, library_id, movie_id, episode_id, path, size_bytes,
+               hash, is_missing, last_seen_at, missing_since, container, video_codec, audio_codec,
+               video_width, video_height, audio_channels, duration_sec,
+               bit_rate,
+               release_resolution, release_source, release_video_codec,
+               release_audio_codec, release_hdr, release_group,
+               is_remux, is_proper, is_repack,
+               probe_status, probe_error, probe_attempts, next_probe_at,
+               created_at, updated_at
+        FROM media_files
+        WHERE id = ?
+    `, id)
+
+	var mf domain.MediaFile
+
+	err := row.Scan(
+		&mf.ID,
+		&mf.LibraryID,
+		&mf.MovieID,
+		&mf.EpisodeID,
+		&mf.Path,
+		&mf.SizeBytes,
+		&mf.Hash,
+		&mf.IsMissing,
+		&mf.LastSeenAt,
+		&mf.MissingSince,
+		&mf.Container,
+		&mf.VideoCodec,
+		&mf.AudioCodec,
+		&mf.VideoWidth,
+		&mf.VideoHeight,
+		&mf.AudioChannels,
+		&mf.DurationSec,
+		&mf.BitRate,
+		&mf.ReleaseResolution,
+		&mf.ReleaseSource,
+		&mf.ReleaseVideoCodec,
+		&mf.ReleaseAudioCodec,
+		&mf.ReleaseHDR,
+		&mf.ReleaseGroup,
+		&mf.IsRemux,
+		&mf.IsProper,
+		&mf.IsRepack,
+		&mf.ProbeStatus,
+		&mf.ProbeError,
+		&mf.ProbeAttempts,
+		&mf.NextProbeAt,
+		&mf.CreatedAt,
+		&mf.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &mf, nil
+}
+
+func (s *sqlStore) CreateMediaFile(mf *domain.MediaFile) error {
+	now := time.Now().UTC()
+	mf.CreatedAt = now
+	mf.UpdatedAt = now
+
+	id, err := s.insertPrepared(`
+		INSERT INTO media_files (
+		    library_id,
+		    movie_id,
+		    episode_id,
+		    path,
+		    size_bytes,
+		    hash,
+		    is_missing,
+		    last_seen_at,
+		    missing_since,
+		    container,
+		    video_codec,
+		    audio_codec,
+		    video_width,
+		    video_height,
+		    audio_channels,
+		    duration_sec,
+		    bit_rate,
+		    release_resolution,
+		    release_source,
+		    release_video_codec,
+		    release_audio_codec,
+		    release_hdr,
+		    release_group,
+		    is_remux,
+		    is_proper,
+		    is_repack,
+		    probe_status,
+		    probe_error,
+		    probe_attempts,
+		    next_probe_at,
+		    created_at,
+		    updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		mf.LibraryID,
+		mf.MovieID,
+		mf.EpisodeID,
+		mf.Path,
+		mf.SizeBytes,
+		mf.Hash,
+		mf.IsMissing,
+		mf.LastSeenAt,
+		mf.MissingSince,
+		mf.Container,
+		mf.VideoCodec,
+		mf.AudioCodec,
+		mf.VideoWidth,
+		mf.VideoHeight,
+		mf.AudioChannels,
+		mf.DurationSec,
+		mf.BitRate,
+		mf.ReleaseResolution,
+		mf.ReleaseSource,
+		mf.ReleaseVideoCodec,
+		mf.ReleaseAudioCodec,
+		mf.ReleaseHDR,
+		mf.ReleaseGroup,
+		mf.IsRemux,
+		mf.IsProper,
+		mf.IsRepack,
+		probeStatus(mf.ProbeStatus),
+		mf.ProbeError,
+		mf.ProbeAttempts,
+		mf.NextProbeAt,
+		mf.CreatedAt,
+		mf.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	mf.ID = id
+	return nil
+}
+
+func (s *sqlStore) CreateMediaFileEpisode(link *domain.MediaFileEpisode) error {
+	id, err := s.insert(`
+        INSERT INTO media_file_episodes (media_file_id, episode_id)
+        VALUES (?, ?)
+        ON CONFLICT DO NOTHING
+    `, link.MediaFileID, link.EpisodeID)
+	if err != nil {
+		return err
+	}
+	link.ID = id
+	return nil
+}
+
+func (s *sqlStore) ListEpisodesByMediaFile(mediaFileID int64) ([]domain.Episode, error) {
+	rows, err := s.read.Query(`
+        SELECT e.id, e.season_id, e.episode_number, e.title, e.overview,
+               e.air_date, e.runtime_min, e.still_path, e.tmdb_id, e.title_source,
+               e.created_at, e.updated_at
+        FROM media_file_episodes mfe
+        JOIN episodes e ON e.id = mfe.episode_id
+        WHERE mfe.media_file_id = ?
+        ORDER BY e.episode_number
+    `, mediaFileID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var eps []domain.Episode
+	for rows.Next() {
+		var ep domain.Episode
+		err := rows.Scan(
+			&ep.ID,
+			&ep.SeasonID,
+			&ep.Number,
+			&ep.Title,
+			&ep.Overview,
+			&ep.AirDate,
+			&ep.RuntimeMin,
+			&ep.StillPath,
+			&ep.TMDBID,
+			&ep.TitleSource,
+			&ep.CreatedAt,
+			&ep.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		eps = append(eps, ep)
+	}
+	return eps, rows.Err()
+}
+
+func (s *sqlStore) ListMediaFilesByEpisode(episodeID int64) ([]domain.MediaFile, error) {
+	rows, err := s.read.Query(`
+        SELECT id, library_id, movie_id, episode_id, path, size_bytes, hash,
+               container, video_codec, audio_codec, video_width, video_height,
+               audio_channels, duration_sec,
+               bit_rate,
+               release_resolution, release_source, release_video_codec,
+               release_audio_codec, release_hdr, release_group,
+               is_remux, is_proper, is_repack,
+               created_at, updated_at
+        FROM media_files
+        WHERE episode_id = ?
+        ORDER BY id
+    `, episodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var result []domain.MediaFile
+	for rows.Next() {
+		var mf domain.MediaFile
+		err := rows.Scan(
+			&mf.ID, &mf.LibraryID, &mf.MovieID, &mf.EpisodeID, &mf.Path, &mf.SizeBytes,
+			&mf.Hash, &mf.Container, &mf.VideoCodec, &mf.AudioCodec,
+			&mf.VideoWidth, &mf.VideoHeight, &mf.AudioChannels, &mf.DurationSec,
+			&mf.BitRate,
+			&mf.ReleaseResolution, &mf.ReleaseSource, &mf.ReleaseVideoCodec,
+			&mf.ReleaseAudioCodec, &mf.ReleaseHDR, &mf.ReleaseGroup,
+			&mf.IsRemux, &mf.IsProper, &mf.IsRepack,
+			&mf.CreatedAt, &mf.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, mf)
+	}
+	return result, rows.Err()
+}
+
+func (s *sqlStore) ListMediaFilesByMovie(movieID int64) ([]domain.MediaFile, error) {
+	rows, err := s.read.Query(`
+        SELECT id, library_id, movie_id, episode_id, path, size_bytes,
+               hash, container, video_codec, audio_codec,
+               video_width, video_height, audio_channels, duration_sec,
+               bit_rate,
+               release_resolution, release_source, release_video_codec,
+               release_audio_codec, release_hdr, release_group,
+               is_remux, is_proper, is_repack,
+               created_at, updated_at
+        FROM media_files
+        WHERE movie_id = ?
+        ORDER BY id
+    `, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var list []domain.MediaFile
+
+	for rows.Next() {
+		var mf domain.MediaFile
+		err := rows.Scan(
+			&mf.ID,
+			&mf.LibraryID,
+			&mf.MovieID,
+			&mf.EpisodeID,
+			&mf.Path,
+			&mf.SizeBytes,
+			&mf.Hash,
+			&mf.Container,
+			&mf.VideoCodec,
+			&mf.AudioCodec,
+			&mf.VideoWidth,
+			&mf.VideoHeight,
+			&mf.AudioChannels,
+			&mf.DurationSec,
+			&mf.BitRate,
+			&mf.ReleaseResolution,
+			&mf.ReleaseSource,
+			&mf.ReleaseVideoCodec,
+			&mf.ReleaseAudioCodec,
+			&mf.ReleaseHDR,
+			&mf.ReleaseGroup,
+			&mf.IsRemux,
+			&mf.IsProper,
+			&mf.IsRepack,
+			&mf.CreatedAt,
+			&mf.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, mf)
+	}
+
+	return list, rows.Err()
+}
+
+func (s *sqlStore) GetMediaFileByPath(path string) (*domain.MediaFile, error) {
+	const q = `
+		SELECT
+			id,
+			library_id,
+			movie_id,
+			episode_id,
+			path,
+			size_bytes,
+			hash,
+			is_missing,
+			missing_since,
+			last_seen_at,
+			container,
+			video_codec,
+			audio_codec,
+			video_width,
+			video_height,
+			audio_channels,
+			duration_sec,
+			bit_rate,
+			release_resolution,
+			release_source,
+			release_video_codec,
+			release_audio_codec,
+			release_hdr,
+			release_group,
+			is_remux,
+			is_proper,
+			is_repack,
+			probe_status,
+			probe_error,
+			probe_attempts,
+			next_probe_at,
+			created_at,
+			updated_at
+		FROM media_files
+		WHERE path = ?
+		LIMIT 1
+	`
+
+	st, err := s.prepared(s.readStmts, q)
+	if err != nil {
+		return nil, err
+	}
+
+	var mf domain.MediaFile
+	err = st.QueryRow(path).Scan(
+		&mf.ID,
+		&mf.LibraryID,
+		&mf.MovieID,
+		&mf.EpisodeID,
+		&mf.Path,
+		&mf.SizeBytes,
+		&mf.Hash,
+		&mf.IsMissing,
+		&mf.MissingSince,
+		&mf.LastSeenAt,
+		&mf.Container,
+		&mf.VideoCodec,
+		&mf.AudioCodec,
+		&mf.VideoWidth,
+		&mf.VideoHeight,
+		&mf.AudioChannels,
+		&mf.DurationSec,
+		&mf.BitRate,
+		&mf.ReleaseResolution,
+		&mf.ReleaseSource,
+		&mf.ReleaseVideoCodec,
+		&mf.ReleaseAudioCodec,
+		&mf.ReleaseHDR,
+		&mf.ReleaseGroup,
+		&mf.IsRemux,
+		&mf.IsProper,
+		&mf.IsRepack,
+		&mf.ProbeStatus,
+		&mf.ProbeError,
+		&mf.ProbeAttempts,
+		&mf.NextProbeAt,
+		&mf.CreatedAt,
+		&mf.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &mf, nil
+}
+
+func (s *sqlStore) UpdateMediaFile(mf *domain.MediaFile) error {
+	const q = `
+	UPDATE media_files SET
+	    movie_id = ?,
+	    episode_id = ?,
+	    size_bytes = ?,
+	    hash = ?,
+	    container = ?,
+	    video_codec = ?,
+	    audio_codec = ?,
+	    video_width = ?,
+	    video_height = ?,
+	    audio_channels = ?,
+	    duration_sec = ?,
+	    bit_rate = ?,
+	    release_resolution = ?,
+	    release_source = ?,
+	    release_video_codec = ?,
+	    release_audio_codec = ?,
+	    release_hdr = ?,
+	    release_group = ?,
+	    is_remux = ?,
+	    is_proper = ?,
+	    is_repack = ?,
+	    probe_status = ?,
+	    probe_error = ?,
+	    probe_attempts = ?,
+	    next_probe_at = ?,
+	    is_missing = FALSE,
+	    last_seen_at = ?,
+	    missing_since = NULL,
+	    updated_at = ?
+	WHERE id = ?
+	`
+
+	_, err := s.exec.Exec(
+		q,
+		mf.MovieID,
+		mf.EpisodeID,
+		mf.SizeBytes,
+		mf.Hash,
+		mf.Container,
+		mf.VideoCodec,
+		mf.AudioCodec,
+		mf.VideoWidth,
+		mf.VideoHeight,
+		mf.AudioChannels,
+		mf.DurationSec,
+		mf.BitRate,
+		mf.ReleaseResolution,
+		mf.ReleaseSource,
+		mf.ReleaseVideoCodec,
+		mf.ReleaseAudioCodec,
+		mf.ReleaseHDR,
+		mf.ReleaseGroup,
+		mf.IsRemux,
+		mf.IsProper,
+		mf.IsRepack,
+		probeStatus(mf.ProbeStatus),
+		mf.ProbeError,
+		mf.ProbeAttempts,
+		mf.NextProbeAt,
+		mf.LastSeenAt,
+		time.Now().UTC(),
+		mf.ID,
+	)
+	return err
+}
+
+// ListMediaFilesWithProbeErrors returns the present files of a library that
+// ffprobe could not read.
+func (s *sqlStore) ListMediaFilesWithProbeErrors(libraryID int64) ([]domain.MediaFile, error) {
+	rows, err := s.read.Query(`
+        SELECT id, library_id, movie_id, episode_id, path, size_bytes, hash,
+               is_missing, last_seen_at, container,
+               release_resolution, release_source, release_video_codec,
+               release_audio_codec, release_hdr, release_group,
+               is_remux, is_proper, is_repack,
+               probe_status, probe_error, probe_attempts, next_probe_at,
+               created_at, updated_at
+        FROM media_files
+        WHERE library_id = ? AND probe_status = ? AND is_missing = FALSE
+        ORDER BY path
+    `, libraryID, domain.ProbeStatusError)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var list []domain.MediaFile
+	for rows.Next() {
+		var mf domain.MediaFile
+		err := rows.Scan(
+			&mf.ID, &mf.LibraryID, &mf.MovieID, &mf.EpisodeID, &mf.Path, &mf.SizeBytes, &mf.Hash,
+			&mf.IsMissing, &mf.LastSeenAt, &mf.Container,
+			&mf.ReleaseResolution, &mf.ReleaseSource, &mf.ReleaseVideoCodec,
+			&mf.ReleaseAudioCodec, &mf.ReleaseHDR, &mf.ReleaseGroup,
+			&mf.IsRemux, &mf.IsProper, &mf.IsRepack,
+			&mf.ProbeStatus, &mf.ProbeError, &mf.ProbeAttempts, &mf.NextProbeAt,
+			&mf.CreatedAt, &mf.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, mf)
+	}
+	return list, rows.Err()
+}
+
+// probeStatus defaults files built without a probe outcome to OK.
+func probeStatus(st domain.ProbeStatus) domain.ProbeStatus {
+	if st == "" {
+		return domain.ProbeStatusOK
+	}
+	return st
+}
+
+func (s *sqlStore) MarkMissingMediaFiles(
+	libraryID int64,
+	scanStartedAt time.Time,
+	skipRoots ...string,
+) (int64, error) {
+
+	q := `
+		UPDATE media_files
+		SET
+			is_missing = TRUE,
+			missing_since = COALESCE(missing_since, ?)
+		WHERE
+			library_id = ?
+			AND (
+				last_seen_at IS NULL
+				OR last_seen_at < ?
+			)
+			AND is_missing = FALSE
+	`
+	args := []any{scanStartedAt, libraryID, scanStartedAt}
+	for _, root := range skipRoots {
+		cond, rootArgs := rootCond("path", root)
+		q += ` AND NOT ` + cond
+		args = append(args, rootArgs...)
+	}
+
+	res, err := s.exec.Exec(q, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+func (s *sqlStore) MarkMediaFileSeen(
+	id int64,
+	seenAt time.Time,
+) error {
+
+	const q = `
+		UPDATE media_files
+		SET
+			last_seen_at = ?,
+			is_missing = FALSE,
+			missing_since = NULL,
+			updated_at = ?
+		WHERE id = ?
+	`
+
+	_, err := s.exec.Exec(q, seenAt, seenAt, id)
+	return err
+}
+
+// ============================================================================
+// Subtitles
+// ============================================================================
+
+func (s *sqlStore) CreateSubtitleTrack(st *domain.SubtitleTrack) error {
+	id, err := s.insertPrepared(`
+        INSERT INTO subtitle_tracks (
+            media_file_id, source, external_path, stream_index, language,
+            title, is_forced, is_sdh, is_default, is_commentary, is_visual_impaired,
+            is_dub, is_original, format, is_bitmap, derived_from_id
+        )
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, st.MediaFileID, st.Source, st.ExternalPath, st.StreamIndex, st.Language,
+		st.Title, st.IsForced, st.IsSDH, st.IsDefault, st.IsCommentary, st.IsVisualImpaired,
+		st.IsDub, st.IsOriginal, st.Format, st.IsBitmap, st.DerivedFromID)
+
+	if err != nil {
+		return err
+	}
+	st.ID = id
+	return nil
+}
+
+func (s *sqlStore) ListSubtitleTracks(mediaFileID int64) ([]domain.SubtitleTrack, error) {
+	rows, err := s.read.Query(`
+        SELECT id, media_file_id, source, external_path, stream_index, language,
+               title, is_forced, is_sdh, is_default, is_commentary, is_visual_impaired,
+               is_dub, is_original, format, is_bitmap, offset_ms, derived_from_id
+        FROM subtitle_tracks
+        WHERE media_file_id = ?
+        ORDER BY id
+    `, mediaFileID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var list []domain.SubtitleTrack
+	for rows.Next() {
+		var st domain.SubtitleTrack
+		err := rows.Scan(
+			&st.ID, &st.MediaFileID, &st.Source, &st.ExternalPath, &st.StreamIndex,
+			&st.Language, &st.Title, &st.IsForced, &st.IsSDH, &st.IsDefault,
+			&st.IsCommentary, &st.IsVisualImpaired, &st.IsDub, &st.IsOriginal,
+			&st.Format, &st.IsBitmap, &st.OffsetMs, &st.DerivedFromID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, st)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqlStore) GetSubtitleTrack(id int64) (*domain.SubtitleTrack, error) {
+	row := s.read.QueryRow(`
+        SELECT id, media_file_id, source, external_path, stream_index, language,
+               title, is_forced, is_sdh, is_default, is_commentary, is_visual_impaired,
+               is_dub, is_original, format, is_bitmap, offset_ms, derived_from_id
+        FROM subtitle_tracks
+        WHERE id = ?
+        LIMIT 1
+    `, id)
+
+	var st domain.SubtitleTrack
+	err := row.Scan(
+		&st.ID, &st.MediaFileID, &st.Source, &st.ExternalPath, &st.StreamIndex,
+		&st.Language, &st.Title, &st.IsForced, &st.IsSDH, &st.IsDefault,
+		&st.IsCommentary, &st.IsVisualImpaired, &st.IsDub, &st.IsOriginal,
+		&st.Format, &st.IsBitmap, &st.OffsetMs, &st.DerivedFromID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (s *sqlStore) UpdateSubtitleTrack(st *domain.SubtitleTrack) error {
+	_, err := s.exec.Exec(`
+        UPDATE subtitle_tracks
+        SET source = ?, external_path = ?, stream_index = ?, language = ?,
+            title = ?, is_forced = ?, is_sdh = ?, is_default = ?, is_commentary = ?,
+            is_visual_impaired = ?, is_dub = ?, is_original = ?, format = ?, is_bitmap = ?
+        WHERE id = ?
+    `, st.Source, st.ExternalPath, st.StreamIndex, st.Language,
+		st.Title, st.IsForced, st.IsSDH, st.IsDefault, st.IsCommentary,
+		st.IsVisualImpaired, st.IsDub, st.IsOriginal, st.Format, st.IsBitmap, st.ID)
+	return err
+}
+
+// SetSubtitleTrackOffset stores the user's timing correction. It is kept out
+// of UpdateSubtitleTrack so rescans never reset it.
+func (s *sqlStore) SetSubtitleTrackOffset(id int64, offsetMs int64) error {
+	_, err := s.exec.Exec(`UPDATE subtitle_tracks SET offset_ms = ? WHERE id = ?`, offsetMs, id)
+	return err
+}
+
+func (s *sqlStore) DeleteSubtitleTrack(id int64) error {
+	_, err := s.exec.Exec(`DELETE FROM subtitle_tracks WHERE id = ?`, id)
+	return err
+}
+
+func (s *sqlStore) CreateAudioTrack(at *domain.AudioTrack) error {
+	id, err := s.insertPrepared(`
+        INSERT INTO audio_tracks (
+            media_file_id, stream_index, language, title, codec, profile, channels,
+            bit_rate, is_default, is_commentary, is_hearing_impaired,
+            is_visual_impaired, is_dub, is_original
+        )
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, at.MediaFileID, at.StreamIndex, at.Language, at.Title, at.Codec, at.Profile, at.Channels,
+		at.BitRate, at.IsDefault, at.IsCommentary, at.IsHearingImpaired,
+		at.IsVisualImpaired, at.IsDub, at.IsOriginal)
+	if err != nil {
+		return err
+	}
+	at.ID = id
+	return nil
+}
+
+func (s *sqlStore) ListAudioTracks(mediaFileID int64) ([]domain.AudioTrack, error) {
+	rows, err := s.read.Query(`
+        SELECT id, media_file_id, stream_index, language, title, codec, profile, channels,
+               bit_rate, is_default, is_commentary, is_hearing_impaired,
+               is_visual_impaired, is_dub, is_original
+        FROM audio_tracks
+        WHERE media_file_id = ?
+        ORDER BY stream_index
+    `, mediaFileID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var list []domain.AudioTrack
+	for rows.Next() {
+		var at domain.AudioTrack
+		if err := rows.Scan(
+			&at.ID, &at.MediaFileID, &at.StreamIndex, &at.Language, &at.Title,
+			&at.Codec, &at.Profile, &at.Channels, &at.BitRate, &at.IsDefault,
+			&at.IsCommentary, &at.IsHearingImpaired, &at.IsVisualImpaired,
+			&at.IsDub, &at.IsOriginal,
+		); err != nil {
+			return nil, err
+		}
+		list = append(list, at)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqlStore) UpdateAudioTrack(at *domain.AudioTrack) error {
+	_, err := s.exec.Exec(`
+        UPDATE audio_tracks
+        SET stream_index = ?, language = ?, title = ?, codec = ?, profile = ?,
+            channels = ?, bit_rate = ?, is_default = ?, is_commentary = ?,
+            is_hearing_impaired = ?, is_visual_impaired = ?, is_dub = ?, is_original = ?
+        WHERE id = ?
+    `, at.StreamIndex, at.Language, at.Title, at.Codec, at.Profile,
+		at.Channels, at.BitRate, at.IsDefault, at.IsCommentary,
+		at.IsHearingImpaired, at.IsVisualImpaired, at.IsDub, at.IsOriginal, at.ID)
+	return err
+}
+
+func (s *sqlStore) DeleteAudioTrack(id int64) error {
+	_, err := s.exec.Exec(`DELETE FROM audio_tracks WHERE id = ?`, id)
+	return err
+}
+
+// ============================================================================
+// Video Streams
+// ============================================================================
+
+func (s *sqlStore) CreateVideoStream(vs *domain.VideoStream) error {
+	id, err := s.insert(`
+        INSERT INTO video_streams (
+            media_file_id, stream_index, codec, profile, level, width, height,
+            bit_rate, frame_rate, bit_depth, pixel_format, color_range,
+            color_space, color_transfer, color_primaries, hdr_formats,
+            scan_type, is_default
+        )
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, vs.MediaFileID, vs.StreamIndex, vs.Codec, vs.Profile, vs.Level, vs.Width, vs.Height,
+		vs.BitRate, vs.FrameRate, vs.BitDepth, vs.PixelFormat, vs.ColorRange,
+		vs.ColorSpace, vs.ColorTransfer, vs.ColorPrimaries, vs.HDRFormats,
+		vs.ScanType, vs.IsDefault)
+	if err != nil {
+		return err
+	}
+	vs.ID = id
+	return nil
+}
+
+func (s *sqlStore) ListVideoStreams(mediaFileID int64) ([]domain.VideoStream, error) {
+	rows, err := s.read.Query(`
+        SELECT id, media_file_id, stream_index, codec, profile, level, width, height,
+               bit_rate, frame_rate, bit_depth, pixel_format, color_range,
+               color_space, color_transfer, color_primaries, hdr_formats,
+               scan_type, is_default
+        FROM video_streams
+        WHERE media_file_id = ?
+        ORDER BY stream_index
+    `, mediaFileID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var list []domain.VideoStream
+	for rows.Next() {
+		var vs domain.VideoStream
+		if err := rows.Scan(
+			&vs.ID, &vs.MediaFileID, &vs.StreamIndex, &vs.Codec, &vs.Profile,
+			&vs.Level, &vs.Width, &vs.Height, &vs.BitRate, &vs.FrameRate,
+			&vs.BitDepth, &vs.PixelFormat, &vs.ColorRange, &vs.ColorSpace,
+			&vs.ColorTransfer, &vs.ColorPrimaries, &vs.HDRFormats,
+			&vs.ScanType, &vs.IsDefault,
+		); err != nil {
+			return nil, err
+		}
+		list = append(list, vs)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqlStore) DeleteVideoStreams(mediaFileID int64) error {
+	_, err := s.exec.Exec(`DELETE FROM video_streams WHERE media_file_id = ?`, mediaFileID)
+	return err
+}
+
+// ============================================================================
+// Chapters
+// ============================================================================
+
+func (s *sqlStore) CreateChapter(ch *domain.Chapter) error {
+	id, err := s.insert(`
+        INSERT INTO chapters (media_file_id, chapter_index, start_ms, end_ms, title)
+        VALUES (?, ?, ?, ?, ?)
+    `, ch.MediaFileID, ch.Index, ch.StartMs, ch.EndMs, ch.Title)
+	if err != nil {
+		return err
+	}
+	ch.ID = id
+	return nil
+}
+
+func (s *sqlStore) ListChapters(mediaFileID int64) ([]domain.Chapter, error) {
+	rows, err := s.read.Query(`
+        SELECT id, media_file_id, chapter_index, start_ms, end_ms, title
+        FROM chapters
+        WHERE media_file_id = ?
+        ORDER BY chapter_index
+    `, mediaFileID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var list []domain.Chapter
+	for rows.Next() {
+		var ch domain.Chapter
+		if err := rows.Scan(
+			&ch.ID, &ch.MediaFileID, &ch.Index, &ch.StartMs, &ch.EndMs, &ch.Title,
+		); err != nil {
+			return nil, err
+		}
+		list = append(list, ch)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqlStore) DeleteChapters(mediaFileID int64) error {
+	_, err := s.exec.Exec(`DELETE FROM chapters WHERE media_file_id = ?`, mediaFileID)
+	return err
+}
+
+// ============================================================================
+// Clean-up
+// ============================================================================
+
+func (s *sqlStore) CleanupEmptySeries(libraryID int64) (int64, error) {
+	const q = `
+	DELETE FROM series
+	WHERE id IN (
+		SELECT sr.id
+		FROM series sr
+		LEFT JOIN seasons s ON s.series_id = sr.id
+		WHERE sr.library_id = ?
+		GROUP BY sr.id
+		HAVING COUNT(s.id) = 0
+	)
+	`
+	return s.cleanup(q, libraryID)
+}
+
+func (s *sqlStore) CleanupEmptySeasons(libraryID int64) (int64, error) {
+	const q = `
+	DELETE FROM seasons
+	WHERE id IN (
+		SELECT s.id
+		FROM seasons s
+		JOIN series sr ON sr.id = s.series_id
+		LEFT JOIN episodes e ON e.season_id = s.id
+		WHERE sr.library_id = ?
+		GROUP BY s.id
+		HAVING COUNT(e.id) = 0
+	)
+	`
+	return s.cleanup(q, libraryID)
+}
+
+func (s *sqlStore) CleanupEmptyEpisodes(libraryID int64) (int64, error) {
+	const q = `
+	DELETE FROM episodes
+	WHERE id IN (
+	    SELECT e.id
+	    FROM episodes e
+	    JOIN seasons s ON s.id = e.season_id
+	    JOIN series sr ON sr.id = s.series_id
+	    LEFT JOIN media_file_episodes mfe
+	        ON mfe.episode_id = e.id
+	    LEFT JOIN media_files mf
+	        ON mf.id = mfe.media_file_id
+	        AND mf.is_missing = FALSE
+	    WHERE sr.library_id = ?
+	    GROUP BY e.id
+	    HAVING COUNT(mf.id) = 0
+	)
+	`
+	return s.cleanup(q, libraryID)
+}
+
+// cleanup runs a delete of catalog rows and drops their search documents.
+func (s *sqlStore) cleanup(q string, libraryID int64) (int64, error) {
+	res, err := s.exec.Exec(q, libraryID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return n, err
+	}
+	return n, s.pruneSearchIndex()
+}
+
+func (s *sqlStore) CleanupMissingMediaFileLinks(libraryID int64) (int64, error) {
+	const q = `
+	DELETE FROM media_file_episodes
+	WHERE media_file_id IN (
+		SELECT id
+		FROM media_files
+		WHERE library_id = ?
+		  AND is_missing = TRUE
+	)
+	`
+	res, err := s.exec.Exec(q, libraryID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *sqlStore) UnlinkMissingMediaFiles(libraryID int64) (int64, error) {
+	// 1) Delete range links for missing files
+	const delLinks = `
+	DELETE FROM media_file_episodes
+	WHERE media_file_id IN (
+		SELECT id FROM media_files
+		WHERE library_id = ? AND is_missing = TRUE
+	)
+	`
+	if _, err := s.exec.Exec(delLinks, libraryID); err != nil {
+		return 0, err
+	}
+
+	// 2) Null direct links
+	const upd = `
+	UPDATE media_files
+	SET episode_id = NULL,
+	    movie_id = NULL,
+	    updated_at = ?
+	WHERE library_id = ?
+	  AND is_missing = TRUE
+	  AND (episode_id IS NOT NULL OR movie_id IS NOT NULL)
+	`
+	res, err := s.exec.Exec(upd, time.Now().UTC(), libraryID)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -1,11 +1,17 @@
 package store
 
 import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/bastianvv/vio/internal/domain"
 )
 
+// The Query* methods return one page of a filtered, sorted listing and the
+// size of the whole listing.
 type Store interface {
 	Close() error
 
@@ -14,10 +20,15 @@ type Store interface {
 	ListLibraries() ([]domain.Library, error)
 	GetLibrary(id int64) (*domain.Library, error)
 	UpdateLibrary(lib *domain.Library) error
+	DeleteLibrary(id int64) error
+	// RelocateLibrary moves one root of a library, with the stored paths
+	// under it.
+	RelocateLibrary(id int64, from, to string) (files, sidecars int, err error)
 
 	// Movies
 	CreateMovie(m *domain.Movie) error
 	ListMoviesByLibrary(libraryID int64) ([]domain.Movie, error)
+	QueryMovies(f ListFilter, p Page) ([]domain.Movie, int, error)
 	GetMovie(id int64) (*domain.Movie, error)
 	GetMovieByTitleAndYear(title string, year int, libraryID int64) (*domain.Movie, error)
 	UpdateMovie(m *domain.Movie) error
@@ -25,8 +36,11 @@ type Store interface {
 	// Series
 	CreateSeries(s *domain.Series) error
 	GetSeries(id int64) (*domain.Series, error)
-	GetSeriesByTitle(title string, libraryID int64) (*domain.Series, error)
+	GetSeriesByTitleAndYear(title string, year int, libraryID int64) (*domain.Series, error)
+	GetSeriesByTMDBID(tmdbID string, libraryID int64) (*domain.Series, error)
+	GetSeriesByTVDBID(tvdbID string, libraryID int64) (*domain.Series, error)
 	ListSeries() ([]*domain.Series, error)
+	QuerySeries(f ListFilter, p Page) ([]domain.Series, int, error)
 	UpdateSeries(s *domain.Series) error
 
 	// Seasons
@@ -40,6 +54,7 @@ type Store interface {
 	CreateEpisode(ep *domain.Episode) error
 	GetEpisode(id int64) (*domain.Episode, error)
 	ListEpisodesBySeason(seasonID int64) ([]domain.Episode, error)
+	QueryEpisodes(f ListFilter, p Page) ([]domain.Episode, int, error)
 	GetEpisodeBySeasonAndNumber(seasonID int64, number int) (*domain.Episode, error)
 	UpdateEpisode(ep *domain.Episode) error
 
@@ -52,15 +67,34 @@ type Store interface {
 	ListEpisodesByMediaFile(mediaFileID int64) ([]domain.Episode, error)
 	GetMediaFileByPath(path string) (*domain.MediaFile, error)
 	UpdateMediaFile(mf *domain.MediaFile) error
-	MarkMissingMediaFiles(libraryID int64, scanStartedAt time.Time) (int64, error)
+	// MarkMissingMediaFiles leaves the files under skipRoots, roots that
+	// were offline for the scan, as they were.
+	MarkMissingMediaFiles(libraryID int64, scanStartedAt time.Time, skipRoots ...string) (int64, error)
 	MarkMediaFileSeen(id int64, seenAt time.Time) error
+	ListMediaFilesWithProbeErrors(libraryID int64) ([]domain.MediaFile, error)
+	QueryMediaFiles(f ListFilter, p Page) ([]domain.MediaFile, int, error)
 
 	// Subtitles
 	CreateSubtitleTrack(st *domain.SubtitleTrack) error
 	ListSubtitleTracks(mediaFileID int64) ([]domain.SubtitleTrack, error)
 	GetSubtitleTrack(id int64) (*domain.SubtitleTrack, error)
+	UpdateSubtitleTrack(st *domain.SubtitleTrack) error
+	SetSubtitleTrackOffset(id int64, offsetMs int64) error
+	DeleteSubtitleTrack(id int64) error
 	CreateAudioTrack(at *domain.AudioTrack) error
 	ListAudioTracks(mediaFileID int64) ([]domain.AudioTrack, error)
+	UpdateAudioTrack(at *domain.AudioTrack) error
+	DeleteAudioTrack(id int64) error
+
+	// Video streams
+	CreateVideoStream(vs *domain.VideoStream) error
+	ListVideoStreams(mediaFileID int64) ([]domain.VideoStream, error)
+	DeleteVideoStreams(mediaFileID int64) error
+
+	// Chapters
+	CreateChapter(ch *domain.Chapter) error
+	ListChapters(mediaFileID int64) ([]domain.Chapter, error)
+	DeleteChapters(mediaFileID int64) error
 
 	// Cleanup
 	CleanupMissingMediaFileLinks(libraryID int64) (int64, error)
@@ -69,6 +103,62 @@ type Store interface {
 	CleanupEmptySeries(libraryID int64) (int64, error)
 	UnlinkMissingMediaFiles(libraryId int64) (int64, error)
 
+	// Search returns the best matches for query, best first. typ and
+	// libraryID narrow the results when set.
+	Search(query string, typ domain.SearchResultType, libraryID int64, limit int) ([]domain.SearchResult, error)
+
 	//DB
 	WithTx(fn func(tx Store) error) error
 }
+
+// Open opens and migrates the store a DSN names: postgres:// or
+// postgresql:// URLs select PostgreSQL, anything else is a SQLite file path.
+func Open(dsn string) (Store, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		s, err := NewPostgresStore(dsn)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.Migrate(); err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+		return s, nil
+	}
+
+	s, err := NewSQLiteStore(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Migrate(); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// setRoots makes lib.Path and lib.Roots agree before a library is written.
+// Path is the first root: a library with only a Path has that one root,
+// and a Path that differs from Roots[0] replaces it.
+func setRoots(lib *domain.Library) error {
+	switch {
+	case len(lib.Roots) == 0:
+		lib.Roots = []string{lib.Path}
+	case lib.Path == "":
+		lib.Path = lib.Roots[0]
+	case lib.Path != lib.Roots[0]:
+		lib.Roots = append([]string{lib.Path}, lib.Roots[1:]...)
+	}
+	for i, r := range lib.Roots {
+		if r == "" || slices.Contains(lib.Roots[:i], r) {
+			return fmt.Errorf("invalid library roots %q", lib.Roots)
+		}
+	}
+	return nil
+}
+
+// UnderRoot reports whether path is root or lies below it.
+func UnderRoot(path, root string) bool {
+	root = strings.TrimSuffix(root, string(filepath.Separator))
+	return path == root || strings.HasPrefix(path, root+string(filepath.Separator))
+}
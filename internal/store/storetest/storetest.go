@@ -0,0 +1,1681 @@
+// Package storetest is a behaviour suite shared by every store.Store
+// implementation. A new backend passes when
+//
+//	storetest.Run(t, func(t *testing.T) store.Store { ... })
+//
+// succeeds with a fresh, migrated, empty store per call.
+package storetest
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/bastianvv/vio/internal/domain"
+	"github.com/bastianvv/vio/internal/store"
+)
+
+// Factory returns an empty store. It should register its own cleanup.
+type Factory func(t *testing.T) store.Store
+
+// Run exercises s against the conventions the scanner and handlers rely on.
+func Run(t *testing.T, newStore Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.Store)
+	}{
+		{"Libraries", testLibraries},
+		{"DeleteLibrary", testDeleteLibrary},
+		{"RelocateLibrary", testRelocateLibrary},
+		{"LibraryRoots", testLibraryRoots},
+		{"Movies", testMovies},
+		{"Series", testSeries},
+		{"SeasonsAndEpisodes", testSeasonsAndEpisodes},
+		{"MediaFiles", testMediaFiles},
+		{"MediaFileConstraints", testMediaFileConstraints},
+		{"ProbeErrors", testProbeErrors},
+		{"MarkMissingMediaFiles", testMarkMissingMediaFiles},
+		{"MarkMissingSkipsOfflineRoots", testMarkMissingSkipsOfflineRoots},
+		{"MultiEpisodeLinks", testMultiEpisodeLinks},
+		{"CleanupCascade", testCleanupCascade},
+		{"UnlinkMissingMediaFiles", testUnlinkMissingMediaFiles},
+		{"SubtitleTracks", testSubtitleTracks},
+		{"StreamsAndChapters", testStreamsAndChapters},
+		{"WithTxCommit", testWithTxCommit},
+		{"WithTxRollback", testWithTxRollback},
+		{"ReadsDuringTx", testReadsDuringTx},
+		{"NestedWithTx", testNestedWithTx},
+		{"Search", testSearch},
+		{"QueryMovies", testQueryMovies},
+		{"QuerySeriesAndEpisodes", testQuerySeriesAndEpisodes},
+		{"QueryMediaFiles", testQueryMediaFiles},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Fixtures
+// ----------------------------------------------------------------------------
+
+// Timestamps are truncated to whole seconds so every backend round-trips
+// them exactly.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func library(t *testing.T, s store.Store, path string, typ domain.LibraryType) *domain.Library {
+	t.Helper()
+	lib := &domain.Library{Name: path, Type: typ, Path: path}
+	must(t, s.CreateLibrary(lib))
+	return lib
+}
+
+func series(t *testing.T, s store.Store, libraryID int64, title string, year int) *domain.Series {
+	t.Helper()
+	sr := &domain.Series{LibraryID: libraryID, Title: title, Year: year}
+	must(t, s.CreateSeries(sr))
+	return sr
+}
+
+func season(t *testing.T, s store.Store, seriesID int64, number int) *domain.Season {
+	t.Helper()
+	se := &domain.Season{SeriesID: seriesID, Number: number}
+	must(t, s.CreateSeason(se))
+	return se
+}
+
+func episode(t *testing.T, s store.Store, seasonID int64, number int) *domain.Episode {
+	t.Helper()
+	ep := &domain.Episode{SeasonID: seasonID, Number: number, Title: fmt.Sprintf("Episode %d", number)}
+	must(t, s.CreateEpisode(ep))
+	return ep
+}
+
+// episodeFile creates a present file for the episodes, linked directly to
+// the first one and through media_file_episodes to all of them, the way
+// the scanner stores range files.
+func episodeFile(t *testing.T, s store.Store, libraryID int64, path string, seenAt time.Time, eps ...*domain.Episode) *domain.MediaFile {
+	t.Helper()
+	mf := &domain.MediaFile{LibraryID: libraryID, Path: path, LastSeenAt: &seenAt}
+	if len(eps) > 0 {
+		mf.EpisodeID = &eps[0].ID
+	}
+	must(t, s.CreateMediaFile(mf))
+
+	for _, ep := range eps {
+		must(t, s.CreateMediaFileEpisode(&domain.MediaFileEpisode{MediaFileID: mf.ID, EpisodeID: ep.ID}))
+	}
+	return mf
+}
+
+func episodeIDs(eps []domain.Episode) []int64 {
+	var ids []int64
+	for _, ep := range eps {
+		ids = append(ids, ep.ID)
+	}
+	return ids
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ----------------------------------------------------------------------------
+// Entities
+// ----------------------------------------------------------------------------
+
+func testLibraries(t *testing.T, s store.Store) {
+	lib := library(t, s, "/media/movies", domain.LibraryTypeMovies)
+	if lib.ID == 0 {
+		t.Fatal("CreateLibrary did not set ID")
+	}
+	if lib.CreatedAt.IsZero() || lib.UpdatedAt.IsZero() {
+		t.Error("CreateLibrary did not set timestamps")
+	}
+
+	got, err := s.GetLibrary(lib.ID)
+	must(t, err)
+	if got.Name != lib.Name || got.Type != lib.Type || got.Path != lib.Path {
+		t.Errorf("GetLibrary = %+v, want %+v", got, lib)
+	}
+
+	if err := s.CreateLibrary(&domain.Library{Name: "dup", Type: domain.LibraryTypeMovies, Path: lib.Path}); err == nil {
+		t.Error("CreateLibrary accepted a duplicate path")
+	}
+
+	if _, err := s.GetLibrary(lib.ID + 1000); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetLibrary(missing) err = %v, want sql.ErrNoRows", err)
+	}
+
+	lib.Name = "Films"
+	lib.Path = "/media/films"
+	must(t, s.UpdateLibrary(lib))
+	got, err = s.GetLibrary(lib.ID)
+	must(t, err)
+	if got.Name != "Films" || got.Path != "/media/films" {
+		t.Errorf("after UpdateLibrary got %+v", got)
+	}
+
+	if err := s.UpdateLibrary(&domain.Library{ID: lib.ID + 1000, Name: "x", Path: "/x"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("UpdateLibrary(missing) err = %v, want sql.ErrNoRows", err)
+	}
+	if err := s.UpdateLibrary(&domain.Library{Name: "x", Path: "/x"}); err == nil {
+		t.Error("UpdateLibrary accepted a library without ID")
+	}
+
+	second := library(t, s, "/media/shows", domain.LibraryTypeSeries)
+	libs, err := s.ListLibraries()
+	must(t, err)
+	if len(libs) != 2 || libs[0].ID != lib.ID || libs[1].ID != second.ID {
+		t.Errorf("ListLibraries = %+v", libs)
+	}
+}
+
+func testMovies(t *testing.T, s store.Store) {
+	lib := library(t, s, "/media/movies", domain.LibraryTypeMovies)
+	other := library(t, s, "/media/other", domain.LibraryTypeMovies)
+
+	b := &domain.Movie{LibraryID: lib.ID, Title: "Brazil", Year: 1985}
+	a := &domain.Movie{LibraryID: lib.ID, Title: "Alien", Year: 1979}
+	must(t, s.CreateMovie(b))
+	must(t, s.CreateMovie(a))
+	must(t, s.CreateMovie(&domain.Movie{LibraryID: other.ID, Title: "Alien", Year: 1979}))
+
+	if err := s.CreateMovie(&domain.Movie{LibraryID: lib.ID, Title: "Alien", Year: 1979}); err == nil {
+		t.Error("CreateMovie accepted a duplicate title and year")
+	}
+	must(t, s.CreateMovie(&domain.Movie{LibraryID: lib.ID, Title: "Alien", Year: 2030}))
+
+	if err := s.CreateMovie(&domain.Movie{LibraryID: lib.ID + other.ID + 1000, Title: "Orphan"}); err == nil {
+		t.Error("CreateMovie accepted an unknown library")
+	}
+
+	got, err := s.GetMovieByTitleAndYear("Alien", 1979, lib.ID)
+	must(t, err)
+	if got == nil || got.ID != a.ID {
+		t.Fatalf("GetMovieByTitleAndYear = %+v, want ID %d", got, a.ID)
+	}
+	got, err = s.GetMovieByTitleAndYear("Alien", 1980, lib.ID)
+	if got != nil || err != nil {
+		t.Errorf("GetMovieByTitleAndYear(missing) = %v, %v, want nil, nil", got, err)
+	}
+	if _, err := s.GetMovie(a.ID + 1000); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetMovie(missing) err = %v, want sql.ErrNoRows", err)
+	}
+
+	movies, err := s.ListMoviesByLibrary(lib.ID)
+	must(t, err)
+	if len(movies) != 3 || movies[0].Title != "Alien" || movies[2].Title != "Brazil" {
+		t.Errorf("ListMoviesByLibrary = %+v, want 3 movies ordered by title", movies)
+	}
+
+	// Only metadata columns change; title and year are the identity.
+	a.Title = "Renamed"
+	a.OriginalTitle = "Alien (Original)"
+	a.TMDBID = ptr("348")
+	a.Overview = "In space."
+	a.RuntimeMin = 117
+	a.PosterPath = ptr("posters/348.jpg")
+	must(t, s.UpdateMovie(a))
+
+	got, err = s.GetMovie(a.ID)
+	must(t, err)
+	if got.Title != "Alien" {
+		t.Errorf("UpdateMovie changed title to %q", got.Title)
+	}
+	if got.OriginalTitle != a.OriginalTitle || got.TMDBID == nil || *got.TMDBID != "348" ||
+		got.Overview != a.Overview || got.RuntimeMin != 117 ||
+		got.PosterPath == nil || *got.PosterPath != *a.PosterPath {
+		t.Errorf("after UpdateMovie got %+v", got)
+	}
+}
+
+func testSeries(t *testing.T, s store.Store) {
+	lib := library(t, s, "/media/shows", domain.LibraryTypeSeries)
+	other := library(t, s, "/media/other", domain.LibraryTypeSeries)
+
+	old := &domain.Series{LibraryID: lib.ID, Title: "Doctor Who", Year: 1963, TMDBID: ptr("121"), TVDBID: ptr("76107")}
+	remake := &domain.Series{LibraryID: lib.ID, Title: "Doctor Who", Year: 2005, TMDBID: ptr("57243")}
+	must(t, s.CreateSeries(remake))
+	must(t, s.CreateSeries(old))
+	series(t, s, other.ID, "Doctor Who", 1963)
+	series(t, s, lib.ID, "Blackadder", 1983)
+
+	if err := s.CreateSeries(&domain.Series{LibraryID: lib.ID, Title: "Doctor Who", Year: 2005}); err == nil {
+		t.Error("CreateSeries accepted a duplicate title and year")
+	}
+
+	got, err := s.GetSeriesByTitleAndYear("Doctor Who", 2005, lib.ID)
+	must(t, err)
+	if got == nil || got.ID != remake.ID {
+		t.Errorf("GetSeriesByTitleAndYear = %+v, want ID %d", got, remake.ID)
+	}
+	got, err = s.GetSeriesByTMDBID("121", lib.ID)
+	must(t, err)
+	if got == nil || got.ID != old.ID {
+		t.Errorf("GetSeriesByTMDBID = %+v, want ID %d", got, old.ID)
+	}
+	got, err = s.GetSeriesByTVDBID("76107", lib.ID)
+	must(t, err)
+	if got == nil || got.ID != old.ID {
+		t.Errorf("GetSeriesByTVDBID = %+v, want ID %d", got, old.ID)
+	}
+
+	for name, lookup := range map[string]func() (*domain.Series, error){
+		"GetSeriesByTitleAndYear": func() (*domain.Series, error) { return s.GetSeriesByTitleAndYear("Doctor Who", 1999, lib.ID) },
+		"GetSeriesByTMDBID":       func() (*domain.Series, error) { return s.GetSeriesByTMDBID("121", lib.ID+other.ID+1000) },
+		"GetSeriesByTVDBID":       func() (*domain.Series, error) { return s.GetSeriesByTVDBID("0", lib.ID) },
+	} {
+		if got, err := lookup(); got != nil || err != nil {
+			t.Errorf("%s(missing) = %v, %v, want nil, nil", name, got, err)
+		}
+	}
+	if _, err := s.GetSeries(old.ID + 1000); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetSeries(missing) err = %v, want sql.ErrNoRows", err)
+	}
+
+	all, err := s.ListSeries()
+	must(t, err)
+	if len(all) != 4 {
+		t.Fatalf("ListSeries returned %d series, want 4", len(all))
+	}
+	if all[0].Title != "Blackadder" || all[1].Year != 1963 || all[3].Year != 2005 {
+		t.Errorf("ListSeries not ordered by title, year: %+v %+v %+v %+v", all[0], all[1], all[2], all[3])
+	}
+
+	old.Title = "Renamed"
+	old.Status = "ended"
+	old.Overview = "A time traveller."
+	old.TVDBID = nil
+	must(t, s.UpdateSeries(old))
+	got, err = s.GetSeries(old.ID)
+	must(t, err)
+	if got.Title != "Doctor Who" || got.Status != "ended" || got.Overview != old.Overview || got.TVDBID != nil {
+		t.Errorf("after UpdateSeries got %+v", got)
+	}
+}
+
+func testSeasonsAndEpisodes(t *testing.T, s store.Store) {
+	lib := library(t, s, "/media/shows", domain.LibraryTypeSeries)
+	sr := series(t, s, lib.ID, "Show", 2020)
+
+	s2 := season(t, s, sr.ID, 2)
+	s1 := season(t, s, sr.ID, 1)
+	if err := s.CreateSeason(&domain.Season{SeriesID: sr.ID, Number: 1}); err == nil {
+		t.Error("CreateSeason accepted a duplicate number")
+	}
+	if err := s.CreateSeason(&domain.Season{SeriesID: sr.ID + 1000, Number: 1}); err == nil {
+		t.Error("CreateSeason accepted an unknown series")
+	}
+
+	seasons, err := s.ListSeasonsBySeries(sr.ID)
+	must(t, err)
+	if len(seasons) != 2 || seasons[0].ID != s1.ID || seasons[1].ID != s2.ID {
+		t.Errorf("ListSeasonsBySeries = %+v, want ordered by number", seasons)
+	}
+
+	got, err := s.GetSeasonBySeriesAndNumber(sr.ID, 2)
+	must(t, err)
+	if got == nil || got.ID != s2.ID {
+		t.Errorf("GetSeasonBySeriesAndNumber = %+v, want ID %d", got, s2.ID)
+	}
+	if got, err := s.GetSeasonBySeriesAndNumber(sr.ID, 9); got != nil || err != nil {
+		t.Errorf("GetSeasonBySeriesAndNumber(missing) = %v, %v, want nil, nil", got, err)
+	}
+	if _, err := s.GetSeason(s2.ID + 1000); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetSeason(missing) err = %v, want sql.ErrNoRows", err)
+	}
+
+	air := now()
+	s1.Title = "Season One"
+	s1.AirDate = &air
+	s1.TMDBID = ptr("s1")
+	must(t, s.UpdateSeason(s1))
+	got, err = s.GetSeason(s1.ID)
+	must(t, err)
+	if got.Title != "Season One" || got.AirDate == nil || !got.AirDate.Equal(air) || got.TMDBID == nil {
+		t.Errorf("after UpdateSeason got %+v", got)
+	}
+
+	e3 := episode(t, s, s1.ID, 3)
+	e1 := episode(t, s, s1.ID, 1)
+	episode(t, s, s2.ID, 1)
+	if err := s.CreateEpisode(&domain.Episode{SeasonID: s1.ID, Number: 3}); err == nil {
+		t.Error("CreateEpisode accepted a duplicate number")
+	}
+
+	eps, err := s.ListEpisodesBySeason(s1.ID)
+	must(t, err)
+	if !equalIDs(episodeIDs(eps), []int64{e1.ID, e3.ID}) {
+		t.Errorf("ListEpisodesBySeason = %v, want [%d %d]", episodeIDs(eps), e1.ID, e3.ID)
+	}
+
+	gotEp, err := s.GetEpisodeBySeasonAndNumber(s1.ID, 3)
+	must(t, err)
+	if gotEp == nil || gotEp.ID != e3.ID {
+		t.Errorf("GetEpisodeBySeasonAndNumber = %+v, want ID %d", gotEp, e3.ID)
+	}
+	if gotEp, err := s.GetEpisodeBySeasonAndNumber(s1.ID, 2); gotEp != nil || err != nil {
+		t.Errorf("GetEpisodeBySeasonAndNumber(missing) = %v, %v, want nil, nil", gotEp, err)
+	}
+	if _, err := s.GetEpisode(e3.ID + 1000); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetEpisode(missing) err = %v, want sql.ErrNoRows", err)
+	}
+
+	e1.Title = "Pilot"
+	e1.TitleSource = domain.TitleSourceManual
+	e1.RuntimeMin = 42
+	must(t, s.UpdateEpisode(e1))
+	gotEp, err = s.GetEpisode(e1.ID)
+	must(t, err)
+	if gotEp.Title != "Pilot" || gotEp.TitleSource != domain.TitleSourceManual || gotEp.RuntimeMin != 42 || gotEp.Number != 1 {
+		t.Errorf("after UpdateEpisode got %+v", gotEp)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Media files
+// ----------------------------------------------------------------------------
+
+func testMediaFiles(t *testing.T, s store.Store) {
+	lib := library(t, s, "/media/movies", domain.LibraryTypeMovies)
+	movie := &domain.Movie{LibraryID: lib.ID, Title: "Alien", Year: 1979}
+	must(t, s.CreateMovie(movie))
+
+	seen := now()
+	b := &domain.MediaFile{LibraryID: lib.ID, MovieID: &movie.ID, Path: "/media/movies/b.mkv", SizeBytes: 2, LastSeenAt: &seen}
+	a := &domain.MediaFile{LibraryID: lib.ID, MovieID: &movie.ID, Path: "/media/movies/a.mkv", SizeBytes: 1, LastSeenAt: &seen}
+	must(t, s.CreateMediaFile(b))
+	must(t, s.CreateMediaFile(a))
+
+	got, err := s.GetMediaFile(a.ID)
+	must(t, err)
+	if got.Path != a.Path || got.SizeBytes != 1 || got.MovieID == nil || *got.MovieID != movie.ID {
+		t.Errorf("GetMediaFile = %+v", got)
+	}
+	if got.ProbeStatus != domain.ProbeStatusOK {
+		t.Errorf("ProbeStatus defaulted to %q, want %q", got.ProbeStatus, domain.ProbeStatusOK)
+	}
+	if got.LastSeenAt == nil || !got.LastSeenAt.Equal(seen) {
+		t.Errorf("LastSeenAt = %v, want %v", got.LastSeenAt, seen)
+	}
+
+	got, err = s.GetMediaFileByPath(b.Path)
+	must(t, err)
+	if got == nil || got.ID != b.ID {
+		t.Errorf("GetMediaFileByPath = %+v, want ID %d", got, b.ID)
+	}
+	if got, err := s.GetMediaFileByPath("/nope.mkv"); got != nil || err != nil {
+		t.Errorf("GetMediaFileByPath(missing) = %v, %v, want nil, nil", got, err)
+	}
+	if _, err := s.GetMediaFile(a.ID + 1000); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetMediaFile(missing) err = %v, want sql.ErrNoRows", err)
+	}
+
+	files, err := s.ListMediaFilesByMovie(movie.ID)
+	must(t, err)
+	if len(files) != 2 || files[0].ID != b.ID || files[1].ID != a.ID {
+		t.Errorf("ListMediaFilesByMovie = %+v, want ordered by ID", files)
+	}
+
+	// Path and library are identity; everything else follows the struct.
+	a.Path = "/elsewhere.mkv"
+	a.SizeBytes = 10
+	a.VideoCodec = "hevc"
+	a.MovieID = nil
+	must(t, s.UpdateMediaFile(a))
+	got, err = s.GetMediaFile(a.ID)
+	must(t, err)
+	if got.Path != "/media/movies/a.mkv" || got.SizeBytes != 10 || got.VideoCodec != "hevc" || got.MovieID != nil {
+		t.Errorf("after UpdateMediaFile got %+v", got)
+	}
+}
+
+func testMediaFileConstraints(t *testing.T, s store.Store) {
+	lib := library(t, s, "/media/movies", domain.LibraryTypeMovies)
+
+	must(t, s.CreateMediaFile(&domain.MediaFile{LibraryID: lib.ID, Path: "/a.mkv"}))
+	if err := s.CreateMediaFile(&domain.MediaFile{LibraryID: lib.ID, Path: "/a.mkv"}); err == nil {
+		t.Error("CreateMediaFile accepted a duplicate path")
+	}
+	if err := s.CreateMediaFile(&domain.MediaFile{LibraryID: lib.ID + 1000, Path: "/b.mkv"}); err == nil {
+		t.Error("CreateMediaFile accepted an unknown library")
+	}
+	if err := s.CreateMediaFile(&domain.MediaFile{LibraryID: lib.ID, Path: "/c.mkv", MovieID: ptr(int64(1000))}); err == nil {
+		t.Error("CreateMediaFile accepted an unknown movie")
+	}
+
+	// is_missing and missing_since must agree.
+	if err := s.CreateMediaFile(&domain.MediaFile{LibraryID: lib.ID, Path: "/d.mkv", IsMissing: true}); err == nil {
+		t.Error("CreateMediaFile accepted IsMissing without MissingSince")
+	}
+	since := now()
+	if err := s.CreateMediaFile(&domain.MediaFile{LibraryID: lib.ID, Path: "/e.mkv", MissingSince: &since}); err == nil {
+		t.Error("CreateMediaFile accepted MissingSince without IsMissing")
+	}
+	must(t, s.CreateMediaFile(&domain.MediaFile{LibraryID: lib.ID, Path: "/f.mkv", IsMissing: true, MissingSince: &since}))
+}
+
+func testProbeErrors(t *testing.T, s store.Store) {
+	lib := library(t, s, "/media/movies", domain.LibraryTypeMovies)
+	other := library(t, s, "/media/other", domain.LibraryTypeMovies)
+	since := now()
+
+	for _, mf := range []*domain.MediaFile{
+		{LibraryID: lib.ID, Path: "/z.mkv", ProbeStatus: domain.ProbeStatusError, ProbeError: "bad"},
+		{LibraryID: lib.ID, Path: "/a.mkv", ProbeStatus: domain.ProbeStatusError, ProbeError: "bad"},
+		{LibraryID: lib.ID, Path: "/ok.mkv"},
+		{LibraryID: lib.ID, Path: "/gone.mkv", ProbeStatus: domain.ProbeStatusError, IsMissing: true, MissingSince: &since},
+		{LibraryID: other.ID, Path: "/other.mkv", ProbeStatus: domain.ProbeStatusError},
+	} {
+		must(t, s.CreateMediaFile(mf))
+	}
+
+	files, err := s.ListMediaFilesWithProbeErrors(lib.ID)
+	must(t, err)
+	if len(files) != 2 || files[0].Path != "/a.mkv" || files[1].Path != "/z.mkv" {
+		t.Errorf("ListMediaFilesWithProbeErrors = %+v, want /a.mkv and /z.mkv", files)
+	}
+	if files[0].ProbeError != "bad" {
+		t.Errorf("ProbeError = %q", files[0].ProbeError)
+	}
+}
+
+func testMarkMissingMediaFiles(t *testing.T, s store.Store) {
+	lib := library(t, s, "/media/movies", domain.LibraryTypeMovies)
+	other := library(t, s, "/media/other", domain.LibraryTypeMovies)
+
+	scanStart := now()
+	before := scanStart.Add(-time.Hour)
+
+	stale := &domain.MediaFile{LibraryID: lib.ID, Path: "/stale.mkv", LastSeenAt: &before}
+	never := &domain.MediaFile{LibraryID: lib.ID, Path: "/never.mkv"}
+	fresh := &domain.MediaFile{LibraryID: lib.ID, Path: "/fresh.mkv", LastSeenAt: &scanStart}
+	elsewhere := &domain.MediaFile{LibraryID: other.ID, Path: "/elsewhere.mkv", LastSeenAt: &before}
+	for _, mf := range []*domain.MediaFile{stale, never, fresh, elsewhere} {
+		must(t, s.CreateMediaFile(mf))
+	}
+
+	n, err := s.MarkMissingMediaFiles(lib.ID, scanStart)
+	must(t, err)
+	if n != 2 {
+		t.Errorf("MarkMissingMediaFiles = %d, want 2", n)
+	}
+
+	for _, tc := range []struct {
+		mf      *domain.MediaFile
+		missing bool
+	}{{stale, true}, {never, true}, {fresh, false}, {elsewhere, false}} {
+		got, err := s.GetMediaFile(tc.mf.ID)
+		must(t, err)
+		if got.IsMissing != tc.missing {
+			t.Errorf("%s: IsMissing = %v, want %v", got.Path, got.IsMissing, tc.missing)
+		}
+		if tc.missing && (got.MissingSince == nil || !got.MissingSince.Equal(scanStart)) {
+			t.Errorf("%s: MissingSince = %v, want %v", got.Path, got.MissingSince, scanStart)
+		}
+	}
+
+	// A later scan only marks the file it has not seen since, and keeps the
+	// first missing_since of those already missing.
+	n, err = s.MarkMissingMediaFiles(lib.ID, scanStart.Add(time.Hour))
+	must(t, err)
+	if n != 1 {
+		t.Errorf("second MarkMissingMediaFiles = %d, want 1", n)
+	}
+	got, err := s.GetMediaFile(stale.ID)
+	must(t, err)
+	if got.MissingSince == nil || !got.MissingSince.Equal(scanStart) {
+		t.Errorf("MissingSince moved to %v, want %v", got.MissingSince, scanStart)
+	}
+
+	// Seeing the file again clears both columns.
+	seen := scanStart.Add(2 * time.Hour)
+	must(t, s.MarkMediaFileSeen(stale.ID, seen))
+	got, err = s.GetMediaFile(stale.ID)
+	must(t, err)
+	if got.IsMissing || got.MissingSince != nil || got.LastSeenAt == nil || !got.LastSeenAt.Equal(seen) {
+		t.Errorf("after MarkMediaFileSeen got missing=%v since=%v seen=%v", got.IsMissing, got.MissingSince, got.LastSeenAt)
+	}
+
+	// So does a full update.
+	got, err = s.GetMediaFile(never.ID)
+	must(t, err)
+	got.LastSeenAt = &seen
+	must(t, s.UpdateMediaFile(got))
+	got, err = s.GetMediaFile(never.ID)
+	must(t, err)
+	if got.IsMissing || got.MissingSince != nil {
+		t.Errorf("after UpdateMediaFile got missing=%v since=%v", got.IsMissing, got.MissingSince)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Episode links and clean-up
+// ----------------------------------------------------------------------------
+
+func testMultiEpisodeLinks(t *testing.T, s store.Store) {
+	lib := library(t, s, "/media/shows", domain.LibraryTypeSeries)
+	sn := season(t, s, series(t, s, lib.ID, "Show", 2020).ID, 1)
+	e2 := episode(t, s, sn.ID, 2)
+	e1 := episode(t, s, sn.ID, 1)
+	e3 := episode(t, s, sn.ID, 3)
+
+	double := episodeFile(t, s, lib.ID, "/Show/S01E01-E02.mkv", now(), e1, e2)
+	single := episodeFile(t, s, lib.ID, "/Show/S01E03.mkv", now(), e3)
+
+	// Linking twice is a no-op.
+	must(t, s.CreateMediaFileEpisode(&domain.MediaFileEpisode{MediaFileID: double.ID, EpisodeID: e2.ID}))
+
+	if err := s.CreateMediaFileEpisode(&domain.MediaFileEpisode{MediaFileID: double.ID, EpisodeID: e3.ID + 1000}); err == nil {
+		t.Error("CreateMediaFileEpisode accepted an unknown episode")
+	}
+
+	eps, err := s.ListEpisodesByMediaFile(double.ID)
+	must(t, err)
+	if !equalIDs(episodeIDs(eps), []int64{e1.ID, e2.ID}) {
+		t.Errorf("ListEpisodesByMediaFile = %v, want [%d %d]", episodeIDs(eps), e1.ID, e2.ID)
+	}
+	eps, err = s.ListEpisodesByMediaFile(single.ID)
+	must(t, err)
+	if !equalIDs(episodeIDs(eps), []int64{e3.ID}) {
+		t.Errorf("ListEpisodesByMediaFile = %v, want [%d]", episodeIDs(eps), e3.ID)
+	}
+
+	files, err := s.ListMediaFilesByEpisode(e1.ID)
+	must(t, err)
+	if len(files) != 1 || files[0].ID != double.ID {
+		t.Errorf("ListMediaFilesByEpisode = %+v, want the range file", files)
+	}
+
+	// Every linked episode has a present file, so nothing is cleaned up.
+	n, err := s.CleanupEmptyEpisodes(lib.ID)
+	must(t, err)
+	if n != 0 {
+		t.Errorf("CleanupEmptyEpisodes = %d, want 0", n)
+	}
+}
+
+func testCleanupCascade(t *testing.T, s store.Store) {
+	lib := library(t, s, "/media/shows", domain.LibraryTypeSeries)
+	other := library(t, s, "/media/other", domain.LibraryTypeSeries)
+
+	sr := series(t, s, lib.ID, "Show", 2020)
+	kept := season(t, s, sr.ID, 1)
+	emptied := season(t, s, sr.ID, 2)
+	keptEp := episode(t, s, kept.ID, 1)
+	goneEp := episode(t, s, kept.ID, 2)
+	lastEp := episode(t, s, emptied.ID, 1)
+	noFileEp := episode(t, s, emptied.ID, 2)
+
+	bare := series(t, s, lib.ID, "Bare", 2021)
+
+	// Another library's orphans are never touched.
+	otherSeries := series(t, s, other.ID, "Other", 2020)
+	otherEp := episode(t, s, season(t, s, otherSeries.ID, 1).ID, 1)
+
+	scanStart := now()
+	episodeFile(t, s, lib.ID, "/Show/S01E01.mkv", scanStart, keptEp)
+	gone := episodeFile(t, s, lib.ID, "/Show/S01E02.mkv", scanStart.Add(-time.Hour), goneEp)
+	episodeFile(t, s, lib.ID, "/Show/S02E01.mkv", scanStart.Add(-time.Hour), lastEp)
+
+	n, err := s.MarkMissingMediaFiles(lib.ID, scanStart)
+	must(t, err)
+	if n != 2 {
+		t.Fatalf("MarkMissingMediaFiles = %d, want 2", n)
+	}
+
+	// A missing file does not keep its episodes alive.
+	n, err = s.CleanupEmptyEpisodes(lib.ID)
+	must(t, err)
+	if n != 3 {
+		t.Errorf("CleanupEmptyEpisodes = %d, want 3", n)
+	}
+	for _, ep := range []*domain.Episode{goneEp, lastEp, noFileEp} {
+		if _, err := s.GetEpisode(ep.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("episode %d survived CleanupEmptyEpisodes (err %v)", ep.Number, err)
+		}
+	}
+	if _, err := s.GetEpisode(keptEp.ID); err != nil {
+		t.Errorf("episode with a present file was removed: %v", err)
+	}
+	if _, err := s.GetEpisode(otherEp.ID); err != nil {
+		t.Errorf("other library's episode was removed: %v", err)
+	}
+
+	// The file's direct episode link is nulled, not the file deleted.
+	got, err := s.GetMediaFile(gone.ID)
+	must(t, err)
+	if got.EpisodeID != nil {
+		t.Errorf("EpisodeID = %d after its episode was deleted, want nil", *got.EpisodeID)
+	}
+	eps, err := s.ListEpisodesByMediaFile(gone.ID)
+	must(t, err)
+	if len(eps) != 0 {
+		t.Errorf("ListEpisodesByMediaFile = %v after its episode was deleted", episodeIDs(eps))
+	}
+
+	n, err = s.CleanupEmptySeasons(lib.ID)
+	must(t, err)
+	if n != 1 {
+		t.Errorf("CleanupEmptySeasons = %d, want 1", n)
+	}
+	if _, err := s.GetSeason(emptied.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("empty season survived (err %v)", err)
+	}
+	if _, err := s.GetSeason(kept.ID); err != nil {
+		t.Errorf("season with episodes was removed: %v", err)
+	}
+
+	n, err = s.CleanupEmptySeries(lib.ID)
+	must(t, err)
+	if n != 1 {
+		t.Errorf("CleanupEmptySeries = %d, want 1", n)
+	}
+	if _, err := s.GetSeries(bare.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("empty series survived (err %v)", err)
+	}
+	if _, err := s.GetSeries(sr.ID); err != nil {
+		t.Errorf("series with seasons was removed: %v", err)
+	}
+	if _, err := s.GetSeries(otherSeries.ID); err != nil {
+		t.Errorf("other library's series was removed: %v", err)
+	}
+}
+
+func testUnlinkMissingMediaFiles(t *testing.T, s store.Store) {
+	lib := library(t, s, "/media/shows", domain.LibraryTypeSeries)
+	sn := season(t, s, series(t, s, lib.ID, "Show", 2020).ID, 1)
+	e1 := episode(t, s, sn.ID, 1)
+	e2 := episode(t, s, sn.ID, 2)
+
+	scanStart := now()
+	gone := episodeFile(t, s, lib.ID, "/Show/S01E01-E02.mkv", scanStart.Add(-time.Hour), e1, e2)
+	present := episodeFile(t, s, lib.ID, "/Show/S01E01.mkv", scanStart, e1)
+
+	_, err := s.MarkMissingMediaFiles(lib.ID, scanStart)
+	must(t, err)
+
+	n, err := s.UnlinkMissingMediaFiles(lib.ID)
+	must(t, err)
+	if n != 1 {
+		t.Errorf("UnlinkMissingMediaFiles = %d, want 1", n)
+	}
+
+	got, err := s.GetMediaFile(gone.ID)
+	must(t, err)
+	if got.EpisodeID != nil || got.MovieID != nil || !got.IsMissing {
+		t.Errorf("missing file after unlink: %+v", got)
+	}
+	eps, err := s.ListEpisodesByMediaFile(gone.ID)
+	must(t, err)
+	if len(eps) != 0 {
+		t.Errorf("missing file still linked to %v", episodeIDs(eps))
+	}
+
+	eps, err = s.ListEpisodesByMediaFile(present.ID)
+	must(t, err)
+	if !equalIDs(episodeIDs(eps), []int64{e1.ID}) {
+		t.Errorf("present file links = %v, want [%d]", episodeIDs(eps), e1.ID)
+	}
+
+	// Nothing left to unlink.
+	n, err = s.UnlinkMissingMediaFiles(lib.ID)
+	must(t, err)
+	if n != 0 {
+		t.Errorf("second UnlinkMissingMediaFiles = %d, want 0", n)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Tracks
+// ----------------------------------------------------------------------------
+
+func testSubtitleTracks(t *testing.T, s store.Store) {
+	lib := library(t, s, "/media/movies", domain.LibraryTypeMovies)
+	mf := &domain.MediaFile{LibraryID: lib.ID, Path: "/a.mkv"}
+	must(t, s.CreateMediaFile(mf))
+
+	orig := &domain.SubtitleTrack{
+		MediaFileID: mf.ID, Source: domain.SubtitleSourceExternal, ExternalPath: ptr("/a.en.srt"),
+		Language: "en", Title: "SDH", IsSDH: true, Format: "srt",
+	}
+	must(t, s.CreateSubtitleTrack(orig))
+	derived := &domain.SubtitleTrack{
+		MediaFileID: mf.ID, Source: domain.SubtitleSourceSynced, ExternalPath: ptr("/cache/1.vtt"),
+		Language: "en", Format: "vtt", DerivedFromID: &orig.ID,
+	}
+	must(t, s.CreateSubtitleTrack(derived))
+	embedded := &domain.SubtitleTrack{
+		MediaFileID: mf.ID, Source: domain.SubtitleSourceEmbedded, StreamIndex: ptr(2),
+		Language: "de", IsForced: true, Format: "subrip",
+	}
+	must(t, s.CreateSubtitleTrack(embedded))
+
+	tracks, err := s.ListSubtitleTracks(mf.ID)
+	must(t, err)
+	if len(tracks) != 3 || tracks[0].ID != orig.ID || tracks[2].ID != embedded.ID {
+		t.Fatalf("ListSubtitleTracks = %+v, want ordered by ID", tracks)
+	}
+	if tracks[1].DerivedFromID == nil || *tracks[1].DerivedFromID != orig.ID {
+		t.Errorf("DerivedFromID = %v, want %d", tracks[1].DerivedFromID, orig.ID)
+	}
+
+	if got, err := s.GetSubtitleTrack(embedded.ID + 1000); got != nil || err != nil {
+		t.Errorf("GetSubtitleTrack(missing) = %v, %v, want nil, nil", got, err)
+	}
+
+	// Rescans go through UpdateSubtitleTrack and must keep the user's offset.
+	must(t, s.SetSubtitleTrackOffset(orig.ID, -1500))
+	orig.Title = "English SDH"
+	orig.OffsetMs = 0
+	must(t, s.UpdateSubtitleTrack(orig))
+	got, err := s.GetSubtitleTrack(orig.ID)
+	must(t, err)
+	if got.Title != "English SDH" || got.OffsetMs != -1500 || !got.IsSDH {
+		t.Errorf("after UpdateSubtitleTrack got %+v", got)
+	}
+
+	// Deleting the original drops its synced copy too.
+	must(t, s.DeleteSubtitleTrack(orig.ID))
+	tracks, err = s.ListSubtitleTracks(mf.ID)
+	must(t, err)
+	if len(tracks) != 1 || tracks[0].ID != embedded.ID {
+		t.Errorf("after DeleteSubtitleTrack got %+v, want only the embedded track", tracks)
+	}
+}
+
+func testStreamsAndChapters(t *testing.T, s store.Store) {
+	lib := library(t, s, "/media/movies", domain.LibraryTypeMovies)
+	mf := &domain.MediaFile{LibraryID: lib.ID, Path: "/a.mkv"}
+	other := &domain.MediaFile{LibraryID: lib.ID, Path: "/b.mkv"}
+	must(t, s.CreateMediaFile(mf))
+	must(t, s.CreateMediaFile(other))
+
+	a2 := &domain.AudioTrack{MediaFileID: mf.ID, StreamIndex: 2, Language: "en", Title: "Commentary", IsCommentary: true}
+	a1 := &domain.AudioTrack{MediaFileID: mf.ID, StreamIndex: 1, Language: "en", Codec: "eac3", Channels: 6, IsDefault: true}
+	must(t, s.CreateAudioTrack(a2))
+	must(t, s.CreateAudioTrack(a1))
+	if err := s.CreateAudioTrack(&domain.AudioTrack{MediaFileID: mf.ID, StreamIndex: 1}); err == nil {
+		t.Error("CreateAudioTrack accepted a duplicate stream index")
+	}
+
+	audio, err := s.ListAudioTracks(mf.ID)
+	must(t, err)
+	if len(audio) != 2 || audio[0].ID != a1.ID || !audio[1].IsCommentary || audio[1].Title != "Commentary" {
+		t.Errorf("ListAudioTracks = %+v, want ordered by stream index", audio)
+	}
+
+	a1.Channels = 8
+	a1.IsOriginal = true
+	must(t, s.UpdateAudioTrack(a1))
+	must(t, s.DeleteAudioTrack(a2.ID))
+	audio, err = s.ListAudioTracks(mf.ID)
+	must(t, err)
+	if len(audio) != 1 || audio[0].Channels != 8 || !audio[0].IsOriginal {
+		t.Errorf("after update and delete got %+v", audio)
+	}
+
+	for _, vs := range []*domain.VideoStream{
+		{MediaFileID: mf.ID, StreamIndex: 1, Codec: "h264"},
+		{MediaFileID: mf.ID, StreamIndex: 0, Codec: "hevc", Width: 3840, Height: 2160, HDRFormats: "HDR10"},
+		{MediaFileID: other.ID, StreamIndex: 0, Codec: "av1"},
+	} {
+		must(t, s.CreateVideoStream(vs))
+	}
+	if err := s.CreateVideoStream(&domain.VideoStream{MediaFileID: mf.ID, StreamIndex: 0}); err == nil {
+		t.Error("CreateVideoStream accepted a duplicate stream index")
+	}
+	video, err := s.ListVideoStreams(mf.ID)
+	must(t, err)
+	if len(video) != 2 || video[0].Codec != "hevc" || video[0].HDRFormats != "HDR10" || video[1].Codec != "h264" {
+		t.Errorf("ListVideoStreams = %+v, want ordered by stream index", video)
+	}
+
+	for _, ch := range []*domain.Chapter{
+		{MediaFileID: mf.ID, Index: 1, StartMs: 60000, EndMs: 120000, Title: "Two"},
+		{MediaFileID: mf.ID, Index: 0, StartMs: 0, EndMs: 60000, Title: "One"},
+		{MediaFileID: other.ID, Index: 0, StartMs: 0, EndMs: 1000},
+	} {
+		must(t, s.CreateChapter(ch))
+	}
+	if err := s.CreateChapter(&domain.Chapter{MediaFileID: mf.ID, Index: 1}); err == nil {
+		t.Error("CreateChapter accepted a duplicate index")
+	}
+	chapters, err := s.ListChapters(mf.ID)
+	must(t, err)
+	if len(chapters) != 2 || chapters[0].Title != "One" || chapters[1].StartMs != 60000 {
+		t.Errorf("ListChapters = %+v, want ordered by index", chapters)
+	}
+
+	// Deletes are per file.
+	must(t, s.DeleteVideoStreams(mf.ID))
+	must(t, s.DeleteChapters(mf.ID))
+	if video, _ := s.ListVideoStreams(mf.ID); len(video) != 0 {
+		t.Errorf("DeleteVideoStreams left %d streams", len(video))
+	}
+	if chapters, _ := s.ListChapters(mf.ID); len(chapters) != 0 {
+		t.Errorf("DeleteChapters left %d chapters", len(chapters))
+	}
+	if video, _ := s.ListVideoStreams(other.ID); len(video) != 1 {
+		t.Errorf("DeleteVideoStreams touched another file: %d streams left", len(video))
+	}
+	if chapters, _ := s.ListChapters(other.ID); len(chapters) != 1 {
+		t.Errorf("DeleteChapters touched another file: %d chapters left", len(chapters))
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Transactions
+// ----------------------------------------------------------------------------
+
+func testWithTxCommit(t *testing.T, s store.Store) {
+	var lib *domain.Library
+	err := s.WithTx(func(tx store.Store) error {
+		lib = &domain.Library{Name: "M", Type: domain.LibraryTypeMovies, Path: "/m"}
+		if err := tx.CreateLibrary(lib); err != nil {
+			return err
+		}
+		// Reads inside the transaction see its own writes.
+		if _, err := tx.GetLibrary(lib.ID); err != nil {
+			return err
+		}
+		if err := tx.CreateMovie(&domain.Movie{LibraryID: lib.ID, Title: "Alien", Year: 1979}); err != nil {
+			return err
+		}
+
+		// What a scan does per file, a few files per transaction.
+		for _, path := range []string{"/m/a.mkv", "/m/b.mkv", "/m/c.mkv"} {
+			if err := tx.WithTx(func(step store.Store) error {
+				if mf, err := step.GetMediaFileByPath(path); err != nil || mf != nil {
+					return fmt.Errorf("GetMediaFileByPath(%s) = %v, %v before create", path, mf, err)
+				}
+				mf := &domain.MediaFile{LibraryID: lib.ID, Path: path}
+				if err := step.CreateMediaFile(mf); err != nil {
+					return err
+				}
+				if err := step.CreateAudioTrack(&domain.AudioTrack{MediaFileID: mf.ID, StreamIndex: 1}); err != nil {
+					return err
+				}
+				if err := step.CreateSubtitleTrack(&domain.SubtitleTrack{MediaFileID: mf.ID, Source: domain.SubtitleSourceEmbedded, StreamIndex: ptr(2)}); err != nil {
+					return err
+				}
+				got, err := step.GetMediaFileByPath(path)
+				if err == nil && (got == nil || got.ID != mf.ID) {
+					err = fmt.Errorf("GetMediaFileByPath(%s) = %+v after create", path, got)
+				}
+				return err
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	must(t, err)
+
+	movies, err := s.ListMoviesByLibrary(lib.ID)
+	must(t, err)
+	if len(movies) != 1 {
+		t.Errorf("committed transaction left %d movies, want 1", len(movies))
+	}
+	mf, err := s.GetMediaFileByPath("/m/c.mkv")
+	must(t, err)
+	if mf == nil {
+		t.Fatal("committed transaction lost its media files")
+	}
+	audio, err := s.ListAudioTracks(mf.ID)
+	must(t, err)
+	subs, err := s.ListSubtitleTracks(mf.ID)
+	must(t, err)
+	if len(audio) != 1 || len(subs) != 1 {
+		t.Errorf("committed transaction left %d audio and %d subtitle tracks, want 1 each", len(audio), len(subs))
+	}
+}
+
+func testWithTxRollback(t *testing.T, s store.Store) {
+	lib := library(t, s, "/media/movies", domain.LibraryTypeMovies)
+	seen := now().Add(-time.Hour)
+	mf := &domain.MediaFile{LibraryID: lib.ID, Path: "/a.mkv", LastSeenAt: &seen}
+	must(t, s.CreateMediaFile(mf))
+
+	boom := errors.New("boom")
+	err := s.WithTx(func(tx store.Store) error {
+		if err := tx.CreateLibrary(&domain.Library{Name: "X", Type: domain.LibraryTypeOther, Path: "/x"}); err != nil {
+			return err
+		}
+		if err := tx.CreateMovie(&domain.Movie{LibraryID: lib.ID, Title: "Alien", Year: 1979}); err != nil {
+			return err
+		}
+		if _, err := tx.MarkMissingMediaFiles(lib.ID, now()); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("WithTx err = %v, want the callback's error", err)
+	}
+
+	libs, err := s.ListLibraries()
+	must(t, err)
+	if len(libs) != 1 {
+		t.Errorf("rolled back transaction left %d libraries, want 1", len(libs))
+	}
+	movies, err := s.ListMoviesByLibrary(lib.ID)
+	must(t, err)
+	if len(movies) != 0 {
+		t.Errorf("rolled back transaction left %d movies", len(movies))
+	}
+	got, err := s.GetMediaFile(mf.ID)
+	must(t, err)
+	if got.IsMissing {
+		t.Error("rolled back transaction left the file marked missing")
+	}
+
+	// The store is still usable after a rollback.
+	library(t, s, "/media/after", domain.LibraryTypeOther)
+}
+
+// testReadsDuringTx checks that a scan's open transaction neither blocks
+// other readers nor shows them its uncommitted rows.
+func testReadsDuringTx(t *testing.T, s store.Store) {
+	lib := library(t, s, "/media/movies", domain.LibraryTypeMovies)
+
+	type result struct {
+		movies []domain.Movie
+		err    error
+	}
+
+	err := s.WithTx(func(tx store.Store) error {
+		if err := tx.CreateMovie(&domain.Movie{LibraryID: lib.ID, Title: "Alien", Year: 1979}); err != nil {
+			return err
+		}
+
+		done := make(chan result, 1)
+		go func() {
+			movies, err := s.ListMoviesByLibrary(lib.ID)
+			done <- result{movies, err}
+		}()
+
+		select {
+		case r := <-done:
+			if r.err != nil {
+				return r.err
+			}
+			if len(r.movies) != 0 {
+				t.Errorf("read during transaction saw %d uncommitted movies", len(r.movies))
+			}
+		case <-time.After(2 * time.Second):
+			t.Error("read blocked behind an open transaction")
+		}
+		return nil
+	})
+	must(t, err)
+}
+
+// testNestedWithTx checks that WithTx on a transaction's store acts as a
+// savepoint: a failing step is undone alone, even after a constraint
+// violation, and the outer transaction still commits.
+func testNestedWithTx(t *testing.T, s store.Store) {
+	lib := library(t, s, "/media/movies", domain.LibraryTypeMovies)
+	boom := errors.New("boom")
+
+	err := s.WithTx(func(tx store.Store) error {
+		if err := tx.WithTx(func(step store.Store) error {
+			return step.CreateMovie(&domain.Movie{LibraryID: lib.ID, Title: "Kept", Year: 2001})
+		}); err != nil {
+			return err
+		}
+
+		err := tx.WithTx(func(step store.Store) error {
+			if err := step.CreateMovie(&domain.Movie{LibraryID: lib.ID, Title: "Undone", Year: 2002}); err != nil {
+				return err
+			}
+			return boom
+		})
+		if !errors.Is(err, boom) {
+			t.Errorf("failing step err = %v, want the callback's error", err)
+		}
+
+		err = tx.WithTx(func(step store.Store) error {
+			if err := step.CreateMovie(&domain.Movie{LibraryID: lib.ID, Title: "Partial", Year: 2003}); err != nil {
+				return err
+			}
+			return step.CreateMovie(&domain.Movie{LibraryID: lib.ID, Title: "Kept", Year: 2001})
+		})
+		if err == nil {
+			t.Error("duplicate movie inside a step was accepted")
+		}
+
+		// The transaction is still usable after the failed steps.
+		return tx.CreateMovie(&domain.Movie{LibraryID: lib.ID, Title: "After", Year: 2004})
+	})
+	must(t, err)
+
+	movies, err := s.ListMoviesByLibrary(lib.ID)
+	must(t, err)
+	var titles []string
+	for _, m := range movies {
+		titles = append(titles, m.Title)
+	}
+	if fmt.Sprint(titles) != "[After Kept]" {
+		t.Errorf("movies after commit = %v, want [After Kept]", titles)
+	}
+}
+
+func testSearch(t *testing.T, s store.Store) {
+	movies := library(t, s, "/media/movies", domain.LibraryTypeMovies)
+	shows := library(t, s, "/media/shows", domain.LibraryTypeSeries)
+
+	amelie := &domain.Movie{LibraryID: movies.ID, Title: "Amélie", Year: 2001,
+		OriginalTitle: "Le Fabuleux Destin d'Amélie Poulain"}
+	must(t, s.CreateMovie(amelie))
+	heist := &domain.Movie{LibraryID: movies.ID, Title: "Heat", Year: 1995,
+		Overview: "A crew plans one last heist in Los Angeles."}
+	must(t, s.CreateMovie(heist))
+	crew := &domain.Movie{LibraryID: movies.ID, Title: "The Heist Crew", Year: 2010}
+	must(t, s.CreateMovie(crew))
+
+	sr := series(t, s, shows.ID, "Heist Stories", 2015)
+	se := season(t, s, sr.ID, 1)
+	ep := episode(t, s, se.ID, 3)
+	ep.Title = "The Café Job"
+	must(t, s.UpdateEpisode(ep))
+
+	search := func(q string, typ domain.SearchResultType, libraryID int64) []domain.SearchResult {
+		t.Helper()
+		got, err := s.Search(q, typ, libraryID, 50)
+		must(t, err)
+		return got
+	}
+	ids := func(rs []domain.SearchResult) []int64 {
+		var out []int64
+		for _, r := range rs {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+
+	// Prefixes match, case and diacritics are ignored both ways.
+	for _, q := range []string{"amel", "AMÉLIE", "poulain"} {
+		got := search(q, "", 0)
+		if len(got) != 1 || got[0].Type != domain.SearchResultMovie || got[0].ID != amelie.ID {
+			t.Errorf("Search(%q) = %+v, want Amélie", q, got)
+		}
+	}
+	if got := search("amelie", "", 0); len(got) != 1 || got[0].Title != "Amélie" || got[0].Year != 2001 {
+		t.Errorf("Search(amelie) = %+v, want the stored title and year", got)
+	}
+
+	// Episodes come back with their series and numbers.
+	got := search("cafe", "", 0)
+	if len(got) != 1 || got[0].Type != domain.SearchResultEpisode || got[0].ID != ep.ID {
+		t.Fatalf("Search(cafe) = %+v, want the episode", got)
+	}
+	if r := got[0]; r.SeriesID != sr.ID || r.SeriesTitle != "Heist Stories" ||
+		r.SeasonNumber != 1 || r.EpisodeNumber != 3 || r.LibraryID != shows.ID {
+		t.Errorf("episode hit = %+v", r)
+	}
+
+	// Title hits outrank overview hits.
+	got = search("heist", "", 0)
+	if len(got) != 3 || got[len(got)-1].ID != heist.ID {
+		t.Errorf("Search(heist) = %+v, want the overview-only Heat last", got)
+	}
+
+	// Filters.
+	if got := search("heist", domain.SearchResultSeries, 0); len(got) != 1 || got[0].ID != sr.ID {
+		t.Errorf("Search(heist, series) = %+v", got)
+	}
+	if got := ids(search("heist", "", movies.ID)); len(got) != 2 {
+		t.Errorf("Search(heist, movies library) = %v, want 2 movies", got)
+	}
+
+	// Every term must match.
+	if got := ids(search("heist crew", "", 0)); !equalIDs(got, []int64{crew.ID, heist.ID}) {
+		t.Errorf("Search(heist crew) = %v, want [%d %d]", got, crew.ID, heist.ID)
+	}
+	if got := search("heist zebra", "", 0); len(got) != 0 {
+		t.Errorf("Search(heist zebra) = %+v, want nothing", got)
+	}
+
+	// Updates are reindexed.
+	heist.OriginalTitle = "Hitze"
+	must(t, s.UpdateMovie(heist))
+	if got := ids(search("hitze", "", 0)); !equalIDs(got, []int64{heist.ID}) {
+		t.Errorf("Search(hitze) after UpdateMovie = %v", got)
+	}
+	ep.Title = "The Diner Job"
+	must(t, s.UpdateEpisode(ep))
+	if got := search("cafe", "", 0); len(got) != 0 {
+		t.Errorf("Search(cafe) after renaming the episode = %+v", got)
+	}
+
+	// Cleaned-up rows drop out.
+	_, err := s.CleanupEmptyEpisodes(shows.ID)
+	must(t, err)
+	if got := search("diner", "", 0); len(got) != 0 {
+		t.Errorf("Search(diner) after CleanupEmptyEpisodes = %+v", got)
+	}
+
+	if got := search("  ", "", 0); got != nil {
+		t.Errorf("Search(blank) = %+v, want nil", got)
+	}
+}
+
+// catalog is a movies library for the Query* tests:
+//
+//	Alpha  2001  tmdb  100 min  probed 1920x1080 h264
+//	Beta   1999        90 min   unprobed 2160p hevc release, plus a missing file
+//	Gamma  2010                 no files
+//
+// and Delta in another library.
+type catalog struct {
+	lib                        *domain.Library
+	alpha, beta, gamma, delta  *domain.Movie
+	alphaFile, betaFile, stale *domain.MediaFile
+}
+
+func newCatalog(t *testing.T, s store.Store) catalog {
+	t.Helper()
+	c := catalog{
+		lib: library(t, s, "/media/movies", domain.LibraryTypeMovies),
+	}
+	other := library(t, s, "/media/other", domain.LibraryTypeMovies)
+
+	c.alpha = &domain.Movie{LibraryID: c.lib.ID, Title: "Alpha", Year: 2001, TMDBID: ptr("1"), RuntimeMin: 100}
+	c.beta = &domain.Movie{LibraryID: c.lib.ID, Title: "Beta", Year: 1999, RuntimeMin: 90}
+	c.gamma = &domain.Movie{LibraryID: c.lib.ID, Title: "Gamma", Year: 2010}
+	c.delta = &domain.Movie{LibraryID: other.ID, Title: "Delta", Year: 2001}
+	for _, m := range []*domain.Movie{c.gamma, c.beta, c.alpha, c.delta} {
+		must(t, s.CreateMovie(m))
+	}
+
+	scanStart := now()
+	c.alphaFile = &domain.MediaFile{LibraryID: c.lib.ID, MovieID: &c.alpha.ID, Path: "/media/movies/alpha.mkv",
+		LastSeenAt: &scanStart, VideoCodec: "h264", VideoWidth: 1920, VideoHeight: 1080, DurationSec: 6000}
+	c.betaFile = &domain.MediaFile{LibraryID: c.lib.ID, MovieID: &c.beta.ID, Path: "/media/movies/beta.2160p.x265.mkv",
+		LastSeenAt: &scanStart, ReleaseResolution: "2160p", ReleaseVideoCodec: "hevc", DurationSec: 5400}
+	old := scanStart.Add(-time.Hour)
+	c.stale = &domain.MediaFile{LibraryID: c.lib.ID, MovieID: &c.beta.ID, Path: "/media/movies/beta.old.mkv",
+		LastSeenAt: &old, VideoCodec: "h264", VideoWidth: 1920, VideoHeight: 800}
+	for _, mf := range []*domain.MediaFile{c.betaFile, c.stale, c.alphaFile} {
+		must(t, s.CreateMediaFile(mf))
+	}
+	_, err := s.MarkMissingMediaFiles(c.lib.ID, scanStart)
+	must(t, err)
+	return c
+}
+
+func movieIDs(ms []domain.Movie) []int64 {
+	var ids []int64
+	for _, m := range ms {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+func testQueryMovies(t *testing.T, s store.Store) {
+	c := newCatalog(t, s)
+	lib := c.lib.ID
+
+	tests := []struct {
+		name  string
+		f     store.ListFilter
+		p     store.Page
+		want  []*domain.Movie
+		total int
+	}{
+		{"by title", store.ListFilter{LibraryID: lib}, store.Page{}, []*domain.Movie{c.alpha, c.beta, c.gamma}, 3},
+		{"all libraries", store.ListFilter{}, store.Page{}, []*domain.Movie{c.alpha, c.beta, c.delta, c.gamma}, 4},
+		{"window", store.ListFilter{LibraryID: lib}, store.Page{Limit: 2, Offset: 1}, []*domain.Movie{c.beta, c.gamma}, 3},
+		{"offset only", store.ListFilter{LibraryID: lib}, store.Page{Offset: 2}, []*domain.Movie{c.gamma}, 3},
+		{"past the end", store.ListFilter{LibraryID: lib}, store.Page{Limit: 2, Offset: 5}, nil, 3},
+		{"year desc", store.ListFilter{LibraryID: lib}, store.Page{Sort: store.SortYear, Desc: true}, []*domain.Movie{c.gamma, c.alpha, c.beta}, 3},
+		{"runtime", store.ListFilter{LibraryID: lib}, store.Page{Sort: store.SortRuntime}, []*domain.Movie{c.gamma, c.beta, c.alpha}, 3},
+		{"added", store.ListFilter{LibraryID: lib}, store.Page{Sort: store.SortAdded}, []*domain.Movie{c.gamma, c.beta, c.alpha}, 3},
+		{"year from", store.ListFilter{LibraryID: lib, YearMin: 2000}, store.Page{}, []*domain.Movie{c.alpha, c.gamma}, 2},
+		{"year range", store.ListFilter{LibraryID: lib, YearMin: 1990, YearMax: 2005}, store.Page{}, []*domain.Movie{c.alpha, c.beta}, 2},
+		{"probed resolution", store.ListFilter{LibraryID: lib, Resolution: "1080p"}, store.Page{}, []*domain.Movie{c.alpha}, 1},
+		{"release resolution", store.ListFilter{LibraryID: lib, Resolution: "2160P"}, store.Page{}, []*domain.Movie{c.beta}, 1},
+		{"codec", store.ListFilter{LibraryID: lib, Codec: "HEVC"}, store.Page{}, []*domain.Movie{c.beta}, 1},
+		// Beta's h264 file is missing, so it doesn't count.
+		{"present files only", store.ListFilter{LibraryID: lib, Codec: "h264"}, store.Page{}, []*domain.Movie{c.alpha}, 1},
+		{"tmdb", store.ListFilter{LibraryID: lib, HasTMDB: ptr(true)}, store.Page{}, []*domain.Movie{c.alpha}, 1},
+		{"no tmdb", store.ListFilter{LibraryID: lib, HasTMDB: ptr(false)}, store.Page{}, []*domain.Movie{c.beta, c.gamma}, 2},
+		{"missing", store.ListFilter{LibraryID: lib, Missing: ptr(true)}, store.Page{}, []*domain.Movie{c.beta}, 1},
+		{"not missing", store.ListFilter{LibraryID: lib, Missing: ptr(false)}, store.Page{}, []*domain.Movie{c.alpha, c.gamma}, 2},
+	}
+	for _, tt := range tests {
+		got, total, err := s.QueryMovies(tt.f, tt.p)
+		must(t, err)
+		want := make([]domain.Movie, 0, len(tt.want))
+		for _, m := range tt.want {
+			want = append(want, *m)
+		}
+		if !equalIDs(movieIDs(got), movieIDs(want)) || total != tt.total {
+			t.Errorf("%s: QueryMovies = %v (total %d), want %v (total %d)",
+				tt.name, movieIDs(got), total, movieIDs(want), tt.total)
+		}
+	}
+
+	if _, _, err := s.QueryMovies(store.ListFilter{}, store.Page{Sort: store.SortPath}); !errors.Is(err, store.ErrInvalidSort) {
+		t.Errorf("QueryMovies(sort path) err = %v, want ErrInvalidSort", err)
+	}
+}
+
+func testQuerySeriesAndEpisodes(t *testing.T, s store.Store) {
+	lib := library(t, s, "/media/shows", domain.LibraryTypeSeries)
+	other := library(t, s, "/media/other", domain.LibraryTypeSeries)
+
+	a := &domain.Series{LibraryID: lib.ID, Title: "Show A", Year: 2005, TMDBID: ptr("57243")}
+	must(t, s.CreateSeries(a))
+	b := series(t, s, lib.ID, "Show B", 2010)
+	series(t, s, other.ID, "Show C", 2001)
+
+	se := season(t, s, a.ID, 1)
+	ep1, ep2, ep3 := episode(t, s, se.ID, 1), episode(t, s, se.ID, 2), episode(t, s, se.ID, 3)
+	bEp := episode(t, s, season(t, s, b.ID, 1).ID, 1)
+
+	scanStart := now()
+	f := episodeFile(t, s, lib.ID, "/media/shows/a/s01e01-e02.mkv", scanStart, ep1, ep2)
+	f.VideoCodec, f.VideoWidth, f.VideoHeight = "h264", 1280, 720
+	must(t, s.UpdateMediaFile(f))
+	episodeFile(t, s, lib.ID, "/media/shows/b/s01e01.mkv", scanStart.Add(-time.Hour), bEp)
+	_, err := s.MarkMissingMediaFiles(lib.ID, scanStart)
+	must(t, err)
+
+	seriesIDs := func(f store.ListFilter, p store.Page) ([]int64, int) {
+		t.Helper()
+		got, total, err := s.QuerySeries(f, p)
+		must(t, err)
+		var ids []int64
+		for _, sr := range got {
+			ids = append(ids, sr.ID)
+		}
+		return ids, total
+	}
+	if got, total := seriesIDs(store.ListFilter{LibraryID: lib.ID}, store.Page{}); !equalIDs(got, []int64{a.ID, b.ID}) || total != 2 {
+		t.Errorf("QuerySeries(library) = %v (total %d)", got, total)
+	}
+	if got, _ := seriesIDs(store.ListFilter{LibraryID: lib.ID}, store.Page{Sort: store.SortYear, Desc: true}); !equalIDs(got, []int64{b.ID, a.ID}) {
+		t.Errorf("QuerySeries(year desc) = %v", got)
+	}
+	if got, total := seriesIDs(store.ListFilter{LibraryID: lib.ID}, store.Page{Limit: 1}); !equalIDs(got, []int64{a.ID}) || total != 2 {
+		t.Errorf("QuerySeries(limit 1) = %v (total %d)", got, total)
+	}
+	if got, _ := seriesIDs(store.ListFilter{Resolution: "720p"}, store.Page{}); !equalIDs(got, []int64{a.ID}) {
+		t.Errorf("QuerySeries(720p) = %v", got)
+	}
+	if got, _ := seriesIDs(store.ListFilter{Missing: ptr(true)}, store.Page{}); !equalIDs(got, []int64{b.ID}) {
+		t.Errorf("QuerySeries(missing) = %v", got)
+	}
+	if got, _ := seriesIDs(store.ListFilter{HasTMDB: ptr(true), YearMax: 2008}, store.Page{}); !equalIDs(got, []int64{a.ID}) {
+		t.Errorf("QuerySeries(tmdb, year max) = %v", got)
+	}
+	if _, _, err := s.QuerySeries(store.ListFilter{}, store.Page{Sort: store.SortRuntime}); !errors.Is(err, store.ErrInvalidSort) {
+		t.Errorf("QuerySeries(sort runtime) err = %v, want ErrInvalidSort", err)
+	}
+
+	eps, total, err := s.QueryEpisodes(store.ListFilter{SeasonID: se.ID}, store.Page{Limit: 2})
+	must(t, err)
+	if !equalIDs(episodeIDs(eps), []int64{ep1.ID, ep2.ID}) || total != 3 {
+		t.Errorf("QueryEpisodes(limit 2) = %v (total %d)", episodeIDs(eps), total)
+	}
+	eps, _, err = s.QueryEpisodes(store.ListFilter{SeasonID: se.ID}, store.Page{Sort: store.SortNumber, Desc: true, Offset: 1})
+	must(t, err)
+	if !equalIDs(episodeIDs(eps), []int64{ep2.ID, ep1.ID}) {
+		t.Errorf("QueryEpisodes(number desc, offset 1) = %v", episodeIDs(eps))
+	}
+	// The range file counts for both of its episodes.
+	eps, total, err = s.QueryEpisodes(store.ListFilter{SeasonID: se.ID, Codec: "h264"}, store.Page{})
+	must(t, err)
+	if !equalIDs(episodeIDs(eps), []int64{ep1.ID, ep2.ID}) || total != 2 {
+		t.Errorf("QueryEpisodes(h264) = %v (total %d)", episodeIDs(eps), total)
+	}
+	eps, _, err = s.QueryEpisodes(store.ListFilter{LibraryID: lib.ID, Missing: ptr(true)}, store.Page{})
+	must(t, err)
+	if !equalIDs(episodeIDs(eps), []int64{bEp.ID}) {
+		t.Errorf("QueryEpisodes(missing) = %v", episodeIDs(eps))
+	}
+	eps, _, err = s.QueryEpisodes(store.ListFilter{SeasonID: se.ID, Missing: ptr(false)}, store.Page{})
+	must(t, err)
+	if !equalIDs(episodeIDs(eps), []int64{ep1.ID, ep2.ID, ep3.ID}) {
+		t.Errorf("QueryEpisodes(not missing) = %v", episodeIDs(eps))
+	}
+}
+
+func testQueryMediaFiles(t *testing.T, s store.Store) {
+	c := newCatalog(t, s)
+
+	fileIDs := func(f store.ListFilter, p store.Page) ([]int64, int) {
+		t.Helper()
+		got, total, err := s.QueryMediaFiles(f, p)
+		must(t, err)
+		var ids []int64
+		for _, mf := range got {
+			ids = append(ids, mf.ID)
+		}
+		return ids, total
+	}
+
+	lib := c.lib.ID
+	tests := []struct {
+		name  string
+		f     store.ListFilter
+		p     store.Page
+		want  []int64
+		total int
+	}{
+		{"by path", store.ListFilter{LibraryID: lib}, store.Page{}, []int64{c.alphaFile.ID, c.betaFile.ID, c.stale.ID}, 3},
+		{"window", store.ListFilter{LibraryID: lib}, store.Page{Limit: 1, Offset: 1}, []int64{c.betaFile.ID}, 3},
+		{"runtime desc", store.ListFilter{LibraryID: lib}, store.Page{Sort: store.SortRuntime, Desc: true}, []int64{c.alphaFile.ID, c.betaFile.ID, c.stale.ID}, 3},
+		// 1920x800 is a letterboxed 1080p film.
+		{"resolution", store.ListFilter{LibraryID: lib, Resolution: "1080p"}, store.Page{}, []int64{c.alphaFile.ID, c.stale.ID}, 2},
+		{"release resolution", store.ListFilter{LibraryID: lib, Resolution: "2160p"}, store.Page{}, []int64{c.betaFile.ID}, 1},
+		{"codec", store.ListFilter{LibraryID: lib, Codec: "h264"}, store.Page{}, []int64{c.alphaFile.ID, c.stale.ID}, 2},
+		{"missing", store.ListFilter{LibraryID: lib, Missing: ptr(true)}, store.Page{}, []int64{c.stale.ID}, 1},
+		{"present", store.ListFilter{LibraryID: lib, Missing: ptr(false)}, store.Page{}, []int64{c.alphaFile.ID, c.betaFile.ID}, 2},
+	}
+	for _, tt := range tests {
+		if got, total := fileIDs(tt.f, tt.p); !equalIDs(got, tt.want) || total != tt.total {
+			t.Errorf("%s: QueryMediaFiles = %v (total %d), want %v (total %d)", tt.name, got, total, tt.want, tt.total)
+		}
+	}
+
+	// An unprobed interlaced release is in the progressive class.
+	tv := library(t, s, "/media/tv", domain.LibraryTypeMovies)
+	seen := time.Now().UTC()
+	interlaced := &domain.MediaFile{LibraryID: tv.ID, Path: "/media/tv/Show.1080i.HDTV.ts", LastSeenAt: &seen, ReleaseResolution: "1080i"}
+	must(t, s.CreateMediaFile(interlaced))
+	if got, _ := fileIDs(store.ListFilter{LibraryID: tv.ID, Resolution: "1080p"}, store.Page{}); !equalIDs(got, []int64{interlaced.ID}) {
+		t.Errorf("QueryMediaFiles(1080p) = %v, want the 1080i release", got)
+	}
+
+	if _, _, err := s.QueryMediaFiles(store.ListFilter{}, store.Page{Sort: store.SortTitle}); !errors.Is(err, store.ErrInvalidSort) {
+		t.Errorf("QueryMediaFiles(sort title) err = %v, want ErrInvalidSort", err)
+	}
+}
+
+func testDeleteLibrary(t *testing.T, s store.Store) {
+	lib := library(t, s, "/media/mixed", domain.LibraryTypeMovies)
+	kept := library(t, s, "/media/kept", domain.LibraryTypeMovies)
+
+	movie := &domain.Movie{LibraryID: lib.ID, Title: "Gone Movie", Year: 2001}
+	must(t, s.CreateMovie(movie))
+	seen := now()
+	movieFile := &domain.MediaFile{LibraryID: lib.ID, MovieID: &movie.ID, Path: "/media/mixed/gone.mkv", LastSeenAt: &seen}
+	must(t, s.CreateMediaFile(movieFile))
+	sub := &domain.SubtitleTrack{MediaFileID: movieFile.ID, Source: domain.SubtitleSourceExternal,
+		ExternalPath: ptr("/media/mixed/gone.en.srt"), Language: "en", Format: "srt"}
+	must(t, s.CreateSubtitleTrack(sub))
+	must(t, s.CreateAudioTrack(&domain.AudioTrack{MediaFileID: movieFile.ID, StreamIndex: 1}))
+	must(t, s.CreateVideoStream(&domain.VideoStream{MediaFileID: movieFile.ID, StreamIndex: 0}))
+	must(t, s.CreateChapter(&domain.Chapter{MediaFileID: movieFile.ID, Index: 0}))
+
+	sr := series(t, s, lib.ID, "Gone Show", 2010)
+	se := season(t, s, sr.ID, 1)
+	ep := episode(t, s, se.ID, 1)
+	epFile := episodeFile(t, s, lib.ID, "/media/mixed/show/s01e01.mkv", seen, ep)
+
+	keptMovie := &domain.Movie{LibraryID: kept.ID, Title: "Gone Fishing", Year: 2001}
+	must(t, s.CreateMovie(keptMovie))
+	keptFile := &domain.MediaFile{LibraryID: kept.ID, MovieID: &keptMovie.ID, Path: "/media/kept/fishing.mkv", LastSeenAt: &seen}
+	must(t, s.CreateMediaFile(keptFile))
+
+	must(t, s.DeleteLibrary(lib.ID))
+
+	if _, err := s.GetLibrary(lib.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetLibrary after delete err = %v, want sql.ErrNoRows", err)
+	}
+	for name, err := range map[string]error{
+		"movie":   func() error { _, err := s.GetMovie(movie.ID); return err }(),
+		"series":  func() error { _, err := s.GetSeries(sr.ID); return err }(),
+		"season":  func() error { _, err := s.GetSeason(se.ID); return err }(),
+		"episode": func() error { _, err := s.GetEpisode(ep.ID); return err }(),
+		"file":    func() error { _, err := s.GetMediaFile(movieFile.ID); return err }(),
+		"ep file": func() error { _, err := s.GetMediaFile(epFile.ID); return err }(),
+	} {
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("%s survived DeleteLibrary (err %v)", name, err)
+		}
+	}
+	if got, err := s.GetSubtitleTrack(sub.ID); got != nil || err != nil {
+		t.Errorf("GetSubtitleTrack after delete = %v, %v, want nil, nil", got, err)
+	}
+	audio, err := s.ListAudioTracks(movieFile.ID)
+	must(t, err)
+	video, err := s.ListVideoStreams(movieFile.ID)
+	must(t, err)
+	chapters, err := s.ListChapters(movieFile.ID)
+	must(t, err)
+	if len(audio)+len(video)+len(chapters) != 0 {
+		t.Errorf("streams survived DeleteLibrary: %d audio, %d video, %d chapters", len(audio), len(video), len(chapters))
+	}
+
+	hits, err := s.Search("gone", "", 0, 10)
+	must(t, err)
+	if len(hits) != 1 || hits[0].ID != keptMovie.ID {
+		t.Errorf("Search(gone) after delete = %+v, want only the other library's movie", hits)
+	}
+	if got, err := s.GetMediaFile(keptFile.ID); err != nil || got.LibraryID != kept.ID {
+		t.Errorf("other library's file = %v, %v", got, err)
+	}
+
+	if err := s.DeleteLibrary(lib.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DeleteLibrary twice err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testRelocateLibrary(t *testing.T, s store.Store) {
+	lib := library(t, s, "/mnt/media/movies", domain.LibraryTypeMovies)
+	// Shares the old root as a string prefix, but is not under it.
+	other := library(t, s, "/mnt/media/movies-4k", domain.LibraryTypeMovies)
+
+	seen := now()
+	file := func(libraryID int64, path string) *domain.MediaFile {
+		mf := &domain.MediaFile{LibraryID: libraryID, Path: path, LastSeenAt: &seen}
+		must(t, s.CreateMediaFile(mf))
+		return mf
+	}
+	moved := file(lib.ID, "/mnt/media/movies/Amélie (2001)/Amélie.mkv")
+	outside := file(lib.ID, "/mnt/elsewhere/stray.mkv")
+	otherFile := file(other.ID, "/mnt/media/movies-4k/Dune.mkv")
+
+	sidecar := &domain.SubtitleTrack{MediaFileID: moved.ID, Source: domain.SubtitleSourceExternal,
+		ExternalPath: ptr("/mnt/media/movies/Amélie (2001)/Amélie.fr.srt"), Language: "fr", Format: "srt"}
+	must(t, s.CreateSubtitleTrack(sidecar))
+	synced := &domain.SubtitleTrack{MediaFileID: moved.ID, Source: domain.SubtitleSourceSynced,
+		ExternalPath: ptr("/var/cache/vio/synced/1.vtt"), Language: "fr", Format: "vtt"}
+	must(t, s.CreateSubtitleTrack(synced))
+
+	files, sidecars, err := s.RelocateLibrary(lib.ID, lib.Path, "/srv/média/movies")
+	must(t, err)
+	if files != 1 || sidecars != 1 {
+		t.Errorf("RelocateLibrary = %d files, %d sidecars, want 1, 1", files, sidecars)
+	}
+
+	got, err := s.GetLibrary(lib.ID)
+	must(t, err)
+	if got.Path != "/srv/média/movies" {
+		t.Errorf("library path = %q", got.Path)
+	}
+	for _, c := range []struct {
+		id   int64
+		want string
+	}{
+		{moved.ID, "/srv/média/movies/Amélie (2001)/Amélie.mkv"},
+		{outside.ID, "/mnt/elsewhere/stray.mkv"},
+		{otherFile.ID, "/mnt/media/movies-4k/Dune.mkv"},
+	} {
+		mf, err := s.GetMediaFile(c.id)
+		must(t, err)
+		if mf.Path != c.want {
+			t.Errorf("file %d path = %q, want %q", c.id, mf.Path, c.want)
+		}
+	}
+	for id, want := range map[int64]string{
+		sidecar.ID: "/srv/média/movies/Amélie (2001)/Amélie.fr.srt",
+		synced.ID:  "/var/cache/vio/synced/1.vtt",
+	} {
+		st, err := s.GetSubtitleTrack(id)
+		must(t, err)
+		if st == nil || st.ExternalPath == nil || *st.ExternalPath != want {
+			t.Errorf("subtitle %d path = %v, want %q", id, st, want)
+		}
+	}
+
+	// A clash fails the whole relocation.
+	err = s.WithTx(func(tx store.Store) error {
+		_, _, err := tx.RelocateLibrary(lib.ID, "/srv/média/movies", "/mnt/media/movies-4k")
+		return err
+	})
+	if err == nil {
+		t.Fatal("RelocateLibrary onto another library's root succeeded")
+	}
+	if mf, err := s.GetMediaFile(moved.ID); err != nil || mf.Path != "/srv/média/movies/Amélie (2001)/Amélie.mkv" {
+		t.Errorf("file after failed relocation = %v, %v", mf, err)
+	}
+
+	if _, _, err := s.RelocateLibrary(lib.ID, "/mnt/media/movies", "/x"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("RelocateLibrary of a former root err = %v, want sql.ErrNoRows", err)
+	}
+	if _, _, err := s.RelocateLibrary(999999, "/x", "/y"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("RelocateLibrary of a missing library err = %v, want sql.ErrNoRows", err)
+	}
+
+	// Moving a second root leaves the first, and its files, alone.
+	lib.Path, lib.Roots = "/srv/média/movies", []string{"/srv/média/movies", "/mnt/disk2"}
+	must(t, s.UpdateLibrary(lib))
+	second := file(lib.ID, "/mnt/disk2/Heat (1995).mkv")
+	files, _, err = s.RelocateLibrary(lib.ID, "/mnt/disk2", "/srv/disk2")
+	must(t, err)
+	if files != 1 {
+		t.Errorf("RelocateLibrary of the second root = %d files, want 1", files)
+	}
+	got, err = s.GetLibrary(lib.ID)
+	must(t, err)
+	if got.Path != "/srv/média/movies" || !slices.Equal(got.Roots, []string{"/srv/média/movies", "/srv/disk2"}) {
+		t.Errorf("library after moving its second root = %q, %q", got.Path, got.Roots)
+	}
+	if mf, err := s.GetMediaFile(second.ID); err != nil || mf.Path != "/srv/disk2/Heat (1995).mkv" {
+		t.Errorf("second root's file = %v, %v", mf, err)
+	}
+}
+
+func testLibraryRoots(t *testing.T, s store.Store) {
+	lib := &domain.Library{Name: "Movies", Type: domain.LibraryTypeMovies,
+		Roots: []string{"/mnt/disk1/movies", "/mnt/disk2/movies", "/mnt/disk3/movies"}}
+	must(t, s.CreateLibrary(lib))
+	if lib.Path != "/mnt/disk1/movies" {
+		t.Errorf("CreateLibrary set Path %q, want the first root", lib.Path)
+	}
+
+	got, err := s.GetLibrary(lib.ID)
+	must(t, err)
+	if got.Path != lib.Path || !slices.Equal(got.Roots, lib.Roots) {
+		t.Errorf("GetLibrary = %q, %q, want %q, %q", got.Path, got.Roots, lib.Path, lib.Roots)
+	}
+
+	single := library(t, s, "/mnt/shows", domain.LibraryTypeSeries)
+	libs, err := s.ListLibraries()
+	must(t, err)
+	for _, l := range libs {
+		want := lib.Roots
+		if l.ID == single.ID {
+			want = []string{"/mnt/shows"}
+		}
+		if !slices.Equal(l.Roots, want) {
+			t.Errorf("ListLibraries: library %d roots = %q, want %q", l.ID, l.Roots, want)
+		}
+	}
+
+	// A root is one library's only.
+	dup := &domain.Library{Name: "dup", Type: domain.LibraryTypeMovies, Roots: []string{"/mnt/elsewhere", "/mnt/disk2/movies"}}
+	if err := s.CreateLibrary(dup); err == nil {
+		t.Error("CreateLibrary accepted another library's root")
+	}
+	single.Roots = []string{"/mnt/shows", "/mnt/disk3/movies"}
+	if err := s.UpdateLibrary(single); err == nil {
+		t.Error("UpdateLibrary accepted another library's root")
+	}
+	if err := s.CreateLibrary(&domain.Library{Name: "twice", Type: domain.LibraryTypeMovies,
+		Roots: []string{"/mnt/twice", "/mnt/twice"}}); err == nil {
+		t.Error("CreateLibrary accepted the same root twice")
+	}
+
+	// Updating replaces the roots, in the order given.
+	lib.Path = ""
+	lib.Roots = []string{"/mnt/disk3/movies", "/mnt/disk1/movies", "/mnt/disk4/movies"}
+	must(t, s.UpdateLibrary(lib))
+	got, err = s.GetLibrary(lib.ID)
+	must(t, err)
+	if got.Path != "/mnt/disk3/movies" || !slices.Equal(got.Roots, lib.Roots) {
+		t.Errorf("after UpdateLibrary = %q, %q, want %q, %q", got.Path, got.Roots, "/mnt/disk3/movies", lib.Roots)
+	}
+
+	// A dropped root is free for another library; deleting a library frees
+	// all of its roots.
+	must(t, s.CreateLibrary(&domain.Library{Name: "reuse", Type: domain.LibraryTypeMovies, Path: "/mnt/disk2/movies"}))
+	must(t, s.DeleteLibrary(lib.ID))
+	must(t, s.CreateLibrary(&domain.Library{Name: "again", Type: domain.LibraryTypeMovies, Path: "/mnt/disk3/movies"}))
+}
+
+func testMarkMissingSkipsOfflineRoots(t *testing.T, s store.Store) {
+	lib := &domain.Library{Name: "Movies", Type: domain.LibraryTypeMovies,
+		Roots: []string{"/mnt/disk1", "/mnt/disk2"}}
+	must(t, s.CreateLibrary(lib))
+
+	scanStart := now()
+	before := scanStart.Add(-time.Hour)
+
+	online := &domain.MediaFile{LibraryID: lib.ID, Path: "/mnt/disk1/gone.mkv", LastSeenAt: &before}
+	offline := &domain.MediaFile{LibraryID: lib.ID, Path: "/mnt/disk2/unseen.mkv", LastSeenAt: &before}
+	// Shares the offline root as a string prefix, but is not under it.
+	sibling := &domain.MediaFile{LibraryID: lib.ID, Path: "/mnt/disk22/gone.mkv", LastSeenAt: &before}
+	for _, mf := range []*domain.MediaFile{online, offline, sibling} {
+		must(t, s.CreateMediaFile(mf))
+	}
+
+	n, err := s.MarkMissingMediaFiles(lib.ID, scanStart, "/mnt/disk2")
+	must(t, err)
+	if n != 2 {
+		t.Errorf("MarkMissingMediaFiles = %d, want 2", n)
+	}
+	for _, tc := range []struct {
+		mf      *domain.MediaFile
+		missing bool
+	}{{online, true}, {offline, false}, {sibling, true}} {
+		got, err := s.GetMediaFile(tc.mf.ID)
+		must(t, err)
+		if got.IsMissing != tc.missing {
+			t.Errorf("%s: IsMissing = %v, want %v", got.Path, got.IsMissing, tc.missing)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package subprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/bastianvv/vio/internal/domain"
+	"github.com/bastianvv/vio/internal/store"
+	"github.com/bastianvv/vio/internal/subtitle"
+)
+
+var ErrNoProviders = errors.New("no subtitle providers configured")
+
+// Manager runs searches across the configured providers and stores
+// downloads under dir as DOWNLOADED subtitle tracks. Files are never written
+// next to the media.
+type Manager struct {
+	store     store.Store
+	providers []Provider
+	languages []string
+	dir       string
+
+	// Candidates of the last search per media file, so downloads can be
+	// requested by provider and ID alone.
+	mu         sync.Mutex
+	candidates map[int64]map[string]Candidate
+}
+
+func NewManager(s store.Store, providers []Provider, languages []string, dir string) *Manager {
+	return &Manager{
+		store:      s,
+		providers:  providers,
+		languages:  languages,
+		dir:        dir,
+		candidates: make(map[int64]map[string]Candidate),
+	}
+}
+
+// Search asks every provider for subtitles for a media file. One failing
+// provider does not hide the results of the others.
+func (m *Manager) Search(ctx context.Context, mediaFileID int64) ([]Candidate, error) {
+	if len(m.providers) == 0 {
+		return nil, ErrNoProviders
+	}
+
+	mf, err := m.store.GetMediaFile(mediaFileID)
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := m.query(mf)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		out     []Candidate
+		lastErr error
+		ok      int
+	)
+	for _, p := range m.providers {
+		found, err := p.Search(ctx, q)
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", p.Name(), err)
+			continue
+		}
+		ok++
+		out = append(out, found...)
+	}
+	if ok == 0 {
+		return nil, lastErr
+	}
+
+	m.rank(out)
+
+	byKey := make(map[string]Candidate, len(out))
+	for _, c := range out {
+		byKey[c.Provider+"/"+c.ID] = c
+	}
+	m.mu.Lock()
+	m.candidates[mediaFileID] = byKey
+	m.mu.Unlock()
+
+	return out, nil
+}
+
+// Download fetches a candidate from the last search and registers it as a
+// subtitle track. Downloading the same candidate again refreshes the
+// existing track.
+func (m *Manager) Download(ctx context.Context, mediaFileID int64, provider, id string) (*domain.SubtitleTrack, error) {
+	m.mu.Lock()
+	cand, ok := m.candidates[mediaFileID][provider+"/"+id]
+	m.mu.Unlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var p Provider
+	for _, pr := range m.providers {
+		if pr.Name() == provider {
+			p = pr
+		}
+	}
+	if p == nil {
+		return nil, ErrNotFound
+	}
+
+	data, err := p.Download(ctx, cand)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := m.downloadDir(mediaFileID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, provider+"-"+id+"."+cand.Format)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+
+	track := &domain.SubtitleTrack{
+		MediaFileID:  mediaFileID,
+		Source:       domain.SubtitleSourceDownloaded,
+		ExternalPath: &path,
+		Language:     cand.Language,
+		IsSDH:        cand.HearingImpaired,
+		Format:       cand.Format,
+	}
+	if _, ok := subtitle.FormatFromPath(path); !ok {
+		track.IsBitmap = true
+	}
+
+	existing, err := m.store.ListSubtitleTracks(mediaFileID)
+	if err != nil {
+		return nil, err
+	}
+	for _, ex := range existing {
+		if ex.Source == domain.SubtitleSourceDownloaded && ex.ExternalPath != nil && *ex.ExternalPath == path {
+			track.ID = ex.ID
+			return track, m.store.UpdateSubtitleTrack(track)
+		}
+	}
+
+	return track, m.store.CreateSubtitleTrack(track)
+}
+
+func (m *Manager) query(mf *domain.MediaFile) (Query, error) {
+	q := Query{
+		FileName:  filepath.Base(mf.Path),
+		FileSize:  mf.SizeBytes,
+		Languages: m.languages,
+	}
+
+	// A missing file still allows an ID based search.
+	if hash, size, err := MovieHash(mf.Path); err == nil {
+		q.MovieHash, q.FileSize = hash, size
+	}
+
+	switch {
+	case mf.MovieID != nil:
+		mv, err := m.store.GetMovie(*mf.MovieID)
+		if err != nil {
+			return q, err
+		}
+		if mv.TMDBID != nil {
+			q.TMDBID = *mv.TMDBID
+		}
+
+	case mf.EpisodeID != nil:
+		ep, err := m.store.GetEpisode(*mf.EpisodeID)
+		if err != nil {
+			return q, err
+		}
+		season, err := m.store.GetSeason(ep.SeasonID)
+		if err != nil {
+			return q, err
+		}
+		series, err := m.store.GetSeries(season.SeriesID)
+		if err != nil {
+			return q, err
+		}
+		if series.TMDBID != nil {
+			q.SeriesTMDBID = *series.TMDBID
+			q.Season = season.Number
+			q.Episode = ep.Number
+		}
+	}
+
+	return q, nil
+}
+
+// rank puts exact fingerprint matches first, then follows the configured
+// language order, then popularity.
+func (m *Manager) rank(list []Candidate) {
+	pref := make(map[string]int, len(m.languages))
+	for i, l := range m.languages {
+		pref[l] = i
+	}
+	langRank := func(l string) int {
+		if i, ok := pref[l]; ok {
+			return i
+		}
+		return len(pref)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.HashMatch != b.HashMatch {
+			return a.HashMatch
+		}
+		if la, lb := langRank(a.Language), langRank(b.Language); la != lb {
+			return la < lb
+		}
+		return a.Downloads > b.Downloads
+	})
+}
+
+// Evict removes a media file's downloads and forgets its last search.
+func (m *Manager) Evict(mediaFileID int64) error {
+	m.mu.Lock()
+	delete(m.candidates, mediaFileID)
+	m.mu.Unlock()
+
+	return os.RemoveAll(m.downloadDir(mediaFileID))
+}
+
+func (m *Manager) downloadDir(mediaFileID int64) string {
+	return filepath.Join(m.dir, "downloaded", strconv.FormatInt(mediaFileID, 10))
+}
@@ -0,0 +1,247 @@
+package subprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bastianvv/vio/internal/language"
+)
+
+const (
+	DefaultOpenSubtitlesURL = "https://api.opensubtitles.com/api/v1"
+
+	// Subtitle files are small; anything bigger is not one.
+	maxDownloadBytes = 10 << 20
+
+	// Bounds a whole request, body included, so a stalled API can't hold a
+	// search or download open.
+	requestTimeout = 30 * time.Second
+)
+
+// OpenSubtitles talks to the OpenSubtitles REST API, or anything that
+// speaks it (a local stand-in in tests, a caching proxy).
+type OpenSubtitles struct {
+	apiKey  string
+	baseURL string
+	http    *http.Client
+}
+
+func NewOpenSubtitles(apiKey, baseURL string) *OpenSubtitles {
+	if baseURL == "" {
+		baseURL = DefaultOpenSubtitlesURL
+	}
+	return &OpenSubtitles{
+		apiKey:  apiKey,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: requestTimeout},
+	}
+}
+
+func (c *OpenSubtitles) Name() string {
+	return "opensubtitles"
+}
+
+type osSearchResponse struct {
+	Data []struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			Language        string  `json:"language"`
+			Release         string  `json:"release"`
+			FPS             float64 `json:"fps"`
+			HearingImpaired bool    `json:"hearing_impaired"`
+			DownloadCount   int     `json:"download_count"`
+			MovieHashMatch  bool    `json:"moviehash_match"`
+			Files           []struct {
+				FileID   int64  `json:"file_id"`
+				FileName string `json:"file_name"`
+			} `json:"files"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+type osDownloadResponse struct {
+	Link     string `json:"link"`
+	FileName string `json:"file_name"`
+}
+
+// osErrorResponse is the body of a refused request, e.g. "You have
+// downloaded your allowed 20 subtitles for 24h" with status 406.
+type osErrorResponse struct {
+	Message   string `json:"message"`
+	ResetTime string `json:"reset_time"`
+}
+
+func (c *OpenSubtitles) Search(ctx context.Context, q Query) ([]Candidate, error) {
+	v := url.Values{}
+
+	// The API wants ISO 639-1 codes, lower case, sorted.
+	langs := make([]string, 0, len(q.Languages))
+	for _, l := range q.Languages {
+		langs = append(langs, strings.ToLower(l))
+	}
+	sort.Strings(langs)
+	if len(langs) > 0 {
+		v.Set("languages", strings.Join(langs, ","))
+	}
+
+	if q.MovieHash != "" {
+		v.Set("moviehash", q.MovieHash)
+	}
+	switch {
+	case q.SeriesTMDBID != "":
+		v.Set("parent_tmdb_id", q.SeriesTMDBID)
+		v.Set("season_number", strconv.Itoa(q.Season))
+		v.Set("episode_number", strconv.Itoa(q.Episode))
+	case q.TMDBID != "":
+		v.Set("tmdb_id", q.TMDBID)
+	case q.MovieHash == "":
+		v.Set("query", strings.TrimSuffix(q.FileName, filepath.Ext(q.FileName)))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/subtitles?"+v.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, quotaError(resp)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("opensubtitles search failed: %s", resp.Status)
+	}
+
+	var raw osSearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	var out []Candidate
+	for _, d := range raw.Data {
+		a := d.Attributes
+		for _, f := range a.Files {
+			format := strings.TrimPrefix(strings.ToLower(filepath.Ext(f.FileName)), ".")
+			if format == "" {
+				format = "srt"
+			}
+
+			out = append(out, Candidate{
+				Provider:        c.Name(),
+				ID:              strconv.FormatInt(f.FileID, 10),
+				Language:        language.Normalize(a.Language),
+				Release:         a.Release,
+				FileName:        f.FileName,
+				Format:          format,
+				FPS:             a.FPS,
+				HashMatch:       a.MovieHashMatch,
+				HearingImpaired: a.HearingImpaired,
+				Downloads:       a.DownloadCount,
+			})
+		}
+	}
+
+	return out, nil
+}
+
+func (c *OpenSubtitles) Download(ctx context.Context, cand Candidate) ([]byte, error) {
+	fileID, err := strconv.ParseInt(cand.ID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("opensubtitles: invalid file id %q", cand.ID)
+	}
+
+	body, _ := json.Marshal(map[string]any{"file_id": fileID})
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/download", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	// 406 is the daily download allowance, 429 the request rate limit.
+	if resp.StatusCode == http.StatusNotAcceptable || resp.StatusCode == http.StatusTooManyRequests {
+		return nil, quotaError(resp)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("opensubtitles download failed: %s", resp.Status)
+	}
+
+	var dl osDownloadResponse
+	if err := json.NewDecoder(resp.Body).Decode(&dl); err != nil {
+		return nil, err
+	}
+	if dl.Link == "" {
+		return nil, fmt.Errorf("opensubtitles download returned no link")
+	}
+
+	req, err = http.NewRequestWithContext(ctx, "GET", dl.Link, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	fileResp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer fileResp.Body.Close()
+
+	if fileResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("opensubtitles file fetch failed: %s", fileResp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(fileResp.Body, maxDownloadBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDownloadBytes {
+		return nil, fmt.Errorf("opensubtitles file too large")
+	}
+
+	return data, nil
+}
+
+// quotaError wraps ErrQuota with the API's explanation, when it gave one.
+func quotaError(resp *http.Response) error {
+	var e osErrorResponse
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&e)
+
+	msg := resp.Status
+	if e.Message != "" {
+		msg = e.Message
+	}
+	if e.ResetTime != "" {
+		msg += " (resets " + e.ResetTime + ")"
+	}
+	return fmt.Errorf("%w: opensubtitles: %s", ErrQuota, msg)
+}
+
+func (c *OpenSubtitles) setHeaders(req *http.Request) {
+	req.Header.Set("Api-Key", c.apiKey)
+	req.Header.Set("User-Agent", "vio v1")
+	req.Header.Set("Accept", "application/json")
+}
@@ -0,0 +1,92 @@
+// Package subprovider searches online subtitle services for a media file
+// and downloads chosen results into VIO's own cache.
+package subprovider
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+var (
+	ErrNotFound = errors.New("subprovider: not found")
+
+	// ErrQuota means the provider refused for now: a download allowance or
+	// rate limit is used up. Retrying later may succeed.
+	ErrQuota = errors.New("subprovider: quota exceeded")
+)
+
+// Query describes the file subtitles are wanted for. Providers use what they
+// support; the fingerprint gives exact release matches, TMDB IDs the rest.
+type Query struct {
+	MovieHash string
+	FileSize  int64
+	FileName  string
+
+	// Movies: TMDBID. Episodes: SeriesTMDBID with season/episode numbers.
+	TMDBID       string
+	SeriesTMDBID string
+	Season       int
+	Episode      int
+
+	Languages []string // BCP-47, in order of preference
+}
+
+// Candidate is one downloadable subtitle offered by a provider.
+type Candidate struct {
+	Provider        string  `json:"provider"`
+	ID              string  `json:"id"`
+	Language        string  `json:"language"`
+	Release         string  `json:"release,omitempty"`
+	FileName        string  `json:"file_name,omitempty"`
+	Format          string  `json:"format"`
+	FPS             float64 `json:"fps,omitempty"`
+	HashMatch       bool    `json:"hash_match"`
+	HearingImpaired bool    `json:"hearing_impaired"`
+	Downloads       int     `json:"downloads"`
+}
+
+// Provider is a subtitle source such as OpenSubtitles.
+type Provider interface {
+	Name() string
+	Search(ctx context.Context, q Query) ([]Candidate, error)
+
+	// Download returns the subtitle file contents of a candidate.
+	Download(ctx context.Context, c Candidate) ([]byte, error)
+}
+
+const hashChunk = 64 * 1024
+
+// MovieHash is the OpenSubtitles file fingerprint: the file size plus the
+// little-endian uint64 sums of the first and last 64 KiB.
+func MovieHash(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = f.Close() }()
+
+	st, err := f.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	size := st.Size()
+
+	sum := uint64(size)
+	buf := make([]byte, hashChunk)
+
+	for _, off := range []int64{0, max(size-hashChunk, 0)} {
+		n, err := f.ReadAt(buf, off)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", 0, err
+		}
+		for i := 0; i+8 <= n; i += 8 {
+			sum += binary.LittleEndian.Uint64(buf[i:])
+		}
+	}
+
+	return fmt.Sprintf("%016x", sum), size, nil
+}
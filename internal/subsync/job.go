@@ -0,0 +1,105 @@
+package subsync
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type JobStatus string
+
+const (
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed"
+)
+
+type Job struct {
+	ID         string     `json:"id"`
+	SubtitleID int64      `json:"subtitle_id"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Status     JobStatus  `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	Result     *Result    `json:"result,omitempty"`
+
+	cancel context.CancelFunc
+}
+
+type Registry struct {
+	mu   sync.RWMutex
+	jobs map[string]*Job
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		jobs: make(map[string]*Job),
+	}
+}
+
+// Start registers a running job and returns it with the context the sync
+// should run under, which Cancel ends.
+func (r *Registry) Start(subtitleID int64) (*Job, context.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		ID:         uuid.NewString(),
+		SubtitleID: subtitleID,
+		StartedAt:  time.Now(),
+		Status:     JobRunning,
+		cancel:     cancel,
+	}
+
+	r.mu.Lock()
+	r.jobs[job.ID] = job
+	r.mu.Unlock()
+
+	return job, ctx
+}
+
+// Cancel ends a running job's context; the job fails once its sync has
+// stopped. It reports false if the job is not running.
+func (r *Registry) Cancel(jobID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	job, ok := r.jobs[jobID]
+	if !ok || job.Status != JobRunning {
+		return false
+	}
+	job.cancel()
+	return true
+}
+
+func (r *Registry) Finish(jobID string, res *Result) {
+	now := time.Now()
+
+	r.mu.Lock()
+	if job, ok := r.jobs[jobID]; ok {
+		job.Status = JobDone
+		job.Result = res
+		job.FinishedAt = &now
+		job.cancel()
+	}
+	r.mu.Unlock()
+}
+
+func (r *Registry) Fail(jobID string, err error) {
+	now := time.Now()
+
+	r.mu.Lock()
+	if job, ok := r.jobs[jobID]; ok {
+		job.Status = JobFailed
+		job.Error = err.Error()
+		job.FinishedAt = &now
+		job.cancel()
+	}
+	r.mu.Unlock()
+}
+
+func (r *Registry) Get(jobID string) (*Job, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	job, ok := r.jobs[jobID]
+	return job, ok
+}
@@ -0,0 +1,230 @@
+// Package subsync aligns sidecar subtitles to the speech in their media
+// file and stores the result as a derived subtitle track. Everything runs
+// locally with ffmpeg; nothing is sent anywhere.
+package subsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/bastianvv/vio/internal/domain"
+	"github.com/bastianvv/vio/internal/media"
+	"github.com/bastianvv/vio/internal/store"
+	"github.com/bastianvv/vio/internal/subtitle"
+)
+
+// MaxShift bounds the offset search. Real-world drift is seconds, rarely
+// more than a minute.
+const MaxShift = 2 * time.Minute
+
+// Fits scoring below this are treated as failures rather than stored.
+const minScore = 0.1
+
+var ErrNotSyncable = errors.New("only external or downloaded text subtitles can be synced")
+
+// Result describes the derived track a sync produced.
+type Result struct {
+	TrackID  int64   `json:"track_id"`
+	OffsetMs int64   `json:"offset_ms"`
+	FPSFrom  float64 `json:"fps_from,omitempty"`
+	FPSTo    float64 `json:"fps_to,omitempty"`
+	Score    float64 `json:"score"`
+}
+
+type Service struct {
+	store store.Store
+	dir   string
+}
+
+// NewService stores synced subtitles under dir, never next to the media.
+func NewService(s store.Store, dir string) *Service {
+	return &Service{store: s, dir: dir}
+}
+
+// Sync aligns one subtitle track and creates or refreshes its SYNCED copy.
+// Ending ctx stops the speech detection, the slow part.
+func (s *Service) Sync(ctx context.Context, subtitleID int64) (*Result, error) {
+	st, err := s.store.GetSubtitleTrack(subtitleID)
+	if err != nil {
+		return nil, err
+	}
+	if st == nil {
+		return nil, fmt.Errorf("subtitle track %d not found", subtitleID)
+	}
+	if !Syncable(st) {
+		return nil, ErrNotSyncable
+	}
+
+	format, _ := subtitle.FormatFromPath(*st.ExternalPath)
+
+	mf, err := s.store.GetMediaFile(st.MediaFileID)
+	if err != nil {
+		return nil, err
+	}
+
+	audio, err := s.pickAudioTrack(mf.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(*st.ExternalPath)
+	if err != nil {
+		return nil, err
+	}
+	cues, err := subtitle.Parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := time.Duration(mf.DurationSec) * time.Second
+	speech, err := media.DetectSpeech(ctx, mf.Path, audio.StreamIndex, duration)
+	if err != nil {
+		return nil, err
+	}
+
+	fit := subtitle.Align(cues, speech, MaxShift)
+	if fit.Score < minScore {
+		return nil, fmt.Errorf("no reliable alignment found (score %.2f)", fit.Score)
+	}
+
+	path, err := s.write(st.ID, fit.Apply(cues))
+	if err != nil {
+		return nil, err
+	}
+
+	track, err := s.saveDerived(st, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		TrackID:  track.ID,
+		OffsetMs: fit.Offset.Milliseconds(),
+		FPSFrom:  fit.FPSFrom,
+		FPSTo:    fit.FPSTo,
+		Score:    fit.Score,
+	}, nil
+}
+
+// Syncable reports whether a track is a text file VIO may align: a sidecar
+// or a downloaded subtitle.
+func Syncable(st *domain.SubtitleTrack) bool {
+	if st.ExternalPath == nil {
+		return false
+	}
+	if st.Source != domain.SubtitleSourceExternal && st.Source != domain.SubtitleSourceDownloaded {
+		return false
+	}
+	_, ok := subtitle.FormatFromPath(*st.ExternalPath)
+	return ok
+}
+
+// pickAudioTrack prefers the default audio stream and avoids commentary and
+// audio description, whose speech does not follow the dialogue.
+func (s *Service) pickAudioTrack(mediaFileID int64) (*domain.AudioTrack, error) {
+	tracks, err := s.store.ListAudioTracks(mediaFileID)
+	if err != nil {
+		return nil, err
+	}
+	if len(tracks) == 0 {
+		return nil, errors.New("media file has no audio track")
+	}
+
+	var main []domain.AudioTrack
+	for _, at := range tracks {
+		if !at.IsCommentary && !at.IsVisualImpaired {
+			main = append(main, at)
+		}
+	}
+	if len(main) == 0 {
+		main = tracks
+	}
+
+	for i := range main {
+		if main[i].IsDefault {
+			return &main[i], nil
+		}
+	}
+	return &main[0], nil
+}
+
+// Evict removes the synced copy made from a subtitle track, if any.
+func (s *Service) Evict(subtitleID int64) error {
+	if err := os.Remove(s.path(subtitleID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *Service) path(subtitleID int64) string {
+	return filepath.Join(s.dir, "synced", strconv.FormatInt(subtitleID, 10)+".vtt")
+}
+
+func (s *Service) write(subtitleID int64, cues []subtitle.Cue) (string, error) {
+	path := s.path(subtitleID)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	// A temp file of its own, so concurrent syncs of one track never
+	// write into each other's output.
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tmp := f.Name()
+
+	if err := subtitle.WriteVTT(f, cues); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Chmod(tmp, 0644); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	return path, nil
+}
+
+// saveDerived reuses an earlier SYNCED track of the same original so its
+// ID stays stable across re-syncs.
+func (s *Service) saveDerived(orig *domain.SubtitleTrack, path string) (*domain.SubtitleTrack, error) {
+	existing, err := s.store.ListSubtitleTracks(orig.MediaFileID)
+	if err != nil {
+		return nil, err
+	}
+
+	track := &domain.SubtitleTrack{
+		MediaFileID:   orig.MediaFileID,
+		Source:        domain.SubtitleSourceSynced,
+		ExternalPath:  &path,
+		Language:      orig.Language,
+		IsForced:      orig.IsForced,
+		IsSDH:         orig.IsSDH,
+		Format:        "vtt",
+		DerivedFromID: &orig.ID,
+	}
+
+	for _, ex := range existing {
+		if ex.Source == domain.SubtitleSourceSynced && ex.DerivedFromID != nil && *ex.DerivedFromID == orig.ID {
+			track.ID = ex.ID
+			return track, s.store.UpdateSubtitleTrack(track)
+		}
+	}
+
+	return track, s.store.CreateSubtitleTrack(track)
+}
@@ -0,0 +1,129 @@
+package subtitle
+
+import "time"
+
+// Interval is a span of detected speech.
+type Interval struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Fit is the timing correction Align found: retime from FPSFrom to FPSTo
+// (both 0 when the framerate already matches), then shift by Offset.
+type Fit struct {
+	Offset  time.Duration
+	FPSFrom float64
+	FPSTo   float64
+
+	// Score is cue time over speech minus cue time over silence, as a
+	// fraction of total cue time: 1 is a perfect fit, 0 no better than chance.
+	Score float64
+}
+
+// Apply retimes cues with the fit.
+func (f Fit) Apply(cues []Cue) []Cue {
+	return Shift(Retime(cues, f.FPSFrom, f.FPSTo), f.Offset)
+}
+
+const alignStep = 100 * time.Millisecond
+
+// Common PAL/film mix-ups tried besides "no retiming".
+var alignRates = [][2]float64{
+	{0, 0},
+	{25, 23.976}, {23.976, 25},
+	{24, 23.976}, {23.976, 24},
+	{25, 24}, {24, 25},
+}
+
+// A framerate change must beat the plain offset by this much to be picked,
+// so noise does not stretch a subtitle that only needed shifting.
+const alignRateMargin = 0.02
+
+// Align searches offsets within ±maxShift and the usual framerate mix-ups
+// for the timing that puts the most cue time over speech, in the spirit of
+// alass and ffsubsync.
+func Align(cues []Cue, speech []Interval, maxShift time.Duration) Fit {
+	if len(cues) == 0 || len(speech) == 0 {
+		return Fit{}
+	}
+
+	shift := int(maxShift / alignStep)
+
+	// Speech as a 0/1 signal in alignStep bins, padded by shift on both
+	// sides, with prefix sums so any window is O(1).
+	var end time.Duration
+	for _, s := range speech {
+		if s.End > end {
+			end = s.End
+		}
+	}
+	for _, c := range cues {
+		if c.End*11/10 > end {
+			end = c.End * 11 / 10 // leave room for stretched cues
+		}
+	}
+	n := int(end/alignStep) + 2*shift + 1
+	prefix := make([]int, n+1)
+	signal := make([]int, n)
+	for _, s := range speech {
+		for b := int(s.Start/alignStep) + shift; b < int(s.End/alignStep)+shift && b < n; b++ {
+			if b >= 0 {
+				signal[b] = 1
+			}
+		}
+	}
+	for i, v := range signal {
+		prefix[i+1] = prefix[i] + v
+	}
+	window := func(a, b int) int {
+		a, b = max(a, 0), min(b, n)
+		if a >= b {
+			return 0
+		}
+		return prefix[b] - prefix[a]
+	}
+
+	var (
+		best      Fit
+		bestScore float64
+		found     bool
+	)
+
+	for _, rate := range alignRates {
+		scaled := Retime(append([]Cue(nil), cues...), rate[0], rate[1])
+
+		total := 0
+		for _, c := range scaled {
+			total += int(c.End/alignStep) - int(c.Start/alignStep)
+		}
+		if total == 0 {
+			continue
+		}
+
+		for off := -shift; off <= shift; off++ {
+			speechBins := 0
+			for _, c := range scaled {
+				a := int(c.Start/alignStep) + shift + off
+				b := int(c.End/alignStep) + shift + off
+				speechBins += window(a, b)
+			}
+			score := float64(2*speechBins-total) / float64(total)
+
+			ranked := score
+			if rate[0] != 0 {
+				ranked -= alignRateMargin
+			}
+			if !found || ranked > bestScore {
+				found, bestScore = true, ranked
+				best = Fit{
+					Offset:  time.Duration(off) * alignStep,
+					FPSFrom: rate[0],
+					FPSTo:   rate[1],
+					Score:   score,
+				}
+			}
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,246 @@
+package subtitle
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type assStyle struct {
+	bold, italic, underline bool
+	align                   string
+}
+
+// ParseASS parses ASS and SSA scripts. Styling is reduced to what WebVTT
+// can express without a stylesheet: bold, italic, underline and top/middle
+// placement. Fonts, colours, karaoke, positioning and drawings are dropped.
+func ParseASS(text string) ([]Cue, error) {
+	var (
+		section     string
+		styleFields []string
+		eventFields []string
+		styles      = map[string]assStyle{}
+		cues        []Cue
+	)
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(line)
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.Contains(section, "styles") && key == "Format":
+			styleFields = splitFields(value, -1)
+
+		case strings.Contains(section, "styles") && key == "Style":
+			name, st := parseASSStyle(styleFields, splitFields(value, len(styleFields)))
+			styles[name] = st
+
+		case section == "[events]" && key == "Format":
+			eventFields = splitFields(value, -1)
+
+		case section == "[events]" && key == "Dialogue":
+			if eventFields == nil {
+				eventFields = []string{"Layer", "Start", "End", "Style", "Name", "MarginL", "MarginR", "MarginV", "Effect", "Text"}
+			}
+			if cue, ok := parseASSDialogue(eventFields, splitFields(value, len(eventFields)), styles); ok {
+				cues = append(cues, cue)
+			}
+		}
+	}
+
+	// Events are not required to be in order; signs often come last.
+	sort.SliceStable(cues, func(i, j int) bool { return cues[i].Start < cues[j].Start })
+
+	return cues, nil
+}
+
+// splitFields splits a comma separated line into at most n fields, so the
+// final Text field keeps its commas.
+func splitFields(s string, n int) []string {
+	parts := strings.SplitN(s, ",", n)
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+func field(names, values []string, name string) string {
+	for i, n := range names {
+		if strings.EqualFold(n, name) && i < len(values) {
+			return values[i]
+		}
+	}
+	return ""
+}
+
+func parseASSStyle(names, values []string) (string, assStyle) {
+	// -1 is true in ASS, 1 is used by some SSA writers.
+	flag := func(name string) bool {
+		v := field(names, values, name)
+		return v == "-1" || v == "1"
+	}
+
+	st := assStyle{
+		bold:      flag("Bold"),
+		italic:    flag("Italic"),
+		underline: flag("Underline"),
+	}
+
+	if n, err := strconv.Atoi(field(names, values, "Alignment")); err == nil {
+		// SSA styles use the legacy numbering, ASS styles the numpad one.
+		numpad := !containsFold(names, "AlphaLevel")
+		st.align = alignSettings(n, numpad)
+	}
+
+	return field(names, values, "Name"), st
+}
+
+func containsFold(list []string, s string) bool {
+	for _, x := range list {
+		if strings.EqualFold(x, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseASSDialogue(names, values []string, styles map[string]assStyle) (Cue, bool) {
+	// "H:MM:SS.cc" reads like any other timestamp.
+	start, ok1 := parseTimestamp(field(names, values, "Start"))
+	end, ok2 := parseTimestamp(field(names, values, "End"))
+	if !ok1 || !ok2 {
+		return Cue{}, false
+	}
+
+	style := styles[strings.TrimPrefix(field(names, values, "Style"), "*")]
+	text, settings := convertASSText(field(names, values, "Text"), style)
+	if text == "" {
+		return Cue{}, false
+	}
+
+	return Cue{Start: start, End: end, Text: text, Settings: settings}, true
+}
+
+// convertASSText applies override blocks ({\i1}, {\b0}, {\an8}, ...) and
+// line breaks to the dialogue text.
+func convertASSText(s string, style assStyle) (string, string) {
+	var (
+		b        strings.Builder
+		settings = style.align
+		drawing  bool
+		open     = map[string]bool{"b": style.bold, "i": style.italic, "u": style.underline}
+		order    = []string{"b", "i", "u"}
+	)
+
+	for _, t := range order {
+		if open[t] {
+			b.WriteString("<" + t + ">")
+		}
+	}
+
+	set := func(tag string, on bool) {
+		if open[tag] == on {
+			return
+		}
+		open[tag] = on
+		if on {
+			b.WriteString("<" + tag + ">")
+		} else {
+			b.WriteString("</" + tag + ">")
+		}
+	}
+
+	for len(s) > 0 {
+		if s[0] == '{' {
+			end := strings.IndexByte(s, '}')
+			if end < 0 {
+				s = s[1:]
+				continue
+			}
+			block := s[1:end]
+			s = s[end+1:]
+
+			for _, tag := range strings.Split(block, `\`)[1:] {
+				switch {
+				case tag == "i1" || tag == "i0":
+					set("i", tag == "i1")
+				case tag == "u1" || tag == "u0":
+					set("u", tag == "u1")
+				case tag == "b0":
+					set("b", false)
+				case len(tag) > 1 && tag[0] == 'b' && isDigits(tag[1:]):
+					set("b", true) // \b1 or a weight such as \b700
+				case strings.HasPrefix(tag, "an") && isDigits(tag[2:]):
+					n, _ := strconv.Atoi(tag[2:])
+					settings = alignSettings(n, true)
+				case len(tag) > 1 && tag[0] == 'a' && isDigits(tag[1:]):
+					n, _ := strconv.Atoi(tag[1:])
+					settings = alignSettings(n, false)
+				case len(tag) > 1 && tag[0] == 'p' && isDigits(tag[1:]):
+					drawing = tag != "p0"
+				case tag == "r":
+					set("b", style.bold)
+					set("i", style.italic)
+					set("u", style.underline)
+				}
+			}
+			continue
+		}
+
+		// Plain text up to the next override block.
+		next := strings.IndexByte(s, '{')
+		if next < 0 {
+			next = len(s)
+		}
+		chunk := s[:next]
+		s = s[next:]
+
+		if drawing {
+			continue
+		}
+
+		chunk = strings.ReplaceAll(chunk, `\N`, "\n")
+		chunk = strings.ReplaceAll(chunk, `\n`, "\n")
+		chunk = strings.ReplaceAll(chunk, `\h`, " ")
+		b.WriteString(escapeText(chunk))
+	}
+
+	for i := len(order) - 1; i >= 0; i-- {
+		if open[order[i]] {
+			b.WriteString("</" + order[i] + ">")
+		}
+	}
+
+	text := strings.TrimSpace(b.String())
+	if stripTags(text) == "" {
+		return "", ""
+	}
+	return text, settings
+}
+
+func stripTags(s string) string {
+	return strings.TrimSpace(reHTMLTag.ReplaceAllString(s, ""))
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
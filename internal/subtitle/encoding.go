@@ -0,0 +1,100 @@
+package subtitle
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Decode converts subtitle bytes to a UTF-8 string. It honours UTF-8 and
+// UTF-16 byte order marks, recognises BOM-less UTF-16 by its zero bytes and
+// falls back to Windows-1252 for anything that is not valid UTF-8, which is
+// what most old European .srt files are.
+func Decode(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:])
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return decodeUTF16(data[2:], false)
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return decodeUTF16(data[2:], true)
+	}
+
+	if be, ok := looksUTF16(data); ok {
+		return decodeUTF16(data, be)
+	}
+
+	if utf8.Valid(data) {
+		return string(data)
+	}
+
+	return decodeWindows1252(data)
+}
+
+// looksUTF16 checks whether most even or most odd bytes are zero, which is
+// what mostly-ASCII text looks like in UTF-16.
+func looksUTF16(data []byte) (bigEndian bool, ok bool) {
+	n := len(data)
+	if n > 1024 {
+		n = 1024
+	}
+	n &^= 1
+	if n < 4 {
+		return false, false
+	}
+
+	var even, odd int
+	for i := 0; i < n; i += 2 {
+		if data[i] == 0 {
+			even++
+		}
+		if data[i+1] == 0 {
+			odd++
+		}
+	}
+
+	pairs := n / 2
+	switch {
+	case odd*10 >= pairs*6 && even*10 < pairs:
+		return false, true
+	case even*10 >= pairs*6 && odd*10 < pairs:
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		} else {
+			units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// Windows-1252 differs from Latin-1 only in 0x80–0x9F.
+var cp1252 = [32]rune{
+	'€', 0xFFFD, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0xFFFD, 'Ž', 0xFFFD,
+	0xFFFD, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0xFFFD, 'ž', 'Ÿ',
+}
+
+func decodeWindows1252(data []byte) string {
+	var b strings.Builder
+	b.Grow(len(data))
+	for _, c := range data {
+		switch {
+		case c < 0x80:
+			b.WriteByte(c)
+		case c < 0xA0:
+			b.WriteRune(cp1252[c-0x80])
+		default:
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,100 @@
+package subtitle
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMicroDVDFrameRate is assumed when a .sub file does not declare one.
+const DefaultMicroDVDFrameRate = 23.976
+
+var (
+	reMicroDVD     = regexp.MustCompile(`^\{(\d+)\}\{(\d*)\}(.*)$`)
+	reMicroDVDCode = regexp.MustCompile(`\{([a-zA-Z]):([^}]*)\}`)
+)
+
+// ParseMicroDVD parses frame based "{start}{end}text" files. A first line
+// like "{1}{1}25.000" sets the frame rate; otherwise fps is used, or
+// DefaultMicroDVDFrameRate when fps is 0.
+func ParseMicroDVD(text string, fps float64) ([]Cue, error) {
+	var cues []Cue
+
+	for i, line := range strings.Split(text, "\n") {
+		m := reMicroDVD.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+
+		if i == 0 && m[1] == m[2] {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(m[3]), 64); err == nil && f > 0 {
+				fps = f
+				continue
+			}
+		}
+		if fps <= 0 {
+			fps = DefaultMicroDVDFrameRate
+		}
+
+		start, _ := strconv.ParseInt(m[1], 10, 64)
+		end, err := strconv.ParseInt(m[2], 10, 64)
+		if err != nil || end < start {
+			end = start + int64(fps*2) // no end frame: show for ~2s
+		}
+
+		text := convertMicroDVDText(m[3])
+		if text == "" {
+			continue
+		}
+
+		cues = append(cues, Cue{
+			Start: frameTime(start, fps),
+			End:   frameTime(end, fps),
+			Text:  text,
+		})
+	}
+
+	return cues, nil
+}
+
+func frameTime(frame int64, fps float64) time.Duration {
+	return time.Duration(float64(frame) / fps * float64(time.Second))
+}
+
+// convertMicroDVDText handles "|" line breaks, {y:i}/{y:b}/{y:u} style codes
+// and the "/" italic line prefix; other control codes are dropped.
+func convertMicroDVDText(s string) string {
+	var wrap []string
+	for _, m := range reMicroDVDCode.FindAllStringSubmatch(s, -1) {
+		if strings.ToLower(m[1]) != "y" {
+			continue
+		}
+		for _, c := range strings.Split(strings.ToLower(m[2]), ",") {
+			if c == "i" || c == "b" || c == "u" {
+				wrap = append(wrap, c)
+			}
+		}
+	}
+	s = reMicroDVDCode.ReplaceAllString(s, "")
+
+	lines := strings.Split(s, "|")
+	for i, l := range lines {
+		l = strings.TrimSpace(l)
+		if strings.HasPrefix(l, "/") {
+			l = "<i>" + escapeText(strings.TrimSpace(l[1:])) + "</i>"
+		} else {
+			l = escapeText(l)
+		}
+		lines[i] = l
+	}
+
+	text := strings.TrimSpace(strings.Join(lines, "\n"))
+	if text == "" {
+		return ""
+	}
+	for i := len(wrap) - 1; i >= 0; i-- {
+		text = "<" + wrap[i] + ">" + text + "</" + wrap[i] + ">"
+	}
+	return text
+}
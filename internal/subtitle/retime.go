@@ -0,0 +1,40 @@
+package subtitle
+
+import "time"
+
+// Shift moves every cue by offset. Cues pushed entirely before zero are
+// dropped; cues straddling zero are clipped.
+func Shift(cues []Cue, offset time.Duration) []Cue {
+	if offset == 0 {
+		return cues
+	}
+
+	out := cues[:0]
+	for _, c := range cues {
+		c.Start += offset
+		c.End += offset
+		if c.End <= 0 {
+			continue
+		}
+		if c.Start < 0 {
+			c.Start = 0
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// Retime rescales cues written for a video at fromFPS so they line up with
+// the same video at toFPS, e.g. a 25fps PAL subtitle on a 23.976 release.
+func Retime(cues []Cue, fromFPS, toFPS float64) []Cue {
+	if fromFPS <= 0 || toFPS <= 0 || fromFPS == toFPS {
+		return cues
+	}
+
+	ratio := fromFPS / toFPS
+	for i := range cues {
+		cues[i].Start = time.Duration(float64(cues[i].Start) * ratio)
+		cues[i].End = time.Duration(float64(cues[i].End) * ratio)
+	}
+	return cues
+}
@@ -0,0 +1,147 @@
+package subtitle
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	reTiming = regexp.MustCompile(`^\s*(\S+)\s*-->\s*(\S+)(.*)$`)
+	reTime   = regexp.MustCompile(`^(?:(\d+):)?(\d{1,2}):(\d{1,2})(?:[,.](\d{1,3}))?$`)
+	reIndex  = regexp.MustCompile(`^\s*\d+\s*$`)
+
+	reHTMLTag  = regexp.MustCompile(`<(/?)([a-zA-Z]+)[^>]*>`)
+	reSRTBrace = regexp.MustCompile(`\{\\[^}]*\}`)
+	reSRTAlign = regexp.MustCompile(`\{\\an?(\d+)\}`)
+)
+
+// ParseSRT parses SubRip text. Blocks missing their blank separator line and
+// stray index lines are tolerated.
+func ParseSRT(text string) ([]Cue, error) {
+	lines := strings.Split(text, "\n")
+
+	var (
+		cues []Cue
+		cur  *Cue
+		body []string
+	)
+
+	flush := func() {
+		if cur != nil {
+			cur.Text, cur.Settings = cleanSRTText(strings.Join(body, "\n"))
+			if cur.Text != "" {
+				cues = append(cues, *cur)
+			}
+		}
+		cur, body = nil, nil
+	}
+
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t")
+
+		if m := reTiming.FindStringSubmatch(line); m != nil {
+			start, ok1 := parseTimestamp(m[1])
+			end, ok2 := parseTimestamp(m[2])
+			if ok1 && ok2 {
+				flush()
+				cur = &Cue{Start: start, End: end}
+				continue
+			}
+		}
+
+		if cur == nil {
+			continue
+		}
+
+		if line == "" {
+			flush()
+			continue
+		}
+
+		// An index line directly followed by a timing line starts the next
+		// cue even without a blank line in between.
+		if reIndex.MatchString(line) && i+1 < len(lines) && reTiming.MatchString(lines[i+1]) {
+			flush()
+			continue
+		}
+
+		body = append(body, line)
+	}
+	flush()
+
+	return cues, nil
+}
+
+// cleanSRTText keeps <i>, <b> and <u>, drops other HTML-ish tags such as
+// <font>, turns {\an8} into a WebVTT setting and drops other ASS overrides
+// that some SRT files carry.
+func cleanSRTText(s string) (string, string) {
+	var settings string
+	if m := reSRTAlign.FindStringSubmatch(s); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			settings = alignSettings(n, strings.HasPrefix(m[0], `{\an`))
+		}
+	}
+	s = reSRTBrace.ReplaceAllString(s, "")
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range reHTMLTag.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(escapeText(s[last:loc[0]]))
+		last = loc[1]
+
+		name := strings.ToLower(s[loc[4]:loc[5]])
+		if name == "i" || name == "b" || name == "u" {
+			b.WriteString("<" + s[loc[2]:loc[3]] + name + ">")
+		}
+	}
+	b.WriteString(escapeText(s[last:]))
+
+	return strings.TrimSpace(b.String()), settings
+}
+
+// parseTimestamp reads "01:02:03,456", "01:02:03.456" and "02:03.456".
+func parseTimestamp(s string) (time.Duration, bool) {
+	m := reTime.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, false
+	}
+
+	h, _ := strconv.Atoi(m[1])
+	min, _ := strconv.Atoi(m[2])
+	sec, _ := strconv.Atoi(m[3])
+
+	// Fractions are digits after the separator: ".5" is 500ms, ".05" 50ms.
+	frac := m[4]
+	for len(frac) < 3 {
+		frac += "0"
+	}
+	ms, _ := strconv.Atoi(frac)
+
+	d := time.Duration(h)*time.Hour +
+		time.Duration(min)*time.Minute +
+		time.Duration(sec)*time.Second +
+		time.Duration(ms)*time.Millisecond
+	return d, true
+}
+
+// alignSettings turns an ASS alignment into WebVTT cue settings. Numpad
+// style (\an, ASS) puts 7–9 at the top and 4–6 in the middle; legacy SSA
+// (\a) uses 5–7 for top and 9–11 for middle. Bottom is the WebVTT default.
+func alignSettings(n int, numpad bool) string {
+	top, middle := n >= 7 && n <= 9, n >= 4 && n <= 6
+	if !numpad {
+		top, middle = n >= 5 && n <= 7, n >= 9 && n <= 11
+	}
+
+	switch {
+	case top:
+		return "line:0"
+	case middle:
+		return "line:50%"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,76 @@
+// Package subtitle parses text subtitle formats into cues and renders them
+// as WebVTT, which is the only format browsers display natively.
+package subtitle
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Cue is one timed piece of text. Text may contain <i>, <b> and <u> tags;
+// everything else is plain text with &, < and > escaped as in WebVTT.
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+
+	// Settings are WebVTT cue settings, e.g. "line:0" for top aligned text.
+	Settings string
+}
+
+// Format is a text subtitle format this package can read.
+type Format string
+
+const (
+	FormatSRT      Format = "srt"
+	FormatASS      Format = "ass"
+	FormatMicroDVD Format = "microdvd"
+	FormatVTT      Format = "vtt"
+)
+
+// FormatFromPath picks the parser from a file extension. It reports false
+// for bitmap formats and anything unknown.
+func FormatFromPath(path string) (Format, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".srt":
+		return FormatSRT, true
+	case ".ass", ".ssa":
+		return FormatASS, true
+	case ".sub":
+		return FormatMicroDVD, true
+	case ".vtt":
+		return FormatVTT, true
+	default:
+		return "", false
+	}
+}
+
+// Parse decodes raw file bytes (see Decode) and parses them as format.
+func Parse(data []byte, format Format) ([]Cue, error) {
+	text := Decode(data)
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	switch format {
+	case FormatSRT:
+		return ParseSRT(text)
+	case FormatASS:
+		return ParseASS(text)
+	case FormatMicroDVD:
+		return ParseMicroDVD(text, 0)
+	case FormatVTT:
+		return ParseVTT(text)
+	default:
+		return nil, fmt.Errorf("subtitle: unsupported format %q", format)
+	}
+}
+
+// escapeText escapes plain text for use in a cue.
+func escapeText(s string) string {
+	s = strings.ReplaceAll(s, "&", "&amp;")
+	s = strings.ReplaceAll(s, "<", "&lt;")
+	s = strings.ReplaceAll(s, ">", "&gt;")
+	return s
+}
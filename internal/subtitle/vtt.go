@@ -0,0 +1,103 @@
+package subtitle
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ParseVTT parses WebVTT so it can be retimed like the other formats. Cue
+// text is kept as written; NOTE, STYLE and REGION blocks are skipped.
+func ParseVTT(text string) ([]Cue, error) {
+	blocks := strings.Split(text, "\n\n")
+	if len(blocks) == 0 || !strings.HasPrefix(blocks[0], "WEBVTT") {
+		return nil, fmt.Errorf("subtitle: missing WEBVTT header")
+	}
+
+	var cues []Cue
+	for _, block := range blocks[1:] {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+
+		// Optional cue identifier before the timing line.
+		if len(lines) > 1 && !strings.Contains(lines[0], "-->") {
+			lines = lines[1:]
+		}
+		if len(lines) < 2 {
+			continue
+		}
+
+		m := reTiming.FindStringSubmatch(lines[0])
+		if m == nil {
+			continue
+		}
+		start, ok1 := parseTimestamp(m[1])
+		end, ok2 := parseTimestamp(m[2])
+		if !ok1 || !ok2 {
+			continue
+		}
+
+		cues = append(cues, Cue{
+			Start:    start,
+			End:      end,
+			Text:     strings.Join(lines[1:], "\n"),
+			Settings: strings.TrimSpace(m[3]),
+		})
+	}
+
+	return cues, nil
+}
+
+// WriteVTT renders cues as a WebVTT file.
+func WriteVTT(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+
+	if _, err := bw.WriteString("WEBVTT\n"); err != nil {
+		return err
+	}
+
+	for i, c := range cues {
+		if c.End < c.Start {
+			c.End = c.Start
+		}
+
+		line := fmt.Sprintf("\n%d\n%s --> %s", i+1, FormatTimestamp(c.Start), FormatTimestamp(c.End))
+		if c.Settings != "" {
+			line += " " + c.Settings
+		}
+
+		if _, err := fmt.Fprintf(bw, "%s\n%s\n", line, cueText(c.Text)); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// cueText makes text safe as a cue payload, which ends at the first blank
+// line and may not contain "-->". Text from outside, like chapter titles
+// from file tags, may have both.
+func cueText(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	text = strings.ReplaceAll(text, "-->", "->")
+
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	for _, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			kept = append(kept, l)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// FormatTimestamp formats a duration as a WebVTT timestamp (hh:mm:ss.ttt).
+func FormatTimestamp(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
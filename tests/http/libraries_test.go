@@ -0,0 +1,107 @@
+package http_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	apphttp "github.com/bastianvv/vio/internal/http"
+	"github.com/bastianvv/vio/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// librariesRouter serves the library create and update endpoints from a
+// memory store.
+func librariesRouter(s store.Store) http.Handler {
+	h := apphttp.NewLibrariesHandler(s, nil, nil, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Post("/api/libraries", h.CreateLibrary)
+	r.Put("/api/libraries/{id}", h.UpdateLibrary)
+	return r
+}
+
+func send(t *testing.T, h http.Handler, method, target string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	b, err := json.Marshal(body)
+	must(t, err)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, bytes.NewReader(b)))
+	return w
+}
+
+func TestLibraryRootsMustNotOverlap(t *testing.T) {
+	media := t.TempDir()
+	for _, dir := range []string{"a/sub", "ab", "b/x", "c"} {
+		must(t, os.MkdirAll(filepath.Join(media, dir), 0o755))
+	}
+	root := func(dir string) string { return filepath.Join(media, dir) }
+
+	s := store.NewMemoryStore()
+	h := librariesRouter(s)
+
+	w := send(t, h, http.MethodPost, "/api/libraries", apphttp.CreateLibraryRequest{
+		Name: "A", Type: "movies", Roots: []string{root("a")},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create A: %d %s", w.Code, w.Body)
+	}
+
+	tests := []struct {
+		name  string
+		roots []string
+		want  int
+	}{
+		{"inside another library", []string{root("a/sub")}, http.StatusConflict},
+		{"around another library", []string{media}, http.StatusConflict},
+		{"same as another library", []string{root("a") + "/"}, http.StatusConflict},
+		{"nested in the request", []string{root("b"), root("b/x")}, http.StatusConflict},
+		{"nesting in the request", []string{root("b/x"), root("b")}, http.StatusConflict},
+		{"duplicate in the request", []string{root("b"), root("b")}, http.StatusBadRequest},
+		{"sharing a name prefix", []string{root("ab")}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := send(t, h, http.MethodPost, "/api/libraries", apphttp.CreateLibraryRequest{
+				Name: tt.name, Type: "movies", Roots: tt.roots,
+			})
+			if w.Code != tt.want {
+				t.Errorf("create with %q = %d %s, want %d", tt.roots, w.Code, w.Body, tt.want)
+			}
+		})
+	}
+
+	w = send(t, h, http.MethodPost, "/api/libraries", apphttp.CreateLibraryRequest{
+		Name: "B", Type: "movies", Roots: []string{root("b")},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create B: %d %s", w.Code, w.Body)
+	}
+	var b struct{ ID int64 }
+	must(t, json.Unmarshal(w.Body.Bytes(), &b))
+	update := "/api/libraries/" + strconv.FormatInt(b.ID, 10)
+
+	w = send(t, h, http.MethodPut, update, apphttp.UpdateLibraryRequest{Roots: []string{root("b"), root("a/sub")}})
+	if w.Code != http.StatusConflict {
+		t.Errorf("update B into A = %d %s, want 409", w.Code, w.Body)
+	}
+	w = send(t, h, http.MethodPut, update, apphttp.UpdateLibraryRequest{Roots: []string{root("b"), root("b/x")}})
+	if w.Code != http.StatusConflict {
+		t.Errorf("update B with a nested root = %d %s, want 409", w.Code, w.Body)
+	}
+	w = send(t, h, http.MethodPut, update, apphttp.UpdateLibraryRequest{Roots: []string{root("b"), root("c")}})
+	if w.Code != http.StatusOK {
+		t.Errorf("update B with a second root = %d %s, want 200", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,143 @@
+package language_test
+
+import (
+	"testing"
+
+	"github.com/bastianvv/vio/internal/language"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		// ISO 639-1, 639-2/B and 639-2/T all land on the 639-1 code.
+		{"en", "en", true},
+		{"eng", "en", true},
+		{"ger", "de", true},
+		{"deu", "de", true},
+		{"DE", "de", true},
+		{"fre", "fr", true},
+		{"fra", "fr", true},
+		{"chi", "zh", true},
+		{"zho", "zh", true},
+		{"cze", "cs", true},
+		{"ces", "cs", true},
+		{"fil", "fil", true},
+
+		// Names, native names and legacy codes.
+		{"English", "en", true},
+		{" german ", "de", true},
+		{"Norwegian Bokmål", "nb", true},
+		{"Deutsch", "de", true},
+		{"日本語", "ja", true},
+		{"iw", "he", true},
+
+		// Regions, scripts and UN M.49 areas, in any case or separator.
+		{"pt-BR", "pt-BR", true},
+		{"pt_br", "pt-BR", true},
+		{"por-br", "pt-BR", true},
+		{"PT-br", "pt-BR", true},
+		{"brazilian", "pt-BR", true},
+		{"pob", "pt-BR", true},
+		{"es-419", "es-419", true},
+		{"latino", "es-419", true},
+		{"zh-hans", "zh-Hans", true},
+		{"chs", "zh-Hans", true},
+		{"zh-hant-tw", "zh-Hant-TW", true},
+
+		// Not languages.
+		{"", "", false},
+		{"und", "", false},
+		{"Unknown", "", false},
+		{"forced", "", false},
+		{"sdh", "", false},
+		{"en-forced", "", false},
+		{"qya", "", false},
+		{"en-1", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := language.Lookup(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Lookup(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"eng", "en"},
+		{"pt_BR", "pt-BR"},
+		{"English", "en"},
+
+		// Unknown but code-shaped tags are kept, lower-cased.
+		{"QYA", "qya"},
+		{"tlh", "tlh"},
+
+		// Undetermined or not code-shaped become "".
+		{"und", ""},
+		{"zxx", ""},
+		{"x", ""},
+		{"abcd", ""},
+		{"English (SDH)", ""},
+		{"e1", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := language.Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestName(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"en", "English"},
+		{"pt-BR", "Portuguese (Brazil)"},
+		{"es-419", "Spanish (Latin America)"},
+		{"zh-Hant-TW", "Chinese (Traditional, Taiwan)"},
+		{"fr-XK", "French (XK)"},
+		{"qya", "qya"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := language.Name(tt.in); got != tt.want {
+			t.Errorf("Name(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		tag, filter string
+		want        bool
+	}{
+		// A bare filter matches every region; a regional one only its own.
+		{"pt-BR", "pt", true},
+		{"pt-BR", "por", true},
+		{"pt-BR", "Portuguese", true},
+		{"pt", "pt", true},
+		{"pt-BR", "pt-BR", true},
+		{"pt-BR", "pt_br", true},
+		{"pt", "pt-BR", false},
+		{"pt-PT", "pt-BR", false},
+		{"es-419", "latino", true},
+
+		// Stored tags are normalized too, for rows from older scans.
+		{"eng", "en", true},
+		{"English", "eng", true},
+		{"ger", "deu", true},
+		{"pt_br", "pt-BR", true},
+
+		{"en", "de", false},
+		{"", "en", false},
+		{"en", "", false},
+		{"und", "und", false},
+	}
+	for _, tt := range tests {
+		if got := language.Match(tt.tag, tt.filter); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.tag, tt.filter, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package media_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bastianvv/vio/internal/domain"
+	"github.com/bastianvv/vio/internal/media"
+	"github.com/bastianvv/vio/internal/store"
+)
+
+// fakeFFProbe puts an ffprobe on PATH that reports every file as a
+// one-minute 1080p H.264 video.
+func fakeFFProbe(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	script := `#!/bin/sh
+cat <<JSON
+{"format":{"duration":"60.0","size":"100"},
+"streams":[{"index":0,"codec_type":"video","codec_name":"h264","width":1920,"height":1080}]}
+JSON
+`
+	must(t, os.WriteFile(filepath.Join(dir, "ffprobe"), []byte(script), 0o755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// touch creates an empty file under root, with its folders.
+func touch(t *testing.T, root, rel string) {
+	t.Helper()
+	path := filepath.Join(root, rel)
+	must(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	must(t, os.WriteFile(path, nil, 0o644))
+}
+
+func scan(t *testing.T, s store.Store, lib *domain.Library) *media.ScanResult {
+	t.Helper()
+	res, err := media.NewScanner(s, 0, 0).ScanLibrary(lib, media.ScanModeIncremental)
+	must(t, err)
+	for _, err := range res.Errors {
+		t.Errorf("scan: %v", err)
+	}
+	return res
+}
+
+func seriesLibrary(t *testing.T, s store.Store) *domain.Library {
+	t.Helper()
+	lib := &domain.Library{Name: "Shows", Type: domain.LibraryTypeSeries, Path: t.TempDir()}
+	must(t, s.CreateLibrary(lib))
+	return lib
+}
+
+func TestScanSeriesFolders(t *testing.T) {
+	fakeFFProbe(t)
+
+	tests := []struct {
+		folder string
+		title  string
+		year   int
+		tmdbID string
+	}{
+		{"Doctor Who (2005)", "Doctor Who", 2005, ""},
+		{"Doctor Who (2005) {tmdb-57243}", "Doctor Who", 2005, "57243"},
+		{"Doctor.Who.2005", "Doctor Who", 2005, ""},
+		{"Doctor Who", "Doctor Who", 0, ""},
+		{"1923", "1923", 0, ""},
+		{"1923 (2022)", "1923", 2022, ""},
+
+		// A number ending a spaced title is part of it.
+		{"Space 1999", "Space 1999", 0, ""},
+		{"Space 1999 (1975)", "Space 1999", 1975, ""},
+		{"Blade Runner 2049", "Blade Runner 2049", 0, ""},
+		{"Doctor Who 2005", "Doctor Who 2005", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.folder, func(t *testing.T) {
+			s := store.NewMemoryStore()
+			lib := seriesLibrary(t, s)
+			touch(t, lib.Path, filepath.Join(tt.folder, "Season 01", "Show S01E01.mkv"))
+
+			if res := scan(t, s, lib); res.SeriesAdded != 1 {
+				t.Fatalf("SeriesAdded = %d, want 1", res.SeriesAdded)
+			}
+			all, err := s.ListSeries()
+			must(t, err)
+			sr := all[0]
+			if sr.Title != tt.title || sr.Year != tt.year {
+				t.Errorf("series = %q (%d), want %q (%d)", sr.Title, sr.Year, tt.title, tt.year)
+			}
+			if got := ""; sr.TMDBID != nil {
+				got = *sr.TMDBID
+				if got != tt.tmdbID {
+					t.Errorf("TMDB ID = %q, want %q", got, tt.tmdbID)
+				}
+			} else if tt.tmdbID != "" {
+				t.Errorf("TMDB ID unset, want %q", tt.tmdbID)
+			}
+		})
+	}
+}
+
+// Scans before folder years were parsed stored "Doctor Who (2005)" as the
+// title; the next scan must find that series, not add another.
+func TestScanKeepsLegacySeries(t *testing.T) {
+	fakeFFProbe(t)
+
+	s := store.NewMemoryStore()
+	lib := seriesLibrary(t, s)
+	legacy := &domain.Series{LibraryID: lib.ID, Title: "Doctor Who (2005) {tmdb-57243}"}
+	must(t, s.CreateSeries(legacy))
+
+	touch(t, lib.Path, filepath.Join("Doctor Who (2005) {tmdb-57243}", "Season 01", "Doctor Who S01E01.mkv"))
+	if res := scan(t, s, lib); res.SeriesAdded != 0 || res.EpisodesAdded != 1 {
+		t.Errorf("scan added %d series, %d episodes, want 0 and 1", res.SeriesAdded, res.EpisodesAdded)
+	}
+
+	all, err := s.ListSeries()
+	must(t, err)
+	if len(all) != 1 || all[0].ID != legacy.ID {
+		t.Fatalf("series = %+v, want only the legacy one", all)
+	}
+	if all[0].TMDBID == nil || *all[0].TMDBID != "57243" {
+		t.Errorf("TMDB ID = %v, want the folder's tag bound", all[0].TMDBID)
+	}
+}
+
+// Episodes TMDB named before titles recorded their source must keep those
+// titles once migration 4 has run, not get filename titles back on a scan.
+func TestScanKeepsTMDBTitlesFromBeforeTitleSources(t *testing.T) {
+	fakeFFProbe(t)
+	path := filepath.Join(t.TempDir(), "vio.db")
+
+	s, err := store.NewSQLiteStore(path)
+	must(t, err)
+	must(t, s.Migrate())
+	lib := seriesLibrary(t, s)
+	sr := &domain.Series{LibraryID: lib.ID, Title: "Doctor Who", Year: 2005}
+	must(t, s.CreateSeries(sr))
+	se := &domain.Season{SeriesID: sr.ID, Number: 1}
+	must(t, s.CreateSeason(se))
+	tmdbID := "11"
+	ep := &domain.Episode{SeasonID: se.ID, Number: 1, Title: "Rose", TMDBID: &tmdbID}
+	must(t, s.CreateEpisode(ep))
+	must(t, s.Close())
+
+	db, err := sql.Open("sqlite3", path)
+	must(t, err)
+	_, err = db.Exec(`DELETE FROM schema_migrations WHERE version >= 4`)
+	_ = db.Close()
+	must(t, err)
+
+	s, err = store.NewSQLiteStore(path)
+	must(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	must(t, s.Migrate())
+
+	touch(t, lib.Path, filepath.Join("Doctor Who (2005)", "Season 01", "Doctor Who S01E01 Pilot.mkv"))
+	scan(t, s, lib)
+
+	got, err := s.GetEpisode(ep.ID)
+	must(t, err)
+	if got.Title != "Rose" || got.TitleSource != domain.TitleSourceTMDB {
+		t.Errorf("episode = %q (%s), want the TMDB title kept", got.Title, got.TitleSource)
+	}
+}
+
+// An empty root is scanned, so its files go missing until they are back;
+// a root that is gone is offline, and its files are left alone.
+func TestScanRootAvailability(t *testing.T) {
+	fakeFFProbe(t)
+
+	s := store.NewMemoryStore()
+	disk1, disk2, disk3 := t.TempDir(), filepath.Join(t.TempDir(), "disk2"), t.TempDir()
+	lib := &domain.Library{Name: "Movies", Type: domain.LibraryTypeMovies, Path: disk1, Roots: []string{disk1, disk2, disk3}}
+	must(t, s.CreateLibrary(lib))
+	heat := filepath.Join(disk1, "Heat (1995)", "Heat (1995).mkv")
+	alien := filepath.Join(disk2, "Alien (1979)", "Alien (1979).mkv")
+	touch(t, disk1, "Heat (1995)/Heat (1995).mkv")
+	touch(t, disk2, "Alien (1979)/Alien (1979).mkv")
+	touch(t, disk3, "Ran (1985)/Ran (1985).mkv")
+	scan(t, s, lib)
+
+	missing := func(path string) bool {
+		t.Helper()
+		mf, err := s.GetMediaFileByPath(path)
+		must(t, err)
+		if mf == nil {
+			t.Fatalf("no media file for %s", path)
+		}
+		return mf.IsMissing
+	}
+	rescan := func() *media.ScanResult {
+		t.Helper()
+		res, err := media.NewScanner(s, 0, 0).ScanLibrary(lib, media.ScanModeIncremental)
+		must(t, err)
+		if len(res.OfflineRoots) != 1 || res.OfflineRoots[0] != disk2 {
+			t.Errorf("OfflineRoots = %q, want only %s", res.OfflineRoots, disk2)
+		}
+		return res
+	}
+
+	// disk1 left empty, disk2 gone.
+	moved := filepath.Join(t.TempDir(), "Heat (1995)")
+	must(t, os.Rename(filepath.Dir(heat), moved))
+	must(t, os.RemoveAll(disk2))
+	rescan()
+	if !missing(heat) {
+		t.Error("file of the empty root not marked missing")
+	}
+	if missing(alien) {
+		t.Error("file of the offline root marked missing")
+	}
+
+	must(t, os.Rename(moved, filepath.Dir(heat)))
+	rescan()
+	if missing(heat) {
+		t.Error("file not restored once its root is filled again")
+	}
+}
+
+func TestRescanReprobesChangedFiles(t *testing.T) {
+	fakeFFProbe(t)
+
+	s := store.NewMemoryStore()
+	lib := &domain.Library{Name: "Movies", Type: domain.LibraryTypeMovies, Path: t.TempDir()}
+	must(t, s.CreateLibrary(lib))
+	heat := filepath.Join(lib.Path, "Heat (1995)", "Heat (1995).mkv")
+	ran := filepath.Join(lib.Path, "Ran (1985)", "Ran (1985).mkv")
+	touch(t, lib.Path, "Heat (1995)/Heat (1995).mkv")
+	touch(t, lib.Path, "Ran (1985)/Ran (1985).mkv")
+	scan(t, s, lib)
+
+	hash := func(path string) string {
+		t.Helper()
+		mf, err := s.GetMediaFileByPath(path)
+		must(t, err)
+		return mf.Hash
+	}
+	heatHash, ranHash := hash(heat), hash(ran)
+
+	must(t, os.WriteFile(heat, []byte("director's cut"), 0o644))
+	res, err := media.NewScanner(s, 1, 0).ScanLibrary(lib, media.ScanModeRescan)
+	must(t, err)
+	if len(res.Errors) > 0 || res.MoviesAdded != 0 {
+		t.Errorf("rescan: %d movies added, errors %v", res.MoviesAdded, res.Errors)
+	}
+	if hash(heat) == heatHash {
+		t.Error("changed file kept its old hash")
+	}
+	if hash(ran) != ranHash {
+		t.Error("unchanged file got a new hash")
+	}
+}
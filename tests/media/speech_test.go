@@ -0,0 +1,28 @@
+package media_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bastianvv/vio/internal/media"
+)
+
+// Cancelling a sync job's context kills the ffmpeg doing its speech
+// detection.
+func TestDetectSpeechStopsWhenCanceled(t *testing.T) {
+	hangingFFmpeg(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := media.DetectSpeech(ctx, "/media/movies/heat.mkv", 1, time.Hour)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("DetectSpeech err = %v, want a cancellation error", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("DetectSpeech returned after %v, want ffmpeg killed on cancel", d)
+	}
+}
@@ -0,0 +1,49 @@
+package media_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bastianvv/vio/internal/domain"
+	"github.com/bastianvv/vio/internal/media"
+)
+
+// hangingFFmpeg puts an ffmpeg on PATH that never finishes by itself.
+func hangingFFmpeg(t *testing.T) {
+	t.Helper()
+	bin := t.TempDir()
+	must(t, os.WriteFile(filepath.Join(bin, "ffmpeg"), []byte("#!/bin/sh\nexec sleep 30\n"), 0o755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// An ffmpeg that hangs is killed once the caller gives up, and leaves no
+// cache entry behind.
+func TestExtractStopsWithContext(t *testing.T) {
+	hangingFFmpeg(t)
+
+	cache := media.NewSubtitleCache(t.TempDir())
+	index := 2
+	mf := &domain.MediaFile{ID: 1, Path: "/media/movies/heat.mkv", Hash: "abc"}
+	st := &domain.SubtitleTrack{ID: 1, Source: domain.SubtitleSourceEmbedded, StreamIndex: &index, Format: "subrip"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := cache.Extract(ctx, mf, st)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Extract err = %v, want a deadline error", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Extract returned after %v, want ffmpeg killed at the deadline", d)
+	}
+
+	left, err := os.ReadDir(cache.Dir())
+	must(t, err)
+	if len(left) != 0 {
+		t.Errorf("cache holds %d entries after a killed extraction", len(left))
+	}
+}
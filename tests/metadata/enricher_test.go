@@ -0,0 +1,77 @@
+package metadata_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bastianvv/vio/internal/domain"
+	"github.com/bastianvv/vio/internal/metadata"
+	"github.com/bastianvv/vio/internal/metadata/tmdb"
+	"github.com/bastianvv/vio/internal/store"
+)
+
+// A TMDB stand-in for one show with one season of two episodes.
+func tmdbServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tv/57243", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id": 57243, "name": "Doctor Who", "seasons": [
+			{"id": 1, "season_number": 1, "name": "Series 1"}]}`))
+	})
+	mux.HandleFunc("/tv/57243/season/1", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id": 1, "episodes": [
+			{"id": 11, "episode_number": 1, "name": "Rose"},
+			{"id": 12, "episode_number": 2, "name": "The End of the World"}]}`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnrichSeriesKeepsManualTitles(t *testing.T) {
+	s := store.NewMemoryStore()
+
+	lib := &domain.Library{Name: "Shows", Type: domain.LibraryTypeSeries, Path: "/media/shows"}
+	must(t, s.CreateLibrary(lib))
+	tmdbID := "57243"
+	sr := &domain.Series{LibraryID: lib.ID, Title: "Doctor Who", Year: 2005, TMDBID: &tmdbID}
+	must(t, s.CreateSeries(sr))
+	se := &domain.Season{SeriesID: sr.ID, Number: 1}
+	must(t, s.CreateSeason(se))
+
+	manual := &domain.Episode{SeasonID: se.ID, Number: 1, Title: "Rose (Pilot)", TitleSource: domain.TitleSourceManual}
+	guessed := &domain.Episode{SeasonID: se.ID, Number: 2, Title: "End of World", TitleSource: domain.TitleSourceFilename}
+	must(t, s.CreateEpisode(manual))
+	must(t, s.CreateEpisode(guessed))
+
+	e := metadata.NewTMDBEnricher(s, tmdb.NewWithBaseURL("key", tmdbServer(t).URL), t.TempDir())
+
+	// Twice: re-enrichment must not replace the manual title either.
+	for range 2 {
+		must(t, e.EnrichSeries(context.Background(), sr.ID))
+
+		got, err := s.GetEpisode(manual.ID)
+		must(t, err)
+		if got.Title != "Rose (Pilot)" || got.TitleSource != domain.TitleSourceManual {
+			t.Errorf("manual episode = %q (%s), want the manual title kept", got.Title, got.TitleSource)
+		}
+		if got.TMDBID == nil || *got.TMDBID != "11" {
+			t.Errorf("manual episode TMDB ID = %v, want 11", got.TMDBID)
+		}
+
+		got, err = s.GetEpisode(guessed.ID)
+		must(t, err)
+		if got.Title != "The End of the World" || got.TitleSource != domain.TitleSourceTMDB {
+			t.Errorf("guessed episode = %q (%s), want the TMDB title", got.Title, got.TitleSource)
+		}
+	}
+}
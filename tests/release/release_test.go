@@ -0,0 +1,125 @@
+package release_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/bastianvv/vio/internal/release"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		want release.Info
+	}{
+		{
+			name: "/media/movies/Inception (2010)/Inception.2010.2160p.UHD.BluRay.REMUX.HDR10.DV.TrueHD.7.1.Atmos-GROUP.mkv",
+			want: release.Info{Resolution: "2160p", Source: "BluRay", AudioCodec: "truehd",
+				HDR: []string{"HDR10", "DV"}, Group: "GROUP", Remux: true},
+		},
+		{
+			name: "The.Matrix.1999.1080p.BluRay.x264.DTS-HD.MA.5.1-FGT.mkv",
+			want: release.Info{Resolution: "1080p", Source: "BluRay", VideoCodec: "h264",
+				AudioCodec: "dts-hd ma", Group: "FGT"},
+		},
+		{
+			name: "Show.S01E01.1080p.WEB-DL.DDP5.1.H.264-NTb.mkv",
+			want: release.Info{Resolution: "1080p", Source: "WEB-DL", VideoCodec: "h264",
+				AudioCodec: "eac3", Group: "NTb"},
+		},
+		{
+			name: "Show.S02E03.720p.WEBRip.x265.AAC2.0-PSA.mkv",
+			want: release.Info{Resolution: "720p", Source: "WEBRip", VideoCodec: "hevc",
+				AudioCodec: "aac", Group: "PSA"},
+		},
+		{
+			name: "Movie.2019.PROPER.REPACK.1080i.HDTV.AVC.AC3-GRP.ts",
+			want: release.Info{Resolution: "1080i", Source: "HDTV", VideoCodec: "h264",
+				AudioCodec: "ac3", Group: "GRP", Proper: true, Repack: true},
+		},
+		{
+			name: "Show.S01E01.576i.HDTV.MPEG2-GRP.ts",
+			want: release.Info{Resolution: "576i", Source: "HDTV", VideoCodec: "mpeg2video", Group: "GRP"},
+		},
+		{
+			name: "Concert.1995.480i.DVD9.MPEG-2.AC3-OLD.mkv",
+			want: release.Info{Resolution: "480i", Source: "DVD", VideoCodec: "mpeg2video", AudioCodec: "ac3", Group: "OLD"},
+		},
+		{
+			name: "Movie.2023.2160p.WEB-DL.DV.HDR10+.HEVC.DDP.Atmos-FLUX.mkv",
+			want: release.Info{Resolution: "2160p", Source: "WEB-DL", VideoCodec: "hevc",
+				AudioCodec: "eac3", HDR: []string{"HDR10+", "DV"}, Group: "FLUX"},
+		},
+		{
+			name: "Old.Movie.1985.DVDRip.XviD.MP3-RiPPER.avi",
+			want: release.Info{Source: "DVD", VideoCodec: "mpeg4", AudioCodec: "mp3", Group: "RiPPER"},
+		},
+		{
+			name: "[SubsPlease] Anime Title - 05 (1080p) [ABCD1234].mkv",
+			want: release.Info{Resolution: "1080p", Group: "SubsPlease"},
+		},
+		{
+			// Tags that sit where a group would are not groups.
+			name: "Movie.2020.1080p.WEB-DL.mkv",
+			want: release.Info{Resolution: "1080p", Source: "WEB-DL"},
+		},
+		{
+			name: "Movie.2020.720p.BluRay.x264-DTS.mkv",
+			want: release.Info{Resolution: "720p", Source: "BluRay", VideoCodec: "h264", AudioCodec: "dts"},
+		},
+		{
+			// A trailing year or episode number is not a group either.
+			name: "Some.Show-2021.mkv",
+			want: release.Info{},
+		},
+		{
+			name: "Movie Title - 2021.mkv",
+			want: release.Info{},
+		},
+		{
+			// Plain names carry nothing; words containing tags don't count.
+			name: "Webster's Dream (1999).mkv",
+			want: release.Info{},
+		},
+		{
+			name: "Hdtvs and Remuxes.mkv",
+			want: release.Info{},
+		},
+		{
+			name: "Movie.2018.1080p.BluRay.DTS-X.7.1.x265.10bit-HDR.mkv",
+			want: release.Info{Resolution: "1080p", Source: "BluRay", VideoCodec: "hevc",
+				AudioCodec: "dts:x", HDR: []string{"HDR10"}},
+		},
+		{
+			name: "Movie.2021.4K.HLG.FLAC.mkv",
+			want: release.Info{Resolution: "2160p", AudioCodec: "flac", HDR: []string{"HLG"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := release.Parse(tt.name)
+			if got.Resolution != tt.want.Resolution ||
+				got.Source != tt.want.Source ||
+				got.VideoCodec != tt.want.VideoCodec ||
+				got.AudioCodec != tt.want.AudioCodec ||
+				!slices.Equal(got.HDR, tt.want.HDR) ||
+				got.Group != tt.want.Group ||
+				got.Remux != tt.want.Remux ||
+				got.Proper != tt.want.Proper ||
+				got.Repack != tt.want.Repack {
+				t.Errorf("Parse(%q)\n got %+v\nwant %+v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHDRString(t *testing.T) {
+	info := release.Parse("Movie.2023.2160p.DV.HDR10.mkv")
+	if got := info.HDRString(); got != "HDR10,DV" {
+		t.Errorf("HDRString() = %q, want %q", got, "HDR10,DV")
+	}
+	if got := release.Parse("Movie.2023.1080p.mkv").HDRString(); got != "" {
+		t.Errorf("HDRString() without flags = %q, want empty", got)
+	}
+}
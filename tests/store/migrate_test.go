@@ -0,0 +1,166 @@
+package store_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bastianvv/vio/internal/domain"
+	"github.com/bastianvv/vio/internal/store"
+)
+
+// Tracks written before the scanner normalized languages keep their raw
+// ffprobe or file-name tags until migration 15 rewrites them.
+func TestSQLiteMigrateNormalizesTrackLanguages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vio.db")
+
+	s, err := store.NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	lib := &domain.Library{Name: "Movies", Type: domain.LibraryTypeMovies, Path: "/media/movies"}
+	must(t, s.CreateLibrary(lib))
+	movie := &domain.Movie{LibraryID: lib.ID, Title: "Heat", Year: 1995}
+	must(t, s.CreateMovie(movie))
+	seen := time.Now().UTC()
+	mf := &domain.MediaFile{LibraryID: lib.ID, MovieID: &movie.ID, Path: "/media/movies/heat.mkv", LastSeenAt: &seen}
+	must(t, s.CreateMediaFile(mf))
+
+	for i, l := range []string{"eng", "English", "pt_br", "und", "qya"} {
+		must(t, s.CreateAudioTrack(&domain.AudioTrack{MediaFileID: mf.ID, StreamIndex: i, Language: l}))
+	}
+	srt := "/media/movies/heat.de.srt"
+	must(t, s.CreateSubtitleTrack(&domain.SubtitleTrack{
+		MediaFileID: mf.ID, Source: domain.SubtitleSourceExternal, ExternalPath: &srt, Language: "German",
+	}))
+	must(t, s.Close())
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`DELETE FROM schema_migrations WHERE version >= 15`)
+	_ = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = store.NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	if err := s.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	audio, err := s.ListAudioTracks(mf.ID)
+	must(t, err)
+	var got []string
+	for _, at := range audio {
+		got = append(got, at.Language)
+	}
+	want := []string{"en", "en", "pt-BR", "", "qya"}
+	if len(got) != len(want) {
+		t.Fatalf("audio languages = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("audio languages = %q, want %q", got, want)
+			break
+		}
+	}
+
+	subs, err := s.ListSubtitleTracks(mf.ID)
+	must(t, err)
+	if len(subs) != 1 || subs[0].Language != "de" {
+		t.Errorf("subtitle tracks = %+v, want one with language de", subs)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A database indexed by a build with FTS5 has to open in one without, and
+// the other way round; Migrate rebuilds the index in the form it supports.
+func TestSQLiteMigrateRebuildsForeignSearchIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vio.db")
+
+	s, err := store.NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	lib := &domain.Library{Name: "Movies", Type: domain.LibraryTypeMovies, Path: "/media/movies"}
+	must(t, s.CreateLibrary(lib))
+	must(t, s.CreateMovie(&domain.Movie{LibraryID: lib.ID, Title: "Heat", Year: 1995}))
+	must(t, s.Close())
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	var fts5 bool
+	if err := db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts5); err != nil {
+		t.Fatal(err)
+	}
+
+	// Leave behind what the other kind of build would have written.
+	foreign := []string{
+		`DROP TABLE search_index`,
+		`CREATE TABLE search_index (
+            id INTEGER PRIMARY KEY, kind TEXT NOT NULL, ref_id INTEGER NOT NULL,
+            library_id INTEGER NOT NULL, title TEXT NOT NULL,
+            original_title TEXT NOT NULL, overview TEXT NOT NULL
+        )`,
+	}
+	if !fts5 {
+		foreign = []string{
+			`DROP TABLE search_index`,
+			`CREATE TABLE search_index_data (id INTEGER PRIMARY KEY, block BLOB)`,
+			`CREATE TABLE search_index_idx (segid, term, pgno, PRIMARY KEY(segid, term)) WITHOUT ROWID`,
+			`CREATE TABLE search_index_content (id INTEGER PRIMARY KEY, c0, c1, c2, c3, c4, c5)`,
+			`CREATE TABLE search_index_docsize (id INTEGER PRIMARY KEY, sz BLOB)`,
+			`CREATE TABLE search_index_config (k PRIMARY KEY, v) WITHOUT ROWID`,
+			`PRAGMA writable_schema = ON`,
+			`INSERT INTO sqlite_master (type, name, tbl_name, rootpage, sql)
+             VALUES ('table', 'search_index', 'search_index', 0,
+                     'CREATE VIRTUAL TABLE search_index USING fts5(title, original_title, overview)')`,
+			`PRAGMA writable_schema = RESET`,
+		}
+	}
+	for _, q := range foreign {
+		if _, err := db.Exec(q); err != nil {
+			_ = db.Close()
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	_ = db.Close()
+
+	s, err = store.NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	if err := s.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Search("heat", "", 0, 10)
+	must(t, err)
+	if len(got) != 1 || got[0].Title != "Heat" {
+		t.Errorf("Search(heat) = %+v, want Heat", got)
+	}
+}
@@ -0,0 +1,186 @@
+package subprovider_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bastianvv/vio/internal/subprovider"
+)
+
+const srt = "1\n00:00:01,000 --> 00:00:02,000\nHello\n"
+
+// openSubtitlesStandIn answers like the OpenSubtitles REST API. File 7 is
+// downloadable, 8 is unknown, 9 is over the daily allowance and 10 fails.
+func openSubtitlesStandIn(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+
+	mux.HandleFunc("GET /subtitles", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Api-Key") != "key" {
+			http.Error(w, `{"message":"invalid api key"}`, http.StatusUnauthorized)
+			return
+		}
+		q := r.URL.Query()
+		switch q.Get("tmdb_id") {
+		case "429":
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"message":"Throttle limit reached. Retry later."}`))
+			return
+		case "500":
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		if got := q.Get("languages"); got != "de,en" {
+			t.Errorf("languages = %q, want de,en", got)
+		}
+		if got := q.Get("moviehash"); got != "8e245d9679d31e12" {
+			t.Errorf("moviehash = %q", got)
+		}
+		_, _ = w.Write([]byte(`{"data":[
+			{"id":"1","attributes":{"language":"en","release":"Heat.1995.1080p.BluRay",
+			 "fps":23.976,"hearing_impaired":true,"download_count":120,"moviehash_match":true,
+			 "files":[{"file_id":7,"file_name":"Heat.1995.1080p.BluRay.srt"}]}},
+			{"id":"2","attributes":{"language":"pt-BR","release":"Heat","download_count":3,
+			 "files":[{"file_id":11,"file_name":"Heat.ass"},{"file_id":12,"file_name":"Heat"}]}}
+		]}`))
+	})
+
+	mux.HandleFunc("POST /download", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			FileID int64 `json:"file_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("download body: %v", err)
+		}
+		switch body.FileID {
+		case 7:
+			_, _ = w.Write([]byte(`{"link":"` + srv.URL + `/files/7.srt","file_name":"Heat.srt","remaining":19}`))
+		case 8:
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+		case 9:
+			w.WriteHeader(http.StatusNotAcceptable)
+			_, _ = w.Write([]byte(`{"requests":21,"remaining":-1,
+				"message":"You have downloaded your allowed 20 subtitles for 24h.",
+				"reset_time":"23 hours and 59 minutes"}`))
+		default:
+			http.Error(w, "boom", http.StatusBadGateway)
+		}
+	})
+
+	mux.HandleFunc("GET /files/7.srt", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(srt))
+	})
+
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOpenSubtitlesSearch(t *testing.T) {
+	srv := openSubtitlesStandIn(t)
+	c := subprovider.NewOpenSubtitles("key", srv.URL+"/")
+
+	got, err := c.Search(context.Background(), subprovider.Query{
+		MovieHash: "8e245d9679d31e12",
+		TMDBID:    "949",
+		Languages: []string{"en", "DE"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []subprovider.Candidate{
+		{Provider: "opensubtitles", ID: "7", Language: "en", Release: "Heat.1995.1080p.BluRay",
+			FileName: "Heat.1995.1080p.BluRay.srt", Format: "srt", FPS: 23.976,
+			HashMatch: true, HearingImpaired: true, Downloads: 120},
+		{Provider: "opensubtitles", ID: "11", Language: "pt-BR", Release: "Heat",
+			FileName: "Heat.ass", Format: "ass", Downloads: 3},
+		{Provider: "opensubtitles", ID: "12", Language: "pt-BR", Release: "Heat",
+			FileName: "Heat", Format: "srt", Downloads: 3},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d candidates, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("candidate %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestOpenSubtitlesSearchErrors(t *testing.T) {
+	srv := openSubtitlesStandIn(t)
+
+	tests := []struct {
+		name  string
+		key   string
+		tmdb  string
+		quota bool
+	}{
+		{name: "rate limited", key: "key", tmdb: "429", quota: true},
+		{name: "server error", key: "key", tmdb: "500"},
+		{name: "bad api key", key: "wrong", tmdb: "949"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := subprovider.NewOpenSubtitles(tt.key, srv.URL)
+			_, err := c.Search(context.Background(), subprovider.Query{TMDBID: tt.tmdb})
+			if err == nil {
+				t.Fatal("Search succeeded, want an error")
+			}
+			if got := errors.Is(err, subprovider.ErrQuota); got != tt.quota {
+				t.Errorf("errors.Is(%v, ErrQuota) = %v, want %v", err, got, tt.quota)
+			}
+		})
+	}
+}
+
+func TestOpenSubtitlesDownload(t *testing.T) {
+	srv := openSubtitlesStandIn(t)
+	c := subprovider.NewOpenSubtitles("key", srv.URL)
+
+	tests := []struct {
+		name    string
+		id      string
+		want    string
+		wantErr error
+		errText string
+	}{
+		{name: "ok", id: "7", want: srt},
+		{name: "unknown file", id: "8", wantErr: subprovider.ErrNotFound},
+		{name: "daily allowance used", id: "9", wantErr: subprovider.ErrQuota,
+			errText: "allowed 20 subtitles for 24h. (resets 23 hours and 59 minutes)"},
+		{name: "server error", id: "10", errText: "502"},
+		{name: "invalid id", id: "abc", errText: "invalid file id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := c.Download(context.Background(), subprovider.Candidate{Provider: "opensubtitles", ID: tt.id})
+			if tt.wantErr == nil && tt.errText == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != tt.want {
+					t.Errorf("Download = %q, want %q", data, tt.want)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Download succeeded, want an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Download error = %v, want %v", err, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("Download error = %q, want it to contain %q", err, tt.errText)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package subsync_test
+
+import (
+	"testing"
+
+	"github.com/bastianvv/vio/internal/subsync"
+)
+
+func TestRegistryCancel(t *testing.T) {
+	r := subsync.NewRegistry()
+
+	job, ctx := r.Start(1)
+	if !r.Cancel(job.ID) {
+		t.Fatal("Cancel of a running job = false")
+	}
+	select {
+	case <-ctx.Done():
+	default:
+		t.Fatal("job context still live after Cancel")
+	}
+	r.Fail(job.ID, ctx.Err())
+	if got, _ := r.Get(job.ID); got.Status != subsync.JobFailed {
+		t.Errorf("canceled job status = %s, want failed", got.Status)
+	}
+	if r.Cancel(job.ID) {
+		t.Error("Cancel of a failed job = true")
+	}
+
+	done, ctx := r.Start(2)
+	r.Finish(done.ID, &subsync.Result{})
+	if ctx.Err() == nil {
+		t.Error("finished job context not released")
+	}
+	if r.Cancel(done.ID) {
+		t.Error("Cancel of a finished job = true")
+	}
+
+	if r.Cancel("no-such-job") {
+		t.Error("Cancel of an unknown job = true")
+	}
+}
@@ -0,0 +1,141 @@
+package subtitle_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bastianvv/vio/internal/subtitle"
+)
+
+// dialogue is the ground truth: 60 lines of uneven length and spacing from
+// the 10s mark on, the way speech sits in a real track.
+func dialogue() []subtitle.Cue {
+	var out []subtitle.Cue
+	at := 10 * time.Second
+	for i := range 60 {
+		length := 1200*time.Millisecond + time.Duration(i%4)*500*time.Millisecond
+		gap := 800*time.Millisecond + time.Duration(i%3)*700*time.Millisecond + time.Duration(i%5)*300*time.Millisecond
+		out = append(out, subtitle.Cue{Start: at, End: at + length, Text: "line"})
+		at += length + gap
+	}
+	return out
+}
+
+// speechFromSilences inverts a silence map the way the silencedetect log is
+// read: speech is whatever lies between silences.
+func speechFromSilences(silences []subtitle.Interval, duration time.Duration) []subtitle.Interval {
+	var speech []subtitle.Interval
+	var cursor time.Duration
+	for _, s := range silences {
+		if s.Start > cursor {
+			speech = append(speech, subtitle.Interval{Start: cursor, End: s.Start})
+		}
+		cursor = s.End
+	}
+	if duration > cursor {
+		speech = append(speech, subtitle.Interval{Start: cursor, End: duration})
+	}
+	return speech
+}
+
+// silenceMap is the complement of the dialogue over the file.
+func silenceMap(lines []subtitle.Cue, duration time.Duration) []subtitle.Interval {
+	var silences []subtitle.Interval
+	var cursor time.Duration
+	for _, c := range lines {
+		if c.Start > cursor {
+			silences = append(silences, subtitle.Interval{Start: cursor, End: c.Start})
+		}
+		cursor = c.End
+	}
+	if duration > cursor {
+		silences = append(silences, subtitle.Interval{Start: cursor, End: duration})
+	}
+	return silences
+}
+
+func TestAlign(t *testing.T) {
+	truth := dialogue()
+	duration := truth[len(truth)-1].End + 30*time.Second
+	speech := speechFromSilences(silenceMap(truth, duration), duration)
+
+	shifted := func(d time.Duration) []subtitle.Cue {
+		return subtitle.Shift(append([]subtitle.Cue(nil), truth...), d)
+	}
+
+	tests := []struct {
+		name     string
+		subs     []subtitle.Cue
+		offset   time.Duration
+		from, to float64
+	}{
+		{name: "in sync", subs: shifted(0)},
+		{name: "subtitle 3s early", subs: shifted(-3 * time.Second), offset: 3 * time.Second},
+		{name: "subtitle 2.5s late", subs: shifted(2500 * time.Millisecond), offset: -2500 * time.Millisecond},
+		{
+			// Made for the 25fps PAL release: 4% short.
+			name: "subtitle for 25fps",
+			subs: subtitle.Retime(append([]subtitle.Cue(nil), truth...), 23.976, 25),
+			from: 25, to: 23.976,
+		},
+		{
+			name: "subtitle for 23.976 on a 25fps release",
+			subs: subtitle.Retime(append([]subtitle.Cue(nil), truth...), 25, 23.976),
+			from: 23.976, to: 25,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fit := subtitle.Align(tt.subs, speech, time.Minute)
+
+			if fit.FPSFrom != tt.from || fit.FPSTo != tt.to {
+				t.Errorf("fps = %v→%v, want %v→%v", fit.FPSFrom, fit.FPSTo, tt.from, tt.to)
+			}
+			if d := fit.Offset - tt.offset; d < -200*time.Millisecond || d > 200*time.Millisecond {
+				t.Errorf("offset = %v, want %v", fit.Offset, tt.offset)
+			}
+			if fit.Score < 0.8 {
+				t.Errorf("score = %.2f, want a confident fit", fit.Score)
+			}
+
+			fixed := fit.Apply(append([]subtitle.Cue(nil), tt.subs...))
+			if len(fixed) != len(truth) {
+				t.Fatalf("Apply kept %d cues, want %d", len(fixed), len(truth))
+			}
+			for i := range truth {
+				if d := fixed[i].Start - truth[i].Start; d < -300*time.Millisecond || d > 300*time.Millisecond {
+					t.Errorf("cue %d starts at %v after the fit, want %v", i, fixed[i].Start, truth[i].Start)
+					break
+				}
+			}
+		})
+	}
+}
+
+// A subtitle that has nothing to do with the audio must not score as a
+// good fit, or the sync job would store it.
+func TestAlignUnrelatedSpeech(t *testing.T) {
+	truth := dialogue()
+	duration := truth[len(truth)-1].End + 30*time.Second
+
+	// Speech that alternates evenly, one second on, one off.
+	var speech []subtitle.Interval
+	for at := time.Duration(0); at < duration; at += 2 * time.Second {
+		speech = append(speech, subtitle.Interval{Start: at, End: at + time.Second})
+	}
+
+	if fit := subtitle.Align(truth, speech, time.Minute); fit.Score > 0.5 {
+		t.Errorf("score = %.2f for unrelated speech, want a poor fit", fit.Score)
+	}
+}
+
+func TestAlignWithoutInput(t *testing.T) {
+	speech := []subtitle.Interval{{Start: 0, End: time.Second}}
+	if fit := subtitle.Align(nil, speech, time.Minute); fit != (subtitle.Fit{}) {
+		t.Errorf("Align without cues = %+v, want the zero fit", fit)
+	}
+	if fit := subtitle.Align(dialogue(), nil, time.Minute); fit != (subtitle.Fit{}) {
+		t.Errorf("Align without speech = %+v, want the zero fit", fit)
+	}
+}
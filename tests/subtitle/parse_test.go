@@ -0,0 +1,218 @@
+package subtitle_test
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/bastianvv/vio/internal/subtitle"
+)
+
+func ms(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+
+func utf16LE(s string, bom bool) []byte {
+	var out []byte
+	if bom {
+		out = append(out, 0xFF, 0xFE)
+	}
+	for _, u := range utf16.Encode([]rune(s)) {
+		out = binary.LittleEndian.AppendUint16(out, u)
+	}
+	return out
+}
+
+const assScript = `[Script Info]
+ScriptType: v4.00+
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, Bold, Italic, Underline, Alignment
+Style: Default,Arial,20,&H00FFFFFF,0,0,0,2
+Style: Sign,Arial,20,&H00FFFFFF,-1,0,0,8
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+Dialogue: 0,0:00:05.00,0:00:06.50,Sign,,0,0,0,,EXIT, now
+Dialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,Hello,\Nworld {\i1}there{\i0}!
+Dialogue: 0,0:00:03.00,0:00:04.00,Default,,0,0,0,,{\an8}Top {\p1}m 0 0 l 10 10{\p0}text
+Comment: 0,0:00:03.00,0:00:04.00,Default,,0,0,0,,hidden
+`
+
+// Every format parses to the same cues however it is encoded, and the cues
+// survive a trip through WebVTT unchanged.
+func TestParseRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		format subtitle.Format
+		data   []byte
+		want   []subtitle.Cue
+	}{
+		{
+			name:   "srt",
+			format: subtitle.FormatSRT,
+			data: []byte("1\n00:00:01,000 --> 00:00:02,500\nHello\nworld\n\n" +
+				"2\n00:00:03,000 --> 00:00:04,000\n<i>It's</i> <font color=\"red\">red</font> & <b>bold</b>\n"),
+			want: []subtitle.Cue{
+				{Start: ms(1000), End: ms(2500), Text: "Hello\nworld"},
+				{Start: ms(3000), End: ms(4000), Text: "<i>It's</i> red &amp; <b>bold</b>"},
+			},
+		},
+		{
+			name:   "srt with CRLF and a UTF-8 BOM",
+			format: subtitle.FormatSRT,
+			data:   []byte("\xEF\xBB\xBF1\r\n00:00:01,000 --> 00:00:02,000\r\nCRLF\r\nlines\r\n\r\n2\r\n00:00:03,000 --> 00:00:04,000\r\nB\r\n"),
+			want: []subtitle.Cue{
+				{Start: ms(1000), End: ms(2000), Text: "CRLF\nlines"},
+				{Start: ms(3000), End: ms(4000), Text: "B"},
+			},
+		},
+		{
+			name:   "srt in Windows-1252",
+			format: subtitle.FormatSRT,
+			data:   []byte("1\r\n00:00:01,000 --> 00:00:02,000\r\nCaf\xe9 \x93cr\xe8me\x94 \x80\r\n"),
+			want:   []subtitle.Cue{{Start: ms(1000), End: ms(2000), Text: "Café “crème” €"}},
+		},
+		{
+			name:   "srt in UTF-16LE with BOM",
+			format: subtitle.FormatSRT,
+			data:   utf16LE("1\r\n00:00:01,000 --> 00:00:02,000\r\nÜber\r\n", true),
+			want:   []subtitle.Cue{{Start: ms(1000), End: ms(2000), Text: "Über"}},
+		},
+		{
+			name:   "srt in UTF-16LE without BOM",
+			format: subtitle.FormatSRT,
+			data:   utf16LE("1\n00:00:01,000 --> 00:00:02,000\nplain ascii text\n", false),
+			want:   []subtitle.Cue{{Start: ms(1000), End: ms(2000), Text: "plain ascii text"}},
+		},
+		{
+			name:   "srt with overlapping cues",
+			format: subtitle.FormatSRT,
+			data: []byte("1\n00:00:01,000 --> 00:00:05,000\nLong\n\n" +
+				"2\n00:00:02,000 --> 00:00:03,000\nInside\n\n" +
+				"3\n00:00:04,500 --> 00:00:06,000\nAcross\n"),
+			want: []subtitle.Cue{
+				{Start: ms(1000), End: ms(5000), Text: "Long"},
+				{Start: ms(2000), End: ms(3000), Text: "Inside"},
+				{Start: ms(4500), End: ms(6000), Text: "Across"},
+			},
+		},
+		{
+			name:   "srt without blank separators, with dots and an alignment tag",
+			format: subtitle.FormatSRT,
+			data: []byte("1\n00:00:01.000 --> 00:00:02.000\n{\\an8}A\n" +
+				"2\n00:00:03,5 --> 00:00:04,25\nB\n"),
+			want: []subtitle.Cue{
+				{Start: ms(1000), End: ms(2000), Text: "A", Settings: "line:0"},
+				{Start: ms(3500), End: ms(4250), Text: "B"},
+			},
+		},
+		{
+			name:   "ass",
+			format: subtitle.FormatASS,
+			data:   []byte(assScript),
+			want: []subtitle.Cue{
+				{Start: ms(1000), End: ms(2000), Text: "Hello,\nworld <i>there</i>!"},
+				{Start: ms(3000), End: ms(4000), Text: "Top text", Settings: "line:0"},
+				{Start: ms(5000), End: ms(6500), Text: "<b>EXIT, now</b>", Settings: "line:0"},
+			},
+		},
+		{
+			name:   "ass with CRLF",
+			format: subtitle.FormatASS,
+			data:   []byte(strings.ReplaceAll(assScript, "\n", "\r\n")),
+			want: []subtitle.Cue{
+				{Start: ms(1000), End: ms(2000), Text: "Hello,\nworld <i>there</i>!"},
+				{Start: ms(3000), End: ms(4000), Text: "Top text", Settings: "line:0"},
+				{Start: ms(5000), End: ms(6500), Text: "<b>EXIT, now</b>", Settings: "line:0"},
+			},
+		},
+		{
+			name:   "microdvd with a declared frame rate",
+			format: subtitle.FormatMicroDVD,
+			data:   []byte("{1}{1}25.000\r\n{25}{50}Hello|world\r\n{75}{100}{y:i}Slanted\r\n{125}{150}/Line|plain\r\n"),
+			want: []subtitle.Cue{
+				{Start: ms(1000), End: ms(2000), Text: "Hello\nworld"},
+				{Start: ms(3000), End: ms(4000), Text: "<i>Slanted</i>"},
+				{Start: ms(5000), End: ms(6000), Text: "<i>Line</i>\nplain"},
+			},
+		},
+		{
+			name:   "microdvd at the default frame rate",
+			format: subtitle.FormatMicroDVD,
+			data:   []byte("{0}{2997}Caf\xe9\n"),
+			want:   []subtitle.Cue{{Start: 0, End: 125 * time.Second, Text: "Café"}},
+		},
+		{
+			name:   "vtt",
+			format: subtitle.FormatVTT,
+			data: []byte("\xEF\xBB\xBFWEBVTT\r\n\r\nNOTE a comment\r\n\r\n" +
+				"intro\r\n00:01.000 --> 00:02.000 line:0\r\n<i>Hi</i>\r\n\r\n" +
+				"00:00:03.000 --> 00:00:04.000\r\nThere\r\n"),
+			want: []subtitle.Cue{
+				{Start: ms(1000), End: ms(2000), Text: "<i>Hi</i>", Settings: "line:0"},
+				{Start: ms(3000), End: ms(4000), Text: "There"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := subtitle.Parse(tt.data, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertCues(t, "Parse", got, tt.want)
+
+			var b strings.Builder
+			if err := subtitle.WriteVTT(&b, got); err != nil {
+				t.Fatal(err)
+			}
+			back, err := subtitle.ParseVTT(b.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertCues(t, "WriteVTT/ParseVTT", back, tt.want)
+		})
+	}
+}
+
+func TestParseVTTRequiresHeader(t *testing.T) {
+	if _, err := subtitle.Parse([]byte("00:01.000 --> 00:02.000\nHi\n"), subtitle.FormatVTT); err == nil {
+		t.Error("Parse accepted a VTT file without its WEBVTT header")
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want subtitle.Format
+		ok   bool
+	}{
+		{"/m/Movie.en.srt", subtitle.FormatSRT, true},
+		{"/m/Movie.ASS", subtitle.FormatASS, true},
+		{"/m/Movie.ssa", subtitle.FormatASS, true},
+		{"/m/Movie.sub", subtitle.FormatMicroDVD, true},
+		{"/m/Movie.vtt", subtitle.FormatVTT, true},
+		{"/m/Movie.sup", "", false},
+		{"/m/Movie.idx", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := subtitle.FormatFromPath(tt.path)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("FormatFromPath(%q) = %q, %v; want %q, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func assertCues(t *testing.T, what string, got, want []subtitle.Cue) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d cues, want %d:\n%+v", what, len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: cue %d = %+v, want %+v", what, i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package subtitle_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bastianvv/vio/internal/subtitle"
+)
+
+func cues(spans ...[2]int) []subtitle.Cue {
+	var out []subtitle.Cue
+	for _, s := range spans {
+		out = append(out, subtitle.Cue{Start: ms(s[0]), End: ms(s[1]), Text: "x"})
+	}
+	return out
+}
+
+func TestShift(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset time.Duration
+		in     []subtitle.Cue
+		want   []subtitle.Cue
+	}{
+		{
+			name:   "positive offset delays cues",
+			offset: 1500 * time.Millisecond,
+			in:     cues([2]int{1000, 2000}, [2]int{3000, 4000}),
+			want:   cues([2]int{2500, 3500}, [2]int{4500, 5500}),
+		},
+		{
+			name:   "negative offset shows cues earlier",
+			offset: -500 * time.Millisecond,
+			in:     cues([2]int{1000, 2000}, [2]int{3000, 4000}),
+			want:   cues([2]int{500, 1500}, [2]int{2500, 3500}),
+		},
+		{
+			name:   "cues straddling zero are clipped, cues before it dropped",
+			offset: -2 * time.Second,
+			in:     cues([2]int{500, 1500}, [2]int{1000, 2000}, [2]int{1500, 2500}, [2]int{3000, 4000}),
+			want:   cues([2]int{0, 500}, [2]int{1000, 2000}),
+		},
+		{
+			name:   "zero offset changes nothing",
+			offset: 0,
+			in:     cues([2]int{1000, 2000}),
+			want:   cues([2]int{1000, 2000}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertCues(t, "Shift", subtitle.Shift(tt.in, tt.offset), tt.want)
+		})
+	}
+}
+
+func TestRetime(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to float64
+		in       []subtitle.Cue
+		want     []subtitle.Cue
+	}{
+		{
+			// PAL runs 4% fast, so its subtitles come early on the film
+			// release and have to be stretched.
+			name: "25fps subtitle on a 23.976 video is stretched",
+			from: 25, to: 23.976,
+			in:   cues([2]int{23976, 47952}),
+			want: cues([2]int{25000, 50000}),
+		},
+		{
+			name: "23.976 subtitle on a 25fps video is compressed",
+			from: 23.976, to: 25,
+			in:   cues([2]int{25000, 50000}),
+			want: cues([2]int{23976, 47952}),
+		},
+		{
+			name: "24 to 25",
+			from: 24, to: 25,
+			in:   cues([2]int{5000, 10000}),
+			want: cues([2]int{4800, 9600}),
+		},
+		{
+			name: "same rate changes nothing",
+			from: 25, to: 25,
+			in:   cues([2]int{1000, 2000}),
+			want: cues([2]int{1000, 2000}),
+		},
+		{
+			name: "a missing rate changes nothing",
+			from: 25, to: 0,
+			in:   cues([2]int{1000, 2000}),
+			want: cues([2]int{1000, 2000}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := subtitle.Retime(tt.in, tt.from, tt.to)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Retime returned %d cues, want %d", len(got), len(tt.want))
+			}
+			for i := range tt.want {
+				if !near(got[i].Start, tt.want[i].Start) || !near(got[i].End, tt.want[i].End) {
+					t.Errorf("cue %d = %v–%v, want %v–%v", i, got[i].Start, got[i].End, tt.want[i].Start, tt.want[i].End)
+				}
+			}
+		})
+	}
+}
+
+// Retiming then shifting is what the stream endpoint does: the offset is
+// in the target video's time.
+func TestRetimeThenShift(t *testing.T) {
+	got := subtitle.Shift(subtitle.Retime(cues([2]int{23976, 24976}), 25, 23.976), -time.Second)
+	want := cues([2]int{24000, 25042})
+	if !near(got[0].Start, want[0].Start) || !near(got[0].End, want[0].End) {
+		t.Errorf("cue = %v–%v, want %v–%v", got[0].Start, got[0].End, want[0].Start, want[0].End)
+	}
+}
+
+func near(a, b time.Duration) bool {
+	d := a - b
+	return d > -time.Millisecond && d < time.Millisecond
+}
@@ -0,0 +1,51 @@
+package subtitle_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bastianvv/vio/internal/subtitle"
+)
+
+// Chapter titles come from file tags and may hold anything; the rendered
+// track must still parse back to one cue per title.
+func TestWriteVTTSanitizesCueText(t *testing.T) {
+	titles := []string{
+		"Intro --> Act I",
+		"Two\n\nParagraphs",
+		"Windows\r\n\r\nbreaks",
+		"Blank\n   \nline",
+		"Plain",
+	}
+	var cues []subtitle.Cue
+	for i, title := range titles {
+		cues = append(cues, subtitle.Cue{
+			Start: time.Duration(i) * time.Minute,
+			End:   time.Duration(i+1) * time.Minute,
+			Text:  title,
+		})
+	}
+
+	var b strings.Builder
+	if err := subtitle.WriteVTT(&b, cues); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := subtitle.ParseVTT(b.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Intro -> Act I", "Two\nParagraphs", "Windows\nbreaks", "Blank\nline", "Plain"}
+	if len(got) != len(want) {
+		t.Fatalf("parsed %d cues, want %d:\n%s", len(got), len(want), b.String())
+	}
+	for i := range want {
+		if got[i].Text != want[i] {
+			t.Errorf("cue %d text = %q, want %q", i, got[i].Text, want[i])
+		}
+		if got[i].Start != cues[i].Start || got[i].End != cues[i].End {
+			t.Errorf("cue %d = %v–%v, want %v–%v", i, got[i].Start, got[i].End, cues[i].Start, cues[i].End)
+		}
+	}
+}